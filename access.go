@@ -0,0 +1,12 @@
+package cosmwasm
+
+import (
+	"github.com/line/wasmvm/internal/api"
+)
+
+// AccessSet is the read and write set of a contract call. Use ConflictsWith to check if two calls
+// can be committed in either order.
+type AccessSet = api.AccessSet
+
+// KeyRange is a range of keys a contract iterated over
+type KeyRange = api.KeyRange
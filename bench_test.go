@@ -0,0 +1,251 @@
+package cosmwasm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+var benchmarkContracts = []string{
+	HACKATOM_TEST_CONTRACT,
+	CYBERPUNK_TEST_CONTRACT,
+	QUEUE_TEST_CONTRACT,
+	IBC_TEST_CONTRACT,
+	"./testdata/reflect.wasm",
+}
+
+// withVMCacheSize is like withVM with the given size of the in-memory cache. A size of 0 disables it,
+// such that every call loads the module from the file system cache.
+func withVMCacheSize(b *testing.B, cacheSize uint32) *VM {
+	api.RequireNoVectorLeaks(b)
+	tmpdir, err := ioutil.TempDir("", "wasmvm-bench")
+	require.NoError(b, err)
+	vm, err := NewVM(tmpdir, TESTING_FEATURES, TESTING_MEMORY_LIMIT, TESTING_PRINT_DEBUG, cacheSize)
+	require.NoError(b, err)
+
+	b.Cleanup(func() {
+		vm.Cleanup()
+		os.RemoveAll(tmpdir)
+	})
+	return vm
+}
+
+func BenchmarkCreate(b *testing.B) {
+	for _, path := range benchmarkContracts {
+		wasm, err := ioutil.ReadFile(path)
+		require.NoError(b, err)
+		b.Run(filepath.Base(path), func(b *testing.B) {
+			vm := withVM(b)
+			b.SetBytes(int64(len(wasm)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, err := vm.Create(wasm)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+// benchmarkCall is a contract with the messages used to benchmark its entry points
+type benchmarkCall struct {
+	name           string
+	path           string
+	instantiateMsg string
+	executeSender  string
+	executeMsg     string
+	queryMsg       string
+}
+
+var benchmarkCalls = []benchmarkCall{
+	{
+		name:           "hackatom",
+		path:           HACKATOM_TEST_CONTRACT,
+		instantiateMsg: `{"verifier": "fred", "beneficiary": "bob"}`,
+		executeSender:  "fred",
+		executeMsg:     `{"release":{}}`,
+		queryMsg:       `{"verifier":{}}`,
+	},
+	{
+		name:           "queue",
+		path:           QUEUE_TEST_CONTRACT,
+		instantiateMsg: `{}`,
+		executeSender:  "creator",
+		executeMsg:     `{"enqueue":{"value":17}}`,
+		queryMsg:       `{"sum":{}}`,
+	},
+	{
+		name:           "reflect",
+		path:           "./testdata/reflect.wasm",
+		instantiateMsg: `{}`,
+		executeSender:  "creator",
+		executeMsg:     `{"reflect_msg":{"msgs":[{"bank":{"send":{"to_address":"friend","amount":[{"denom":"ATOM","amount":"10"}]}}}]}}`,
+		queryMsg:       `{"owner":{}}`,
+	},
+	{
+		name:           "cyberpunk",
+		path:           CYBERPUNK_TEST_CONTRACT,
+		instantiateMsg: `{}`,
+		executeSender:  "creator",
+		executeMsg:     `{"mirror_env":{}}`,
+		queryMsg:       `{"mirror_env":{}}`,
+	},
+}
+
+func BenchmarkInstantiate(b *testing.B) {
+	cases := map[string]struct {
+		cacheSize uint32
+		pin       bool
+	}{
+		// every instance is loaded from the file system cache
+		"cold": {cacheSize: 0},
+		// instances are created from the in-memory cache after the first call
+		"memory_cache": {cacheSize: TESTING_CACHE_SIZE},
+		"pinned":       {cacheSize: TESTING_CACHE_SIZE, pin: true},
+	}
+	for _, name := range []string{"cold", "memory_cache", "pinned"} {
+		tc := cases[name]
+		b.Run(name, func(b *testing.B) {
+			for _, bc := range benchmarkCalls {
+				b.Run(bc.name, func(b *testing.B) {
+					vm := withVMCacheSize(b, tc.cacheSize)
+					checksum := createTestContract(b, vm, bc.path)
+					if tc.pin {
+						require.NoError(b, vm.Pin(checksum))
+					}
+					gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+					store := api.NewLookup(gasMeter)
+					goapi := api.NewMockAPI()
+					querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+					env := api.MockEnv()
+					info := api.MockInfo("creator", nil)
+					msg := []byte(bc.instantiateMsg)
+					deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					for n := 0; n < b.N; n++ {
+						_, _, err := vm.Instantiate(checksum, env, info, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+						require.NoError(b, err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// instantiateBenchmarkContract returns a store with an instantiated contract of bc
+func instantiateBenchmarkContract(b *testing.B, vm *VM, checksum Checksum, bc benchmarkCall) (*api.Lookup, api.MockGasMeter) {
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	msg := []byte(bc.instantiateMsg)
+	_, _, err := vm.Instantiate(checksum, api.MockEnv(), api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, types.UFraction{Numerator: 1, Denominator: 1})
+	require.NoError(b, err)
+	return store, gasMeter
+}
+
+func BenchmarkExecute(b *testing.B) {
+	for _, bc := range benchmarkCalls {
+		b.Run(bc.name, func(b *testing.B) {
+			vm := withVM(b)
+			checksum := createTestContract(b, vm, bc.path)
+			store, gasMeter := instantiateBenchmarkContract(b, vm, checksum, bc)
+			goapi := api.NewMockAPI()
+			querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, types.Coins{types.NewCoin(250, "ATOM")})
+			env := api.MockEnv()
+			info := api.MockInfo(bc.executeSender, nil)
+			msg := []byte(bc.executeMsg)
+			deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, _, err := vm.Execute(checksum, env, info, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	for _, bc := range benchmarkCalls {
+		b.Run(bc.name, func(b *testing.B) {
+			vm := withVM(b)
+			checksum := createTestContract(b, vm, bc.path)
+			store, gasMeter := instantiateBenchmarkContract(b, vm, checksum, bc)
+			goapi := api.NewMockAPI()
+			querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+			env := api.MockEnv()
+			msg := []byte(bc.queryMsg)
+			deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, _, err := vm.Query(checksum, env, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkIBC(b *testing.B) {
+	const channelID = "channel-432"
+	vm := withVM(b)
+	checksum := createTestContract(b, vm, IBC_TEST_CONTRACT)
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	env := api.MockEnv()
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	initMsg := []byte(`{"reflect_code_id": 101}`)
+	_, _, err := vm.Instantiate(checksum, env, api.MockInfo("creator", nil), initMsg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(b, err)
+
+	b.Run("channel_open", func(b *testing.B) {
+		msg := api.MockIBCChannelOpenInit(channelID, types.Ordered, IBC_VERSION)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			_, _, err := vm.IBCChannelOpen(checksum, env, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+			require.NoError(b, err)
+		}
+	})
+	b.Run("channel_connect", func(b *testing.B) {
+		msg := api.MockIBCChannelConnectAck(channelID, types.Ordered, IBC_VERSION)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			_, _, err := vm.IBCChannelConnect(checksum, env, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+			require.NoError(b, err)
+		}
+	})
+	b.Run("packet_receive", func(b *testing.B) {
+		// the channel has no reflect account, so the contract acknowledges the packet with an error
+		msg := api.MockIBCPacketReceive(channelID, []byte(`{"who_am_i":{}}`))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			_, _, err := vm.IBCPacketReceive(checksum, env, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+			require.NoError(b, err)
+		}
+	})
+	b.Run("channel_close", func(b *testing.B) {
+		msg := api.MockIBCChannelCloseInit(channelID, types.Ordered, IBC_VERSION)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			_, _, err := vm.IBCChannelClose(checksum, env, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+			require.NoError(b, err)
+		}
+	})
+}
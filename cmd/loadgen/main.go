@@ -0,0 +1,246 @@
+// Command loadgen drives concurrent callers against a contract for a fixed duration and reports
+// throughput, latency percentiles, gas per second and the cache metrics of the VM.
+//
+// Every caller instantiates its own instance of the contract with a private in-memory store, so
+// the callers only contend inside the VM.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	wasmvm "github.com/line/wasmvm"
+	"github.com/line/wasmvm/types"
+)
+
+const (
+	SUPPORTED_FEATURES = "staking,stargate,iterator"
+	MEMORY_LIMIT       = 32  // MiB
+	CACHE_SIZE         = 100 // MiB
+	DEFAULT_GAS_LIMIT  = 100_000_000_000
+)
+
+var deserCost = types.UFraction{Numerator: 1, Denominator: 1}
+
+type config struct {
+	features    string
+	cacheSize   uint
+	pin         bool
+	concurrency int
+	duration    time.Duration
+	gasLimit    uint64
+	sender      string
+	initMsg     string
+	executeMsg  string
+	queryMsg    string
+}
+
+func main() {
+	var c config
+	flag.StringVar(&c.features, "features", SUPPORTED_FEATURES, "comma separated list of features supported by the chain")
+	flag.UintVar(&c.cacheSize, "cache", CACHE_SIZE, "size of the in-memory cache in MiB, 0 loads every instance from the file system cache")
+	flag.BoolVar(&c.pin, "pin", false, "pin the contract before the run")
+	flag.IntVar(&c.concurrency, "c", 4, "number of concurrent callers")
+	flag.DurationVar(&c.duration, "d", 10*time.Second, "duration of the run")
+	flag.Uint64Var(&c.gasLimit, "gas", DEFAULT_GAS_LIMIT, "gas limit of every call")
+	flag.StringVar(&c.sender, "sender", "loadgen", "sender of instantiate and execute")
+	flag.StringVar(&c.initMsg, "init", "{}", "instantiate message of every caller's contract instance")
+	flag.StringVar(&c.executeMsg, "execute", "", "execute message sent in a loop")
+	flag.StringVar(&c.queryMsg, "query", "", "query message sent in a loop, instead of -execute")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] CONTRACT.wasm\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || c.concurrency < 1 || (c.executeMsg == "") == (c.queryMsg == "") {
+		fmt.Fprintln(flag.CommandLine.Output(), "exactly one contract, one of -execute and -query and at least one caller are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(c, flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(c config, path string) error {
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "wasmvm-loadgen")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	vm, err := wasmvm.NewVM(dir, c.features, MEMORY_LIMIT, false, uint32(c.cacheSize))
+	if err != nil {
+		return err
+	}
+	defer vm.Cleanup()
+
+	checksum, err := vm.Create(code)
+	if err != nil {
+		return err
+	}
+	if c.pin {
+		if err := vm.Pin(checksum); err != nil {
+			return err
+		}
+	}
+
+	callers := make([]*caller, c.concurrency)
+	for i := range callers {
+		callers[i], err = newCaller(vm, checksum, c, i)
+		if err != nil {
+			return fmt.Errorf("caller %d: %w", i, err)
+		}
+	}
+
+	deadline := time.Now().Add(c.duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, cl := range callers {
+		wg.Add(1)
+		go func(cl *caller) {
+			defer wg.Done()
+			cl.loop(deadline)
+		}(cl)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	metrics, err := vm.GetMetrics()
+	if err != nil {
+		return err
+	}
+	report(c, callers, elapsed, metrics)
+	return nil
+}
+
+// caller makes calls on its own contract instance
+type caller struct {
+	vm       *wasmvm.VM
+	checksum wasmvm.Checksum
+	c        config
+	env      types.Env
+	store    wasmvm.KVStore
+
+	latencies []time.Duration
+	gasUsed   uint64
+	errors    int
+	lastErr   error
+}
+
+func newCaller(vm *wasmvm.VM, checksum wasmvm.Checksum, c config, index int) (*caller, error) {
+	cl := &caller{
+		vm:       vm,
+		checksum: checksum,
+		c:        c,
+		env: types.Env{
+			Block:       types.BlockInfo{Height: 1, Time: uint64(time.Now().UnixNano()), ChainID: "loadgen"},
+			Transaction: &types.TransactionInfo{Index: 0},
+			Contract:    types.ContractInfo{Address: fmt.Sprintf("contract%d", index+1)},
+		},
+		store: wasmvm.AdaptKVStoreWithErrors(wasmvm.NewMemDB()),
+	}
+	info := types.MessageInfo{Sender: c.sender, Funds: types.Coins{}}
+	_, _, err := vm.Instantiate(checksum, cl.env, info, []byte(c.initMsg), cl.store, goAPI(), querier{}, wasmvm.NewInfiniteGasMeter(), c.gasLimit, deserCost)
+	return cl, err
+}
+
+func (cl *caller) loop(deadline time.Time) {
+	info := types.MessageInfo{Sender: cl.c.sender, Funds: types.Coins{}}
+	for time.Now().Before(deadline) {
+		gasMeter := wasmvm.NewInfiniteGasMeter()
+		var gasUsed uint64
+		var err error
+		start := time.Now()
+		if cl.c.queryMsg != "" {
+			_, gasUsed, err = cl.vm.Query(cl.checksum, cl.env, []byte(cl.c.queryMsg), cl.store, goAPI(), querier{}, gasMeter, cl.c.gasLimit, deserCost)
+		} else {
+			_, gasUsed, err = cl.vm.Execute(cl.checksum, cl.env, info, []byte(cl.c.executeMsg), cl.store, goAPI(), querier{}, gasMeter, cl.c.gasLimit, deserCost)
+		}
+		cl.latencies = append(cl.latencies, time.Since(start))
+		cl.gasUsed += gasUsed + gasMeter.GasConsumed()
+		if err != nil {
+			cl.errors++
+			cl.lastErr = err
+		}
+		cl.env.Block.Height++
+	}
+}
+
+func report(c config, callers []*caller, elapsed time.Duration, metrics *types.Metrics) {
+	var latencies []time.Duration
+	var gasUsed uint64
+	var failed int
+	var lastErr error
+	for _, cl := range callers {
+		latencies = append(latencies, cl.latencies...)
+		gasUsed += cl.gasUsed
+		failed += cl.errors
+		if cl.lastErr != nil {
+			lastErr = cl.lastErr
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	seconds := elapsed.Seconds()
+
+	fmt.Printf("callers:     %d\n", c.concurrency)
+	fmt.Printf("duration:    %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("calls:       %d (%d failed)\n", len(latencies), failed)
+	if lastErr != nil {
+		fmt.Printf("last error:  %s\n", lastErr)
+	}
+	fmt.Printf("throughput:  %.1f calls/s\n", float64(len(latencies))/seconds)
+	fmt.Printf("gas:         %.0f gas/s\n", float64(gasUsed)/seconds)
+	if len(latencies) != 0 {
+		fmt.Printf("latency:     p50 %s  p90 %s  p99 %s  max %s\n",
+			percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99), latencies[len(latencies)-1])
+	}
+	fmt.Printf("cache hits:  pinned %d  memory %d  fs %d  misses %d\n",
+		metrics.HitsPinnedMemoryCache, metrics.HitsMemoryCache, metrics.HitsFsCache, metrics.Misses)
+	fmt.Printf("cache size:  pinned %d elements (%d bytes)  memory %d elements (%d bytes)\n",
+		metrics.ElementsPinnedMemoryCache, metrics.SizePinnedMemoryCache, metrics.ElementsMemoryCache, metrics.SizeMemoryCache)
+}
+
+// percentile returns the p-th percentile of sorted latencies using the nearest rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// querier answers bank queries with empty balances
+type querier struct{}
+
+var _ wasmvm.Querier = querier{}
+
+func (querier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	switch {
+	case request.Bank != nil && request.Bank.AllBalances != nil:
+		return []byte(`{"amount":[]}`), nil
+	case request.Bank != nil && request.Bank.Balance != nil:
+		return []byte(fmt.Sprintf(`{"amount":{"denom":%q,"amount":"0"}}`, request.Bank.Balance.Denom)), nil
+	default:
+		return nil, types.UnsupportedRequest{Kind: "only bank balance queries are supported"}
+	}
+}
+
+func (querier) GasConsumed() uint64 {
+	return 0
+}
+
+// goAPI uses the address bytes as canonical address
+func goAPI() wasmvm.GoAPI {
+	return wasmvm.NewPlainGoAPI(0, 0)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	wasmvm "github.com/line/wasmvm"
+)
+
+const (
+	SUPPORTED_FEATURES = "staking,stargate,iterator"
+	MEMORY_LIMIT       = 32  // MiB
+	CACHE_SIZE         = 100 // MiB
+)
+
+// replay re-runs calls recorded with VM.SetCallRecorder and reports whether the results are identical
+func main() {
+	features := flag.String("features", SUPPORTED_FEATURES, "comma separated list of features supported by the chain")
+	printDebug := flag.Bool("debug", false, "print debug logs of the contract")
+	codeFiles := flag.String("code", "", "comma separated list of the wasm files of the recorded contracts")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -code CONTRACT.wasm[,...] [flags] RECORD...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir, err := ioutil.TempDir("", "wasmvm-replay")
+	if err != nil {
+		fail(err)
+	}
+	defer os.RemoveAll(dir)
+	vm, err := wasmvm.NewVM(dir, *features, MEMORY_LIMIT, *printDebug, CACHE_SIZE)
+	if err != nil {
+		fail(err)
+	}
+	defer vm.Cleanup()
+	// records only contain the checksum of the code
+	for _, file := range strings.Split(*codeFiles, ",") {
+		if file == "" {
+			continue
+		}
+		code, err := ioutil.ReadFile(file)
+		if err != nil {
+			fail(err)
+		}
+		if _, err := vm.Create(code); err != nil {
+			fail(fmt.Errorf("cannot store %s: %w", file, err))
+		}
+	}
+
+	differs := false
+	for _, file := range flag.Args() {
+		record, err := wasmvm.ReadCallRecord(file)
+		if err != nil {
+			fail(err)
+		}
+		res, err := vm.Replay(record)
+		if err != nil {
+			fmt.Printf("%s: %s %X: %v\n", file, record.Entry, record.Checksum, err)
+			differs = true
+			continue
+		}
+		if res.Identical {
+			fmt.Printf("%s: %s %X: identical (gas used %d)\n", file, record.Entry, record.Checksum, res.GasUsed)
+			continue
+		}
+		differs = true
+		fmt.Printf("%s: %s %X: differs\n", file, record.Entry, record.Checksum)
+		fmt.Printf("  recorded: gas used %d, error %q, result %s\n", record.GasUsed, record.Err, record.Result)
+		fmt.Printf("  replayed: gas used %d, error %q, result %s\n", res.GasUsed, res.Err, res.Result)
+	}
+	if differs {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/line/wasmvm/types"
+)
+
+const (
+	defaultChainID = "wasmvm-local"
+	defaultHeight  = 1
+	defaultTime    = "2022-01-01T00:00:00Z"
+)
+
+// envFlags are the flags that set the Env of a call
+type envFlags struct {
+	height  uint64
+	time    string
+	chainID string
+	txIndex int64
+}
+
+func (f *envFlags) register(fs *flag.FlagSet) {
+	fs.Uint64Var(&f.height, "height", defaultHeight, "block height")
+	fs.StringVar(&f.time, "time", defaultTime, "block time, RFC 3339 or nanoseconds since the epoch")
+	fs.StringVar(&f.chainID, "chain-id", defaultChainID, "chain ID")
+	fs.Int64Var(&f.txIndex, "tx-index", 0, "index of the transaction in the block, -1 for none")
+}
+
+func (f *envFlags) env(contract string) (types.Env, error) {
+	if f.txIndex < -1 || f.txIndex > math.MaxUint32 {
+		return types.Env{}, fmt.Errorf("invalid tx-index %d: use -1 for none or 0 to %d", f.txIndex, uint32(math.MaxUint32))
+	}
+	blockTime, err := parseTime(f.time)
+	if err != nil {
+		return types.Env{}, err
+	}
+	env := types.Env{
+		Block: types.BlockInfo{
+			Height:  f.height,
+			Time:    blockTime,
+			ChainID: f.chainID,
+		},
+		Contract: types.ContractInfo{Address: contract},
+	}
+	if f.txIndex >= 0 {
+		env.Transaction = &types.TransactionInfo{Index: uint32(f.txIndex)}
+	}
+	return env, nil
+}
+
+// parseTime returns the nanoseconds since the epoch
+func parseTime(s string) (uint64, error) {
+	if nanos, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return nanos, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: use RFC 3339 or nanoseconds since the epoch", s)
+	}
+	if t.Before(time.Unix(0, 0)) {
+		return 0, fmt.Errorf("time %q is before the epoch", s)
+	}
+	return uint64(t.UnixNano()), nil
+}
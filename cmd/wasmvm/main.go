@@ -0,0 +1,490 @@
+// Command wasmvm runs contracts with this build of the VM against a local file based state,
+// such that contract developers can exercise their code without a chain.
+//
+// The state (contract storage, contract metadata and bank balances) is stored with goleveldb
+// in the home directory. Messages returned by contracts are printed but not dispatched.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	wasmvm "github.com/line/wasmvm"
+	"github.com/line/wasmvm/scenario"
+	"github.com/line/wasmvm/types"
+)
+
+const (
+	SUPPORTED_FEATURES = "staking,stargate,iterator"
+	MEMORY_LIMIT       = 32  // MiB
+	CACHE_SIZE         = 100 // MiB
+	DEFAULT_GAS_LIMIT  = 100_000_000_000
+)
+
+// deserCost is the gas cost per byte of the contract results
+var deserCost = types.UFraction{Numerator: 1, Denominator: 1}
+
+// command is a subcommand. args are the positional arguments after the flags, -1 means at least one.
+type command struct {
+	usage       string
+	description string
+	args        int
+	run         func(c *cli, args []string) error
+	// offline commands do not open the VM and the state
+	offline bool
+}
+
+var commands = map[string]command{
+	"store":       {"FILE", "store Wasm code and print its checksum and code ID", 1, (*cli).store, false},
+	"checksum":    {"FILE", "print the checksum of Wasm code without storing it", 1, (*cli).checksum, true},
+	"analyze":     {"CHECKSUM", "print the analysis report of stored code", 1, (*cli).analyze, false},
+	"pin":         {"CHECKSUM", "pin stored code in the in-memory cache of this process", 1, (*cli).pin, false},
+	"unpin":       {"CHECKSUM", "unpin stored code", 1, (*cli).unpin, false},
+	"instantiate": {"CHECKSUM MSG", "instantiate a contract and print its address and the response", 2, (*cli).instantiate, false},
+	"execute":     {"ADDRESS MSG", "execute a message on a contract", 2, (*cli).execute, false},
+	"query":       {"ADDRESS MSG", "query a contract", 2, (*cli).query, false},
+	"migrate":     {"ADDRESS CHECKSUM MSG", "migrate a contract to new code", 3, (*cli).migrate, false},
+	"sudo":        {"ADDRESS MSG", "call the sudo entry point of a contract", 2, (*cli).sudo, false},
+	"scenario":    {"FILE...", "run scenario files on a fresh in-memory state", -1, (*cli).scenario, true},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s COMMAND [flags] ARGS...\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, name := range []string{"store", "checksum", "analyze", "pin", "unpin", "instantiate", "execute", "query", "migrate", "sudo", "scenario"} {
+		cmd := commands[name]
+		fmt.Fprintf(os.Stderr, "  %-12s %-22s %s\n", name, cmd.usage, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s COMMAND -h' for the flags of a command.\n", filepath.Base(os.Args[0]))
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	c := &cli{}
+	fs := c.flags(os.Args[1])
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", filepath.Base(os.Args[0]), os.Args[1], cmd.usage, cmd.description)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		os.Exit(2)
+	}
+	if fs.NArg() != cmd.args && !(cmd.args == -1 && fs.NArg() > 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if cmd.offline {
+		if err := cmd.run(c, fs.Args()); err != nil {
+			fail(err)
+		}
+		return
+	}
+	if err := c.open(); err != nil {
+		fail(err)
+	}
+	err := cmd.run(c, fs.Args())
+	c.close()
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
+
+// cli holds the flags and the opened VM and state
+type cli struct {
+	home       string
+	features   string
+	printDebug bool
+	dump       bool
+	gasLimit   uint64
+	env        envFlags
+	sender     string
+	funds      string
+	label      string
+	admin      string
+
+	vm    *wasmvm.VM
+	state *state
+}
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&c.home, "home", ".wasmvm", "directory of the code cache and the state")
+	fs.StringVar(&c.features, "features", SUPPORTED_FEATURES, "comma separated list of features supported by the chain")
+	fs.BoolVar(&c.printDebug, "debug", false, "print debug logs of the contracts")
+	fs.BoolVar(&c.dump, "dump", false, "log all data passed between Go and the VM, except code")
+	fs.Uint64Var(&c.gasLimit, "gas", DEFAULT_GAS_LIMIT, "gas limit of the call")
+	c.env.register(fs)
+	switch name {
+	case "instantiate", "execute":
+		fs.StringVar(&c.sender, "sender", "creator", "address of the message sender")
+		fs.StringVar(&c.funds, "funds", "", "funds sent with the message, e.g. 100ucosm,5uatom")
+	case "migrate":
+		fs.StringVar(&c.sender, "sender", "creator", "address of the message sender, must be the admin of the contract")
+	}
+	if name == "instantiate" {
+		fs.StringVar(&c.label, "label", "", "label of the contract")
+		fs.StringVar(&c.admin, "admin", "", "admin that can migrate the contract")
+	}
+	return fs
+}
+
+func (c *cli) open() error {
+	if err := os.MkdirAll(c.home, 0o755); err != nil {
+		return err
+	}
+	if c.dump {
+		wasmvm.SetPayloadDump(&wasmvm.PayloadDumpOptions{
+			Sink:   wasmvm.LogPayload,
+			Redact: []wasmvm.RedactionRule{{Name: "wasm"}, {Name: "code"}},
+		})
+	}
+	vm, err := wasmvm.NewVM(filepath.Join(c.home, "cache"), c.features, MEMORY_LIMIT, c.printDebug, CACHE_SIZE)
+	if err != nil {
+		return err
+	}
+	st, err := openState(c.home, vm)
+	if err != nil {
+		vm.Cleanup()
+		return err
+	}
+	c.vm, c.state = vm, st
+	return nil
+}
+
+func (c *cli) close() {
+	c.state.Close()
+	c.vm.Cleanup()
+}
+
+func parseChecksum(s string) (wasmvm.Checksum, error) {
+	checksum, err := hex.DecodeString(s)
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, fmt.Errorf("invalid checksum %q: must be 64 hex characters", s)
+	}
+	return checksum, nil
+}
+
+// parseMsg checks that msg is JSON, such that typos are reported before calling the contract
+func parseMsg(msg string) ([]byte, error) {
+	if !json.Valid([]byte(msg)) {
+		return nil, fmt.Errorf("message is not valid JSON: %s", msg)
+	}
+	return []byte(msg), nil
+}
+
+func printJSON(v interface{}) error {
+	bz, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(bz))
+	return nil
+}
+
+func (c *cli) store(args []string) error {
+	code, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	checksum, err := c.vm.Create(code)
+	if err != nil {
+		return err
+	}
+	id, err := c.state.codeID(checksum)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("checksum: %X\ncode id: %d\n", checksum, id)
+	return nil
+}
+
+func (c *cli) checksum(args []string) error {
+	code, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%X\n", sha256.Sum256(code))
+	return nil
+}
+
+func (c *cli) analyze(args []string) error {
+	checksum, err := parseChecksum(args[0])
+	if err != nil {
+		return err
+	}
+	report, err := c.vm.AnalyzeCode(checksum)
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+func (c *cli) pin(args []string) error {
+	checksum, err := parseChecksum(args[0])
+	if err != nil {
+		return err
+	}
+	if err := c.vm.Pin(checksum); err != nil {
+		return err
+	}
+	metrics, err := c.vm.GetMetrics()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pinned, %d pinned modules in this process\n", metrics.ElementsPinnedMemoryCache)
+	return nil
+}
+
+func (c *cli) unpin(args []string) error {
+	checksum, err := parseChecksum(args[0])
+	if err != nil {
+		return err
+	}
+	if err := c.vm.Unpin(checksum); err != nil {
+		return err
+	}
+	fmt.Println("unpinned")
+	return nil
+}
+
+// call holds what all contract calls need. The contract writes to an overlay, which is only
+// committed together with the funds if the call succeeds.
+type call struct {
+	address  string
+	env      types.Env
+	overlay  *wasmvm.OverlayStore
+	store    wasmvm.KVStore
+	querier  *querier
+	gasMeter wasmvm.FullGasMeter
+	funds    types.Coins
+}
+
+func (c *cli) newCall(address string, funds types.Coins) (*call, error) {
+	env, err := c.env.env(address)
+	if err != nil {
+		return nil, err
+	}
+	gasMeter := wasmvm.NewGasMeter(c.gasLimit)
+	overlay := wasmvm.NewOverlayStore(c.state.contractStore(address))
+	return &call{
+		address:  address,
+		env:      env,
+		overlay:  overlay,
+		store:    wasmvm.NewGasKVStore(overlay, gasMeter, wasmvm.DefaultKVGasConfig()),
+		querier:  &querier{state: c.state, gasMeter: gasMeter, block: env.Block, credit: map[string]types.Coins{address: funds}},
+		gasMeter: gasMeter,
+		funds:    funds,
+	}, nil
+}
+
+// commit applies the writes and funds of the call and the contract metadata c, if not nil, to the state
+func (cl *call) commit(s *state, c *contract) error {
+	return s.commit(cl.address, cl.overlay.Writes(), cl.funds, c)
+}
+
+func (c *cli) info() (types.MessageInfo, error) {
+	funds, err := types.ParseCoins(c.funds)
+	if err != nil {
+		return types.MessageInfo{}, err
+	}
+	if err := funds.ValidateForContracts(); err != nil {
+		return types.MessageInfo{}, err
+	}
+	return types.MessageInfo{Sender: c.sender, Funds: funds}, nil
+}
+
+// printResult prints the outcome of a call. The gas used is the gas reported by the VM,
+// the storage gas is consumed on the gas meter.
+func printResult(res interface{}, gasUsed uint64, gasMeter wasmvm.FullGasMeter, err error) error {
+	fmt.Printf("gas used: %d (VM), %d (storage and queries)\n", gasUsed, gasMeter.GasConsumed())
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *cli) instantiate(args []string) error {
+	checksum, err := parseChecksum(args[0])
+	if err != nil {
+		return err
+	}
+	msg, err := parseMsg(args[1])
+	if err != nil {
+		return err
+	}
+	codeID, err := c.state.codeID(checksum)
+	if err != nil {
+		return err
+	}
+	info, err := c.info()
+	if err != nil {
+		return err
+	}
+	address, err := c.state.newContractAddress()
+	if err != nil {
+		return err
+	}
+	cl, err := c.newCall(address, info.Funds)
+	if err != nil {
+		return err
+	}
+	res, gasUsed, err := c.vm.Instantiate(checksum, cl.env, info, msg, cl.store, goAPI(), cl.querier, cl.gasMeter, c.gasLimit, deserCost)
+	if err == nil {
+		err = cl.commit(c.state, &contract{CodeID: codeID, Checksum: checksum, Creator: info.Sender, Admin: c.admin, Label: c.label})
+	}
+	if err == nil {
+		fmt.Printf("address: %s\n", address)
+	}
+	return printResult(res, gasUsed, cl.gasMeter, err)
+}
+
+func (c *cli) execute(args []string) error {
+	address := args[0]
+	ct, err := c.state.contract(address)
+	if err != nil {
+		return err
+	}
+	msg, err := parseMsg(args[1])
+	if err != nil {
+		return err
+	}
+	info, err := c.info()
+	if err != nil {
+		return err
+	}
+	cl, err := c.newCall(address, info.Funds)
+	if err != nil {
+		return err
+	}
+	res, gasUsed, err := c.vm.Execute(ct.Checksum, cl.env, info, msg, cl.store, goAPI(), cl.querier, cl.gasMeter, c.gasLimit, deserCost)
+	if err == nil {
+		err = cl.commit(c.state, nil)
+	}
+	return printResult(res, gasUsed, cl.gasMeter, err)
+}
+
+func (c *cli) query(args []string) error {
+	address := args[0]
+	ct, err := c.state.contract(address)
+	if err != nil {
+		return err
+	}
+	msg, err := parseMsg(args[1])
+	if err != nil {
+		return err
+	}
+	cl, err := c.newCall(address, nil)
+	if err != nil {
+		return err
+	}
+	res, gasUsed, err := c.vm.Query(ct.Checksum, cl.env, msg, cl.store, goAPI(), cl.querier, cl.gasMeter, c.gasLimit, deserCost)
+	// the query result is arbitrary JSON defined by the contract
+	return printResult(json.RawMessage(res), gasUsed, cl.gasMeter, err)
+}
+
+func (c *cli) migrate(args []string) error {
+	address := args[0]
+	ct, err := c.state.contract(address)
+	if err != nil {
+		return err
+	}
+	if ct.Admin == "" || ct.Admin != c.sender {
+		return errors.New("only the admin of the contract can migrate it")
+	}
+	checksum, err := parseChecksum(args[1])
+	if err != nil {
+		return err
+	}
+	msg, err := parseMsg(args[2])
+	if err != nil {
+		return err
+	}
+	codeID, err := c.state.codeID(checksum)
+	if err != nil {
+		return err
+	}
+	cl, err := c.newCall(address, nil)
+	if err != nil {
+		return err
+	}
+	res, gasUsed, err := c.vm.Migrate(checksum, cl.env, msg, cl.store, goAPI(), cl.querier, cl.gasMeter, c.gasLimit, deserCost)
+	if err == nil {
+		ct.CodeID, ct.Checksum = codeID, checksum
+		err = cl.commit(c.state, ct)
+	}
+	return printResult(res, gasUsed, cl.gasMeter, err)
+}
+
+func (c *cli) sudo(args []string) error {
+	address := args[0]
+	ct, err := c.state.contract(address)
+	if err != nil {
+		return err
+	}
+	msg, err := parseMsg(args[1])
+	if err != nil {
+		return err
+	}
+	cl, err := c.newCall(address, nil)
+	if err != nil {
+		return err
+	}
+	res, gasUsed, err := c.vm.Sudo(ct.Checksum, cl.env, msg, cl.store, goAPI(), cl.querier, cl.gasMeter, c.gasLimit, deserCost)
+	if err == nil {
+		err = cl.commit(c.state, nil)
+	}
+	return printResult(res, gasUsed, cl.gasMeter, err)
+}
+
+// scenario runs scenario files with a VM using the code cache of the home directory, but not its state
+func (c *cli) scenario(args []string) error {
+	if err := os.MkdirAll(c.home, 0o755); err != nil {
+		return err
+	}
+	vm, err := wasmvm.NewVM(filepath.Join(c.home, "cache"), c.features, MEMORY_LIMIT, c.printDebug, CACHE_SIZE)
+	if err != nil {
+		return err
+	}
+	defer vm.Cleanup()
+
+	runner := scenario.NewRunner(vm)
+	runner.GasLimit = c.gasLimit
+	runner.DeserCost = deserCost
+	failed := 0
+	for _, path := range args {
+		s, err := scenario.Load(path)
+		if err != nil {
+			return err
+		}
+		res := runner.Run(s)
+		fmt.Print(res)
+		if !res.Passed() {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	dbm "github.com/tendermint/tm-db"
+
+	wasmvm "github.com/line/wasmvm"
+	"github.com/line/wasmvm/tmdb"
+	"github.com/line/wasmvm/types"
+)
+
+// Key prefixes of the state database
+var (
+	prefixContractStore = []byte("s/")
+	prefixContract      = []byte("c/")
+	prefixCode          = []byte("k/")
+	prefixBalance       = []byte("b/")
+	keyContractSequence = []byte("seq/contract")
+	keyCodeSequence     = []byte("seq/code")
+)
+
+// contract is the metadata of an instantiated contract
+type contract struct {
+	CodeID   uint64          `json:"code_id"`
+	Checksum wasmvm.Checksum `json:"checksum"`
+	Creator  string          `json:"creator"`
+	Admin    string          `json:"admin,omitempty"`
+	Label    string          `json:"label,omitempty"`
+}
+
+// state is the chain state of the CLI, stored in a goleveldb database
+type state struct {
+	db dbm.DB
+	vm *wasmvm.VM
+}
+
+func openState(home string, vm *wasmvm.VM) (*state, error) {
+	db, err := dbm.NewGoLevelDB("state", filepath.Join(home, "data"))
+	if err != nil {
+		return nil, err
+	}
+	return &state{db: db, vm: vm}, nil
+}
+
+func (s *state) Close() error {
+	return s.db.Close()
+}
+
+func prefixed(prefix []byte, key string) []byte {
+	return append(append([]byte{}, prefix...), key...)
+}
+
+// nextSequence increments the counter at key and returns the new value
+func (s *state) nextSequence(key []byte) (uint64, error) {
+	bz, err := s.db.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	var seq uint64
+	if bz != nil {
+		seq = binary.BigEndian.Uint64(bz)
+	}
+	seq++
+	bz = make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, seq)
+	return seq, s.db.Set(key, bz)
+}
+
+// codeID returns the code ID of checksum, assigning a new one for unknown code
+func (s *state) codeID(checksum wasmvm.Checksum) (uint64, error) {
+	key := prefixed(prefixCode, string(checksum))
+	bz, err := s.db.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if bz != nil {
+		return binary.BigEndian.Uint64(bz), nil
+	}
+	id, err := s.nextSequence(keyCodeSequence)
+	if err != nil {
+		return 0, err
+	}
+	bz = make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, id)
+	return id, s.db.Set(key, bz)
+}
+
+// newContractAddress returns a fresh contract address
+func (s *state) newContractAddress() (string, error) {
+	seq, err := s.nextSequence(keyContractSequence)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("contract%d", seq), nil
+}
+
+func (s *state) contract(address string) (*contract, error) {
+	bz, err := s.db.Get(prefixed(prefixContract, address))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, types.NoSuchContract{Addr: address}
+	}
+	var c contract
+	if err := json.Unmarshal(bz, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// contractStore returns the storage of a contract
+func (s *state) contractStore(address string) wasmvm.KVStore {
+	return wasmvm.AdaptKVStoreWithErrors(tmdb.NewDB(dbm.NewPrefixDB(s.db, contractStorePrefix(address))))
+}
+
+func contractStorePrefix(address string) []byte {
+	return prefixed(prefixContractStore, address+"/")
+}
+
+// meteredStore returns the storage of a contract metered with the SDK's gas costs
+func (s *state) meteredStore(address string, gasMeter wasmvm.FullGasMeter) wasmvm.KVStore {
+	return wasmvm.NewGasKVStore(s.contractStore(address), gasMeter, wasmvm.DefaultKVGasConfig())
+}
+
+// commit applies the writes of a successful call, credits the funds sent with it and stores the
+// contract metadata c if it is not nil. Everything is written in one batch, so either all of it or
+// nothing ends up in the database. Failed calls are discarded, like on chain.
+func (s *state) commit(address string, writes []wasmvm.StoreWrite, funds types.Coins, c *contract) error {
+	batch := s.db.NewBatch()
+	defer batch.Close()
+	prefix := contractStorePrefix(address)
+	for _, write := range writes {
+		var err error
+		if write.Delete {
+			err = batch.Delete(prefixed(prefix, string(write.Key)))
+		} else {
+			err = batch.Set(prefixed(prefix, string(write.Key)), write.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.addBalance(batch, address, funds); err != nil {
+		return err
+	}
+	if c != nil {
+		bz, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if err := batch.Set(prefixed(prefixContract, address), bz); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+func (s *state) balance(address string) (types.Coins, error) {
+	bz, err := s.db.Get(prefixed(prefixBalance, address))
+	if err != nil || bz == nil {
+		return types.Coins{}, err
+	}
+	var coins types.Coins
+	return coins, json.Unmarshal(bz, &coins)
+}
+
+// addBalance credits funds to address in batch. There are no accounts, so funds are not taken from the sender.
+func (s *state) addBalance(batch dbm.Batch, address string, funds types.Coins) error {
+	if len(funds) == 0 {
+		return nil
+	}
+	balance, err := s.balance(address)
+	if err != nil {
+		return err
+	}
+	balance, err = balance.Add(funds)
+	if err != nil {
+		return err
+	}
+	bz, err := json.Marshal(balance)
+	if err != nil {
+		return err
+	}
+	return batch.Set(prefixed(prefixBalance, address), bz)
+}
+
+// querier answers the bank and wasm queries of contracts from the state
+type querier struct {
+	state    *state
+	gasMeter wasmvm.FullGasMeter
+	// block is the block of the running call, which is also used for smart queries
+	block types.BlockInfo
+	// credit are the funds sent with the running call, which are only committed if it succeeds
+	credit map[string]types.Coins
+}
+
+// balance returns the balance of address including the funds sent with the running call
+func (q *querier) balance(address string) (types.Coins, error) {
+	balance, err := q.state.balance(address)
+	if err != nil || len(q.credit[address]) == 0 {
+		return balance, err
+	}
+	return balance.Add(q.credit[address])
+}
+
+var _ wasmvm.Querier = (*querier)(nil)
+
+func (q *querier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	switch {
+	case request.Bank != nil && request.Bank.AllBalances != nil:
+		balance, err := q.balance(request.Bank.AllBalances.Address)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(types.AllBalancesResponse{Amount: balance})
+	case request.Bank != nil && request.Bank.Balance != nil:
+		balance, err := q.balance(request.Bank.Balance.Address)
+		if err != nil {
+			return nil, err
+		}
+		res := types.BalanceResponse{Amount: types.NewCoin(0, request.Bank.Balance.Denom)}
+		for _, coin := range balance {
+			if coin.Denom == request.Bank.Balance.Denom {
+				res.Amount = coin
+			}
+		}
+		return json.Marshal(res)
+	case request.Wasm != nil && request.Wasm.Smart != nil:
+		c, err := q.state.contract(request.Wasm.Smart.ContractAddr)
+		if err != nil {
+			return nil, err
+		}
+		// queries are not associated with a transaction
+		env := types.Env{Block: q.block, Contract: types.ContractInfo{Address: request.Wasm.Smart.ContractAddr}}
+		// the gas used by the VM includes the storage costs, so the nested call gets its own meter
+		nestedMeter := wasmvm.NewGasMeter(gasLimit)
+		nested := &querier{state: q.state, gasMeter: nestedMeter, block: q.block, credit: q.credit}
+		store := q.state.meteredStore(request.Wasm.Smart.ContractAddr, nestedMeter)
+		res, gasUsed, err := q.state.vm.Query(c.Checksum, env, request.Wasm.Smart.Msg, store, goAPI(), nested, nestedMeter, gasLimit, deserCost)
+		q.gasMeter.ConsumeGas(gasUsed, "smart query")
+		return res, err
+	case request.Wasm != nil && request.Wasm.Raw != nil:
+		if _, err := q.state.contract(request.Wasm.Raw.ContractAddr); err != nil {
+			return nil, err
+		}
+		store := q.state.meteredStore(request.Wasm.Raw.ContractAddr, q.gasMeter)
+		return store.Get(request.Wasm.Raw.Key), nil
+	case request.Wasm != nil && request.Wasm.ContractInfo != nil:
+		c, err := q.state.contract(request.Wasm.ContractInfo.ContractAddr)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(types.ContractInfoResponse{CodeID: c.CodeID, Creator: c.Creator, Admin: c.Admin})
+	default:
+		return nil, types.UnsupportedRequest{Kind: "only bank balance and wasm queries are supported"}
+	}
+}
+
+func (q *querier) GasConsumed() uint64 {
+	return q.gasMeter.GasConsumed()
+}
+
+// Costs of the address API, like the ones of the SDK's mock API
+const (
+	costCanonicalize uint64 = 440
+	costHumanize     uint64 = 550
+)
+
+// goAPI uses the address bytes as canonical address, so every non-empty string up to 64 bytes is a valid address
+func goAPI() wasmvm.GoAPI {
+	return wasmvm.NewPlainGoAPI(costCanonicalize, costHumanize)
+}
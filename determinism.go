@@ -0,0 +1,278 @@
+package cosmwasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/line/wasmvm/types"
+)
+
+// DeterminismCall runs one contract call on vm with the given host environment and passes out to it, e.g.
+//
+//	func(vm *VM, store KVStore, goapi GoAPI, querier Querier, out *CallOutput) (*types.Response, uint64, error) {
+//		return vm.Execute(checksum, env, info, msg, store, goapi, querier, NewGasMeter(limit), limit, deserCost, out)
+//	}
+//
+// out receives the raw result bytes of the VM, which are compared. It is invoked once per VM and
+// must not share a gas meter between invocations.
+type DeterminismCall func(vm *VM, store KVStore, goapi GoAPI, querier Querier, out *CallOutput) (*types.Response, uint64, error)
+
+// Names of the host callbacks in HostCall
+const (
+	HostCallStorageRead         = "db_read"
+	HostCallStorageScan         = "db_scan"
+	HostCallStorageNext         = "db_next"
+	HostCallQuery               = "query"
+	HostCallHumanizeAddress     = "humanize_address"
+	HostCallCanonicalizeAddress = "canonicalize_address"
+)
+
+// HostCall is one answer of a host callback to the contract
+type HostCall struct {
+	// Callback is one of the HostCall* names
+	Callback string
+	// Args describes the arguments passed by the contract
+	Args string
+	// Output is the data returned to the contract
+	Output []byte
+	// Gas is the gas cost reported by the address API. It is 0 for other callbacks.
+	Gas uint64
+	// Err is the error message returned to the contract, if any
+	Err string
+}
+
+func (c HostCall) String() string {
+	s := fmt.Sprintf("%s(%s) = %q", c.Callback, c.Args, c.Output)
+	if c.Gas != 0 {
+		s += fmt.Sprintf(" (gas %d)", c.Gas)
+	}
+	if c.Err != "" {
+		s += fmt.Sprintf(" (error %q)", c.Err)
+	}
+	return s
+}
+
+func (c HostCall) equal(other HostCall) bool {
+	return c.Callback == other.Callback && c.Args == other.Args && bytes.Equal(c.Output, other.Output) &&
+		c.Gas == other.Gas && c.Err == other.Err
+}
+
+// DivergenceKind is the part of a call in which two runs differed
+type DivergenceKind string
+
+const (
+	DivergenceHostCall DivergenceKind = "host call"
+	DivergenceError    DivergenceKind = "error"
+	DivergenceResponse DivergenceKind = "response"
+	DivergenceGasUsed  DivergenceKind = "gas used"
+	DivergenceWrites   DivergenceKind = "writes"
+)
+
+// Divergence is the first difference CheckDeterminism found between the two runs of a call
+type Divergence struct {
+	Kind DivergenceKind
+	// Index is the position of the host call or write that differs. It is 0 for the other kinds.
+	Index int
+	// First and Second describe the value seen in the first and second run
+	First  string
+	Second string
+	// HostCalls are all host calls of the first and second run
+	HostCalls [2][]HostCall
+}
+
+func (d *Divergence) String() string {
+	if d.Kind == DivergenceHostCall || d.Kind == DivergenceWrites {
+		return fmt.Sprintf("%s #%d differs: %s vs. %s", d.Kind, d.Index, d.First, d.Second)
+	}
+	return fmt.Sprintf("%s differs: %s vs. %s", d.Kind, d.First, d.Second)
+}
+
+// CheckDeterminism runs call on two independent VMs, which must use separate cache directories and
+// have the contract code stored. Each run gets its own discardable overlay of store, so store is
+// never modified. The host calls, the error, the raw result bytes returned by the VM, the gas used
+// and the writes of both runs are compared in this order and the first difference is returned. A host call difference
+// points at a non-deterministic querier, address API or store and is the likely cause of all
+// later differences.
+//
+// nil is returned if both runs behaved identically.
+func CheckDeterminism(first, second *VM, call DeterminismCall, store KVStore, goapi GoAPI, querier Querier) (*Divergence, error) {
+	if first == nil || second == nil {
+		return nil, errors.New("two VMs are required")
+	}
+	if first == second {
+		return nil, errors.New("the VMs must be independent instances")
+	}
+
+	runs := [2]*determinismRun{
+		runForDeterminism(first, call, store, goapi, querier),
+		runForDeterminism(second, call, store, goapi, querier),
+	}
+	for _, run := range runs {
+		if run.err == "" && run.response == nil {
+			return nil, errors.New("the call did not pass its CallOutput to the VM")
+		}
+	}
+	return compareRuns(runs), nil
+}
+
+// determinismRun is the observed outcome of one run. response is the raw result returned by the VM.
+type determinismRun struct {
+	hostCalls []HostCall
+	err       string
+	response  []byte
+	gasUsed   uint64
+	writes    []StoreWrite
+}
+
+func runForDeterminism(vm *VM, call DeterminismCall, store KVStore, goapi GoAPI, querier Querier) *determinismRun {
+	trace := &hostTrace{}
+	overlay := NewOverlayStore(store)
+	traced := &tracingStore{KVStore: overlay, trace: trace}
+	out := &CallOutput{KeepResult: true}
+	_, gasUsed, err := call(vm, traced, trace.api(goapi), &tracingQuerier{querier: querier, trace: trace}, out)
+	return &determinismRun{
+		hostCalls: trace.calls,
+		err:       errString(err),
+		response:  out.Result,
+		gasUsed:   gasUsed,
+		writes:    overlay.Writes(),
+	}
+}
+
+func compareRuns(runs [2]*determinismRun) *Divergence {
+	diverge := func(kind DivergenceKind, index int, first, second string) *Divergence {
+		return &Divergence{
+			Kind:      kind,
+			Index:     index,
+			First:     first,
+			Second:    second,
+			HostCalls: [2][]HostCall{runs[0].hostCalls, runs[1].hostCalls},
+		}
+	}
+
+	a, b := runs[0], runs[1]
+	for i := 0; i < len(a.hostCalls) || i < len(b.hostCalls); i++ {
+		switch {
+		case i >= len(a.hostCalls):
+			return diverge(DivergenceHostCall, i, "<none>", b.hostCalls[i].String())
+		case i >= len(b.hostCalls):
+			return diverge(DivergenceHostCall, i, a.hostCalls[i].String(), "<none>")
+		case !a.hostCalls[i].equal(b.hostCalls[i]):
+			return diverge(DivergenceHostCall, i, a.hostCalls[i].String(), b.hostCalls[i].String())
+		}
+	}
+	if a.err != b.err {
+		return diverge(DivergenceError, 0, fmt.Sprintf("%q", a.err), fmt.Sprintf("%q", b.err))
+	}
+	if !bytes.Equal(a.response, b.response) {
+		return diverge(DivergenceResponse, 0, string(a.response), string(b.response))
+	}
+	if a.gasUsed != b.gasUsed {
+		return diverge(DivergenceGasUsed, 0, fmt.Sprint(a.gasUsed), fmt.Sprint(b.gasUsed))
+	}
+	for i := 0; i < len(a.writes) || i < len(b.writes); i++ {
+		switch {
+		case i >= len(a.writes):
+			return diverge(DivergenceWrites, i, "<none>", describeWrite(b.writes[i]))
+		case i >= len(b.writes):
+			return diverge(DivergenceWrites, i, describeWrite(a.writes[i]), "<none>")
+		case describeWrite(a.writes[i]) != describeWrite(b.writes[i]):
+			return diverge(DivergenceWrites, i, describeWrite(a.writes[i]), describeWrite(b.writes[i]))
+		}
+	}
+	return nil
+}
+
+func describeWrite(w StoreWrite) string {
+	if w.Delete {
+		return fmt.Sprintf("delete %x", w.Key)
+	}
+	return fmt.Sprintf("set %x = %q", w.Key, w.Value)
+}
+
+// hostTrace collects the host calls of one run
+type hostTrace struct {
+	calls []HostCall
+}
+
+func (t *hostTrace) add(call HostCall) {
+	t.calls = append(t.calls, call)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// api returns a GoAPI that records all results of goapi
+func (t *hostTrace) api(goapi GoAPI) GoAPI {
+	return GoAPI{
+		HumanAddress: func(canon []byte) (string, uint64, error) {
+			human, gas, err := goapi.HumanAddress(canon)
+			t.add(HostCall{Callback: HostCallHumanizeAddress, Args: fmt.Sprintf("%x", canon), Output: []byte(human), Gas: gas, Err: errString(err)})
+			return human, gas, err
+		},
+		CanonicalAddress: func(human string) ([]byte, uint64, error) {
+			canon, gas, err := goapi.CanonicalAddress(human)
+			t.add(HostCall{Callback: HostCallCanonicalizeAddress, Args: fmt.Sprintf("%q", human), Output: canon, Gas: gas, Err: errString(err)})
+			return canon, gas, err
+		},
+	}
+}
+
+// tracingQuerier records all query results
+type tracingQuerier struct {
+	querier Querier
+	trace   *hostTrace
+}
+
+var _ Querier = (*tracingQuerier)(nil)
+
+func (q *tracingQuerier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	res, err := q.querier.Query(request, gasLimit)
+	args, _ := json.Marshal(request)
+	q.trace.add(HostCall{Callback: HostCallQuery, Args: string(args), Output: res, Err: errString(err)})
+	return res, err
+}
+
+func (q *tracingQuerier) GasConsumed() uint64 {
+	return q.querier.GasConsumed()
+}
+
+// tracingStore records all data the contract reads from the store
+type tracingStore struct {
+	KVStore
+	trace *hostTrace
+}
+
+func (s *tracingStore) Get(key []byte) []byte {
+	value := s.KVStore.Get(key)
+	s.trace.add(HostCall{Callback: HostCallStorageRead, Args: fmt.Sprintf("%x", key), Output: value})
+	return value
+}
+
+func (s *tracingStore) Iterator(start, end []byte) Iterator {
+	s.trace.add(HostCall{Callback: HostCallStorageScan, Args: fmt.Sprintf("%x..%x ascending", start, end)})
+	return &tracingIterator{Iterator: s.KVStore.Iterator(start, end), trace: s.trace}
+}
+
+func (s *tracingStore) ReverseIterator(start, end []byte) Iterator {
+	s.trace.add(HostCall{Callback: HostCallStorageScan, Args: fmt.Sprintf("%x..%x descending", start, end)})
+	return &tracingIterator{Iterator: s.KVStore.ReverseIterator(start, end), trace: s.trace}
+}
+
+// tracingIterator records every value read from the iterator
+type tracingIterator struct {
+	Iterator
+	trace *hostTrace
+}
+
+func (it *tracingIterator) Value() []byte {
+	value := it.Iterator.Value()
+	it.trace.add(HostCall{Callback: HostCallStorageNext, Args: fmt.Sprintf("%x", it.Iterator.Key()), Output: value})
+	return value
+}
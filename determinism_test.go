@@ -0,0 +1,106 @@
+package cosmwasm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+func TestCompareRuns(t *testing.T) {
+	base := func() *determinismRun {
+		return &determinismRun{
+			hostCalls: []HostCall{{Callback: HostCallStorageRead, Args: "01", Output: []byte("a")}},
+			response:  []byte(`{"messages":[]}`),
+			gasUsed:   100,
+			writes:    []StoreWrite{{Key: []byte{1}, Value: []byte("b")}},
+		}
+	}
+	assert.Nil(t, compareRuns([2]*determinismRun{base(), base()}))
+
+	other := base()
+	other.hostCalls[0].Output = []byte("c")
+	other.gasUsed = 200
+	d := compareRuns([2]*determinismRun{base(), other})
+	require.NotNil(t, d)
+	assert.Equal(t, DivergenceHostCall, d.Kind)
+	assert.Equal(t, 0, d.Index)
+	assert.Equal(t, `host call #0 differs: db_read(01) = "a" vs. db_read(01) = "c"`, d.String())
+
+	other = base()
+	other.hostCalls = append(other.hostCalls, HostCall{Callback: HostCallQuery, Args: "{}", Err: "boom"})
+	d = compareRuns([2]*determinismRun{base(), other})
+	require.NotNil(t, d)
+	assert.Equal(t, 1, d.Index)
+	assert.Equal(t, `host call #1 differs: <none> vs. query({}) = "" (error "boom")`, d.String())
+
+	other = base()
+	other.gasUsed = 200
+	d = compareRuns([2]*determinismRun{base(), other})
+	require.NotNil(t, d)
+	assert.Equal(t, "gas used differs: 100 vs. 200", d.String())
+
+	other = base()
+	other.writes[0] = StoreWrite{Key: []byte{1}, Delete: true}
+	d = compareRuns([2]*determinismRun{base(), other})
+	require.NotNil(t, d)
+	assert.Equal(t, `writes #0 differs: set 01 = "b" vs. delete 01`, d.String())
+}
+
+// countingQuerier answers every query differently
+type countingQuerier struct {
+	count int
+}
+
+func (q *countingQuerier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	q.count++
+	return []byte(fmt.Sprintf(`{"amount":[{"denom":"ATOM","amount":"%d"}]}`, q.count)), nil
+}
+
+func (q *countingQuerier) GasConsumed() uint64 {
+	return 0
+}
+
+func TestCheckDeterminism(t *testing.T) {
+	vm1 := withVM(t)
+	vm2 := withVM(t)
+	checksum := createTestContract(t, vm1, HACKATOM_TEST_CONTRACT)
+	createTestContract(t, vm2, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	balance := types.Coins{types.NewCoin(250, "ATOM")}
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, balance)
+	env := api.MockEnv()
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := vm1.Instantiate(checksum, env, api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+
+	release := func(vm *VM, store KVStore, goapi GoAPI, querier Querier, out *CallOutput) (*types.Response, uint64, error) {
+		return vm.Execute(checksum, env, api.MockInfo("fred", nil), []byte(`{"release":{}}`), store, goapi, querier, NewGasMeter(TESTING_GAS_LIMIT), TESTING_GAS_LIMIT, deserCost, out)
+	}
+	d, err := CheckDeterminism(vm1, vm2, release, store, *goapi, querier)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	d, err = CheckDeterminism(vm1, vm2, release, store, *goapi, &countingQuerier{})
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, DivergenceHostCall, d.Kind)
+	assert.Contains(t, d.First, HostCallQuery)
+
+	_, err = CheckDeterminism(vm1, vm1, release, store, *goapi, querier)
+	require.Error(t, err)
+
+	// the raw result can only be compared if the call passes out to the VM
+	noOutput := func(vm *VM, store KVStore, goapi GoAPI, querier Querier, out *CallOutput) (*types.Response, uint64, error) {
+		return release(vm, store, goapi, querier, nil)
+	}
+	_, err = CheckDeterminism(vm1, vm2, noOutput, store, *goapi, querier)
+	require.EqualError(t, err, "the call did not pass its CallOutput to the VM")
+}
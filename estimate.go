@@ -0,0 +1,132 @@
+package cosmwasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/line/wasmvm/types"
+)
+
+// SimulateFunc runs one simulation of a contract call with the given gas limit.
+// This is typically a closure around one of the Simulate* methods, e.g.
+//
+//	func(gasLimit uint64) (*SimulationResult, error) {
+//		return vm.SimulateExecute(checksum, env, info, msg, store, goapi, querier, gasMeter, gasLimit, deserCost)
+//	}
+type SimulateFunc func(gasLimit uint64) (*SimulationResult, error)
+
+// DefaultGasSafetyMultiplier adds 10% to the estimated gas
+var DefaultGasSafetyMultiplier = types.UFraction{Numerator: 11, Denominator: 10}
+
+// GasEstimate is the result of EstimateGas
+type GasEstimate struct {
+	// GasNeeded is the lowest gas limit found with which the call succeeds
+	GasNeeded uint64
+	// Recommended is GasNeeded multiplied by the safety multiplier, capped at the maximum gas
+	Recommended uint64
+	// Simulations is the number of simulations that were run
+	Simulations int
+}
+
+// EstimateGas finds the gas limit needed for a contract call.
+//
+// The call is first simulated with maxGas. The gas needed is then the gas reported by the VM,
+// which includes the deserialization cost, plus the gas consumed on the external GasMeter.
+// A simulation only succeeds with a limit if this total fits into the limit, like a real transaction.
+// If the call does not succeed with this limit (e.g. because gas consumption depends on the limit),
+// the limit is found by bisection between this value and maxGas.
+//
+// The simulations only consume gas for writes if the store is a MeteredStore, see SimulateInstantiate.
+//
+// An error is returned if the call fails with maxGas.
+func (vm *VM) EstimateGas(call SimulateFunc, maxGas uint64, safetyMultiplier types.UFraction) (*GasEstimate, error) {
+	if safetyMultiplier.Denominator == 0 {
+		return nil, errors.New("safety multiplier must have a non-zero denominator")
+	}
+
+	estimate := GasEstimate{}
+	run := func(gasLimit uint64) (*SimulationResult, error) {
+		estimate.Simulations++
+		res, err := call(gasLimit)
+		if err == nil && res != nil {
+			// the VM only enforces the limit on its own gas, but a real transaction is also
+			// charged the gas consumed on the GasMeter
+			if total, ok := totalGas(res); !ok || total > gasLimit {
+				err = types.OutOfGasError{Limit: gasLimit, Used: total}
+			}
+		}
+		return res, err
+	}
+
+	res, err := run(maxGas)
+	if err != nil {
+		return nil, fmt.Errorf("call fails with maximum gas %d: %w", maxGas, err)
+	}
+	needed, _ := totalGas(res)
+
+	if needed < maxGas {
+		_, err = run(needed)
+		if err != nil {
+			if !isOutOfGas(err) {
+				return nil, fmt.Errorf("call fails with gas %d: %w", needed, err)
+			}
+			// bisect in (needed, maxGas], the call is known to succeed with hi
+			lo, hi := needed, maxGas
+			for hi-lo > 1 {
+				mid := lo + (hi-lo)/2
+				_, err = run(mid)
+				switch {
+				case err == nil:
+					hi = mid
+				case isOutOfGas(err):
+					lo = mid
+				default:
+					return nil, fmt.Errorf("call fails with gas %d: %w", mid, err)
+				}
+			}
+			needed = hi
+		}
+	}
+
+	estimate.GasNeeded = needed
+	estimate.Recommended = mulFractionCeil(needed, safetyMultiplier, maxGas)
+	return &estimate, nil
+}
+
+// totalGas returns the gas used by the VM plus the gas consumed on the GasMeter. On overflow it
+// returns math.MaxUint64 and false.
+func totalGas(res *SimulationResult) (uint64, bool) {
+	total, carry := bits.Add64(res.GasUsed, res.ExternalGasUsed, 0)
+	if carry != 0 {
+		return math.MaxUint64, false
+	}
+	return total, true
+}
+
+// isOutOfGas returns true for all errors caused by a too low gas limit
+func isOutOfGas(err error) bool {
+	var outOfGas types.OutOfGasError
+	return errors.As(err, &outOfGas)
+}
+
+// mulFractionCeil returns ceil(x * f) or max if the result is larger than max
+func mulFractionCeil(x uint64, f types.UFraction, max uint64) uint64 {
+	hi, lo := bits.Mul64(x, f.Numerator)
+	if hi >= f.Denominator {
+		// the quotient does not fit into uint64
+		return max
+	}
+	quo, rem := bits.Div64(hi, lo, f.Denominator)
+	if rem != 0 {
+		if quo == math.MaxUint64 {
+			return max
+		}
+		quo++
+	}
+	if quo > max {
+		return max
+	}
+	return quo
+}
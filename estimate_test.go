@@ -0,0 +1,90 @@
+package cosmwasm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+func TestEstimateGas(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, QUEUE_TEST_CONTRACT)
+	store, gasMeter := instantiateQueue(t, vm, checksum)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	env := api.MockEnv()
+	info := api.MockInfo("creator", nil)
+	msg := []byte(`{"enqueue":{"value":17}}`)
+
+	call := func(gasLimit uint64) (*SimulationResult, error) {
+		return vm.SimulateExecute(checksum, env, info, msg, store, *goapi, querier, gasMeter, gasLimit, deserCost)
+	}
+	estimate, err := vm.EstimateGas(call, TESTING_GAS_LIMIT, DefaultGasSafetyMultiplier)
+	require.NoError(t, err)
+	assert.NotZero(t, estimate.GasNeeded)
+	assert.GreaterOrEqual(t, estimate.Simulations, 2)
+	assert.Equal(t, mulFractionCeil(estimate.GasNeeded, DefaultGasSafetyMultiplier, TESTING_GAS_LIMIT), estimate.Recommended)
+
+	// the estimate is enough for the real call, including the gas the store charges for the write
+	before := gasMeter.GasConsumed()
+	_, gasUsed, err := vm.Execute(checksum, env, info, msg, store, *goapi, querier, gasMeter, estimate.GasNeeded, deserCost)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, gasUsed+gasMeter.GasConsumed()-before, estimate.GasNeeded)
+	assert.Greater(t, estimate.GasNeeded, gasUsed)
+
+	// too little maximum gas
+	_, err = vm.EstimateGas(call, estimate.GasNeeded/2, DefaultGasSafetyMultiplier)
+	require.Error(t, err)
+}
+
+func TestEstimateGasBisects(t *testing.T) {
+	vm := withVM(t)
+	const required = 123456
+	runs := 0
+	// a call that reports less gas than it needs
+	call := func(gasLimit uint64) (*SimulationResult, error) {
+		runs++
+		if gasLimit < required {
+			return &SimulationResult{GasUsed: gasLimit}, types.OutOfGasError{}
+		}
+		return &SimulationResult{GasUsed: 1000, ExternalGasUsed: 234}, nil
+	}
+	estimate, err := vm.EstimateGas(call, 1_000_000, types.UFraction{Numerator: 1, Denominator: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(required), estimate.GasNeeded)
+	assert.Equal(t, uint64(required), estimate.Recommended)
+	assert.Equal(t, runs, estimate.Simulations)
+}
+
+func TestEstimateGasCountsExternalGas(t *testing.T) {
+	vm := withVM(t)
+	// the VM gas fits into any limit, but with a low limit the call consumes a lot of external gas,
+	// e.g. because the contract takes another code path
+	call := func(gasLimit uint64) (*SimulationResult, error) {
+		if gasLimit < 100_000 {
+			return &SimulationResult{GasUsed: 1000, ExternalGasUsed: 49_000}, nil
+		}
+		return &SimulationResult{GasUsed: 1000, ExternalGasUsed: 1000}, nil
+	}
+	estimate, err := vm.EstimateGas(call, 1_000_000, types.UFraction{Numerator: 1, Denominator: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(50_000), estimate.GasNeeded)
+
+	// the total must fit into the maximum gas
+	_, err = vm.EstimateGas(call, 40_000, types.UFraction{Numerator: 1, Denominator: 1})
+	assert.True(t, isOutOfGas(err))
+}
+
+func TestMulFractionCeil(t *testing.T) {
+	assert.Equal(t, uint64(110), mulFractionCeil(100, types.UFraction{Numerator: 11, Denominator: 10}, math.MaxUint64))
+	assert.Equal(t, uint64(13), mulFractionCeil(11, types.UFraction{Numerator: 11, Denominator: 10}, math.MaxUint64))
+	assert.Equal(t, uint64(100), mulFractionCeil(100, types.UFraction{Numerator: 11, Denominator: 10}, 100))
+	assert.Equal(t, uint64(math.MaxUint64), mulFractionCeil(math.MaxUint64, types.UFraction{Numerator: 3, Denominator: 2}, math.MaxUint64))
+	assert.Equal(t, uint64(math.MaxUint64/2+1), mulFractionCeil(math.MaxUint64, types.UFraction{Numerator: 1, Denominator: 2}, math.MaxUint64))
+}
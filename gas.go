@@ -0,0 +1,133 @@
+package cosmwasm
+
+import (
+	"fmt"
+
+	"github.com/line/wasmvm/types"
+)
+
+// SetGasSchedule attaches a gas schedule to the VM. Every call is then priced with the GasConfig
+// active at the block height of its Env and the `deserCost` argument of the calls is ignored.
+// Pass nil to go back to charging only `deserCost` per byte of the contract result.
+//
+// The *EncodedEnv calls are priced at the height passed along with the encoded Env.
+func (vm *VM) SetGasSchedule(schedule *types.GasSchedule) {
+	vm.gasSchedule = schedule
+}
+
+// GasSchedule returns the schedule set by SetGasSchedule or nil
+func (vm *VM) GasSchedule() *types.GasSchedule {
+	return vm.gasSchedule
+}
+
+// gasConfig returns the pricing of a call at the given height
+func (vm *VM) gasConfig(height uint64, deserCost types.UFraction) types.GasConfig {
+	if vm.gasSchedule == nil {
+		return types.DefaultGasConfig(deserCost)
+	}
+	return vm.gasSchedule.ConfigAt(height)
+}
+
+// callGas is the gas accounting of a single call done on the Go side
+type callGas struct {
+	config types.GasConfig
+	// limit is the gas limit of the call
+	limit uint64
+	// vmLimit is the gas limit passed to the VM, i.e. without the base cost
+	vmLimit uint64
+}
+
+// startGas charges the base cost and wraps goapi and querier such that they charge the per byte costs of config
+func startGas(config types.GasConfig, gasLimit uint64, goapi *GoAPI, querier *Querier) (callGas, error) {
+	if gasLimit < config.BaseCost {
+		return callGas{}, types.OutOfGasError{Limit: gasLimit, Location: types.GasLocationBaseCost}
+	}
+	if !isZeroCost(config.HumanizeCostPerByte) || !isZeroCost(config.CanonicalizeCostPerByte) {
+		*goapi = pricedGoAPI(*goapi, config)
+	}
+	if !isZeroCost(config.QueryResponseCostPerByte) {
+		*querier = &pricedQuerier{querier: *querier, costPerByte: config.QueryResponseCostPerByte}
+	}
+	return callGas{
+		config:  config,
+		limit:   gasLimit,
+		vmLimit: gasLimit - config.BaseCost,
+	}, nil
+}
+
+// finish adds the base cost to the gas used by the VM and, if the call succeeded,
+// charges the deserialization of the result data
+func (g callGas) finish(gasUsed uint64, data []byte, err error) (uint64, error) {
+	gasUsed += g.config.BaseCost
+	if err != nil {
+		return gasUsed, err
+	}
+
+	gasForDeserialization, err := g.config.DeserializationCostPerByte.MulFloor(uint64(len(data)))
+	if err != nil || gasUsed > g.limit || g.limit-gasUsed < gasForDeserialization {
+		return gasUsed, types.OutOfGasError{
+			Limit:      g.limit,
+			Used:       gasUsed,
+			Location:   types.GasLocationDeserialization,
+			Descriptor: fmt.Sprintf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data)),
+		}
+	}
+	return gasUsed + gasForDeserialization, nil
+}
+
+func isZeroCost(f types.UFraction) bool {
+	return f.Numerator == 0
+}
+
+// pricedGoAPI adds the per byte costs of config to the costs returned by api
+func pricedGoAPI(api GoAPI, config types.GasConfig) GoAPI {
+	return GoAPI{
+		HumanAddress: func(canon []byte) (string, uint64, error) {
+			extra, err := config.HumanizeCostPerByte.MulFloor(uint64(len(canon)))
+			if err != nil {
+				return "", 0, err
+			}
+			human, cost, err := api.HumanAddress(canon)
+			return human, saturatingAdd(cost, extra), err
+		},
+		CanonicalAddress: func(human string) ([]byte, uint64, error) {
+			extra, err := config.CanonicalizeCostPerByte.MulFloor(uint64(len(human)))
+			if err != nil {
+				return nil, 0, err
+			}
+			canon, cost, err := api.CanonicalAddress(human)
+			return canon, saturatingAdd(cost, extra), err
+		},
+	}
+}
+
+// pricedQuerier reports the per byte cost of the query responses as consumed gas
+type pricedQuerier struct {
+	querier     Querier
+	costPerByte types.UFraction
+	extra       uint64
+}
+
+var _ Querier = (*pricedQuerier)(nil)
+
+func (q *pricedQuerier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	res, err := q.querier.Query(request, gasLimit)
+	cost, costErr := q.costPerByte.MulFloor(uint64(len(res)))
+	if costErr != nil {
+		return nil, costErr
+	}
+	q.extra = saturatingAdd(q.extra, cost)
+	return res, err
+}
+
+func (q *pricedQuerier) GasConsumed() uint64 {
+	return saturatingAdd(q.querier.GasConsumed(), q.extra)
+}
+
+func saturatingAdd(a, b uint64) uint64 {
+	sum := a + b
+	if sum < a {
+		return ^uint64(0)
+	}
+	return sum
+}
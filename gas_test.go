@@ -0,0 +1,81 @@
+package cosmwasm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+func TestCallGas(t *testing.T) {
+	config := types.DefaultGasConfig(types.UFraction{Numerator: 2, Denominator: 1})
+	config.BaseCost = 100
+	goapi := *api.NewMockAPI()
+	var querier Querier = api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+
+	_, err := startGas(config, 99, &goapi, &querier)
+	require.ErrorAs(t, err, &types.OutOfGasError{})
+
+	gas, err := startGas(config, 1000, &goapi, &querier)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(900), gas.vmLimit)
+
+	// base cost and deserialization
+	gasUsed, err := gas.finish(500, make([]byte, 200), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(500+100+400), gasUsed)
+
+	// not enough gas to deserialize
+	gasUsed, err = gas.finish(500, make([]byte, 201), nil)
+	require.Error(t, err)
+	assert.Equal(t, uint64(600), gasUsed)
+
+	// failed calls are charged the base cost
+	gasUsed, err = gas.finish(500, nil, errors.New("foo"))
+	require.EqualError(t, err, "foo")
+	assert.Equal(t, uint64(600), gasUsed)
+}
+
+func TestPricedAPIAndQuerier(t *testing.T) {
+	config := types.DefaultGasConfig(types.UFraction{Numerator: 1, Denominator: 1})
+	config.CanonicalizeCostPerByte = types.UFraction{Numerator: 10, Denominator: 1}
+	config.HumanizeCostPerByte = types.UFraction{Numerator: 1, Denominator: 2}
+	config.QueryResponseCostPerByte = types.UFraction{Numerator: 3, Denominator: 1}
+
+	plainAPI := *api.NewMockAPI()
+	balance := types.Coins{types.NewCoin(250, "ATOM")}
+	plainQuerier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, balance)
+
+	goapi := plainAPI
+	var querier Querier = plainQuerier
+	_, err := startGas(config, 1000, &goapi, &querier)
+	require.NoError(t, err)
+
+	human := "foobar"
+	canon, cost, err := goapi.CanonicalAddress(human)
+	require.NoError(t, err)
+	_, plainCost, _ := plainAPI.CanonicalAddress(human)
+	assert.Equal(t, plainCost+uint64(10*len(human)), cost)
+
+	_, cost, err = goapi.HumanAddress(canon)
+	require.NoError(t, err)
+	_, plainCost, _ = plainAPI.HumanAddress(canon)
+	assert.Equal(t, plainCost+uint64(len(canon)/2), cost)
+
+	request := types.QueryRequest{Bank: &types.BankQuery{AllBalances: &types.AllBalancesQuery{Address: api.MOCK_CONTRACT_ADDR}}}
+	before := querier.GasConsumed()
+	res, err := querier.Query(request, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, before+uint64(3*len(res)), querier.GasConsumed())
+
+	// without per byte costs nothing is wrapped
+	goapi = plainAPI
+	querier = plainQuerier
+	_, err = startGas(types.DefaultGasConfig(types.UFraction{Numerator: 1, Denominator: 1}), 1000, &goapi, &querier)
+	require.NoError(t, err)
+	assert.Equal(t, Querier(plainQuerier), querier)
+}
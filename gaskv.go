@@ -0,0 +1,35 @@
+package cosmwasm
+
+import (
+	"github.com/line/wasmvm/internal/api"
+)
+
+// FullGasMeter is the full GasMeter interface of the SDK. GasKVStore consumes gas from it.
+type FullGasMeter = api.FullGasMeter
+
+// NewGasMeter returns a gas meter that panics with an out of gas error when more than limit is consumed
+func NewGasMeter(limit uint64) FullGasMeter {
+	return api.NewGasMeter(limit)
+}
+
+// NewInfiniteGasMeter returns a gas meter without a limit
+func NewInfiniteGasMeter() FullGasMeter {
+	return api.NewInfiniteGasMeter()
+}
+
+// KVGasConfig defines the gas cost for each operation on a GasKVStore
+type KVGasConfig = api.KVGasConfig
+
+// DefaultKVGasConfig returns the SDK's default costs for KVStore operations
+func DefaultKVGasConfig() KVGasConfig {
+	return api.DefaultKVGasConfig()
+}
+
+// GasKVStore is a KVStore consuming gas for each operation like the SDK's gaskv store
+type GasKVStore = api.GasKVStore
+
+// NewGasKVStore wraps parent such that all operations consume gas on gasMeter. Pass the same gasMeter
+// to the contract calls to have the storage gas accounted for.
+func NewGasKVStore(parent KVStore, gasMeter FullGasMeter, gasConfig KVGasConfig) *GasKVStore {
+	return api.NewGasKVStore(parent, gasMeter, gasConfig)
+}
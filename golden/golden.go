@@ -0,0 +1,447 @@
+// Package golden guards the gas used by contract calls against unnoticed changes.
+//
+// A corpus lists contract calls. Measure runs them with the mock store, API and querier of the
+// VM tests and records the gas used and a hash of every response. These measurements are kept
+// in a golden file, and Verify fails a test with a table of all calls whose gas drifted beyond a
+// threshold or whose response changed:
+//
+//	var update = flag.Bool("update", false, "update the golden files")
+//
+//	func TestGas(t *testing.T) {
+//		golden.Verify(t, vm, "testdata/corpus.json", "testdata/gas.golden.json", 0.01, *update)
+//	}
+//
+// After an intended change, run the test with -update to rewrite the golden file.
+package golden
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	cosmwasm "github.com/line/wasmvm"
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+// Entry points a call can use
+const (
+	EntryInstantiate       = "instantiate"
+	EntryExecute           = "execute"
+	EntryQuery             = "query"
+	EntryMigrate           = "migrate"
+	EntrySudo              = "sudo"
+	EntryReply             = "reply"
+	EntryIBCChannelOpen    = "ibc_channel_open"
+	EntryIBCChannelConnect = "ibc_channel_connect"
+	EntryIBCChannelClose   = "ibc_channel_close"
+	EntryIBCPacketReceive  = "ibc_packet_receive"
+	EntryIBCPacketAck      = "ibc_packet_ack"
+	EntryIBCPacketTimeout  = "ibc_packet_timeout"
+)
+
+// GasLimit is the gas limit of every call
+const GasLimit uint64 = 500_000_000_000
+
+// Corpus is a list of cases
+type Corpus struct {
+	Cases []Case `json:"cases"`
+	// dir is the directory of the corpus file, contracts are relative to it
+	dir string
+}
+
+// Case is a sequence of calls on one contract instance with a fresh store
+type Case struct {
+	Name string `json:"name"`
+	// Contract is the path of the Wasm file
+	Contract string `json:"contract"`
+	// Balance is the balance of the contract seen by bank queries
+	Balance types.Coins `json:"balance,omitempty"`
+	Calls   []Call      `json:"calls"`
+}
+
+// Call is one contract call. Msg is the message of the entry point, for reply and the IBC
+// entry points the JSON encoding of the message type, e.g. types.IBCChannelOpenMsg.
+type Call struct {
+	Name   string          `json:"name"`
+	Entry  string          `json:"entry"`
+	Sender string          `json:"sender,omitempty"`
+	Funds  types.Coins     `json:"funds,omitempty"`
+	Msg    json.RawMessage `json:"msg"`
+}
+
+// Measurement is the outcome of one call
+type Measurement struct {
+	// Gas is the gas reported by the VM plus the gas charged by the store
+	Gas uint64 `json:"gas"`
+	// Response is the hex encoded SHA-256 hash of the JSON response, or of the error message for failed calls
+	Response string `json:"response"`
+	Failed   bool   `json:"failed,omitempty"`
+}
+
+// Measurements are keyed by "case/call"
+type Measurements map[string]Measurement
+
+// LoadCorpus reads a corpus file
+func LoadCorpus(path string) (*Corpus, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var corpus Corpus
+	if err := json.Unmarshal(bz, &corpus); err != nil {
+		return nil, fmt.Errorf("cannot parse corpus %s: %w", path, err)
+	}
+	corpus.dir = filepath.Dir(path)
+	if err := corpus.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid corpus %s: %w", path, err)
+	}
+	return &corpus, nil
+}
+
+var entryPoints = map[string]bool{
+	EntryInstantiate: true, EntryExecute: true, EntryQuery: true, EntryMigrate: true, EntrySudo: true, EntryReply: true,
+	EntryIBCChannelOpen: true, EntryIBCChannelConnect: true, EntryIBCChannelClose: true,
+	EntryIBCPacketReceive: true, EntryIBCPacketAck: true, EntryIBCPacketTimeout: true,
+}
+
+// Validate checks that all calls have a unique name and a known entry point
+func (c *Corpus) Validate() error {
+	seen := make(map[string]bool)
+	for _, cs := range c.Cases {
+		if cs.Name == "" || cs.Contract == "" {
+			return errors.New("every case needs a name and a contract")
+		}
+		for _, call := range cs.Calls {
+			name := cs.Name + "/" + call.Name
+			if call.Name == "" {
+				return fmt.Errorf("case %s has a call without name", cs.Name)
+			}
+			if seen[name] {
+				return fmt.Errorf("duplicate call %s", name)
+			}
+			seen[name] = true
+			if !entryPoints[call.Entry] {
+				return fmt.Errorf("call %s has unknown entry point %q", name, call.Entry)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadGolden reads a golden file
+func ReadGolden(path string) (Measurements, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var golden Measurements
+	if err := json.Unmarshal(bz, &golden); err != nil {
+		return nil, fmt.Errorf("cannot parse golden file %s: %w", path, err)
+	}
+	return golden, nil
+}
+
+// WriteFile writes the measurements as golden file, with one call per line for readable diffs
+func (m Measurements) WriteFile(path string) error {
+	names := m.names()
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, name := range names {
+		key, _ := json.Marshal(name)
+		value, err := json.Marshal(m[name])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  %s: %s", key, value)
+		if i != len(names)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return ioutil.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+func (m Measurements) names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Measure runs all calls of the corpus. Failing calls are measured as well, an error is only
+// returned if a call cannot be made, e.g. because its contract cannot be stored.
+func Measure(vm *cosmwasm.VM, corpus *Corpus) (Measurements, error) {
+	if err := corpus.Validate(); err != nil {
+		return nil, err
+	}
+	measurements := make(Measurements)
+	for _, c := range corpus.Cases {
+		path := c.Contract
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(corpus.dir, path)
+		}
+		code, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+		checksum, err := vm.Create(code)
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+
+		store := api.NewLookup(api.NewMockGasMeter(GasLimit))
+		for _, call := range c.Calls {
+			name := c.Name + "/" + call.Name
+			gasMeter := api.NewMockGasMeter(GasLimit)
+			store.SetGasMeter(gasMeter)
+			res, gasUsed, err := run(vm, checksum, c, call, store, gasMeter)
+			if errors.As(err, &setupError{}) {
+				return nil, fmt.Errorf("call %s: %w", name, err)
+			}
+			measurements[name] = measure(gasUsed+gasMeter.GasConsumed(), res, err)
+		}
+	}
+	return measurements, nil
+}
+
+// setupError means a call could not be made
+type setupError struct {
+	err error
+}
+
+func (e setupError) Error() string {
+	return e.err.Error()
+}
+
+func (e setupError) Unwrap() error {
+	return e.err
+}
+
+func measure(gas uint64, res interface{}, err error) Measurement {
+	var hash [32]byte
+	if err != nil {
+		hash = sha256.Sum256([]byte(err.Error()))
+	} else {
+		bz, _ := json.Marshal(res)
+		hash = sha256.Sum256(bz)
+	}
+	return Measurement{Gas: gas, Response: hex.EncodeToString(hash[:]), Failed: err != nil}
+}
+
+// run makes one call and returns its response
+func run(vm *cosmwasm.VM, checksum cosmwasm.Checksum, c Case, call Call, store cosmwasm.KVStore, gasMeter cosmwasm.GasMeter) (interface{}, uint64, error) {
+	env := api.MockEnv()
+	goapi := *api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, c.Balance)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	msg := []byte(call.Msg)
+
+	// unmarshal decodes the message of the typed entry points
+	unmarshal := func(v interface{}) error {
+		if err := json.Unmarshal(msg, v); err != nil {
+			return setupError{fmt.Errorf("invalid %s message: %w", call.Entry, err)}
+		}
+		return nil
+	}
+
+	switch call.Entry {
+	case EntryInstantiate:
+		info := types.MessageInfo{Sender: call.Sender, Funds: coinsOrEmpty(call.Funds)}
+		return vm.Instantiate(checksum, env, info, msg, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryExecute:
+		info := types.MessageInfo{Sender: call.Sender, Funds: coinsOrEmpty(call.Funds)}
+		return vm.Execute(checksum, env, info, msg, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryQuery:
+		res, gasUsed, err := vm.Query(checksum, env, msg, store, goapi, querier, gasMeter, GasLimit, deserCost)
+		// query results are JSON, hash them as they are
+		return json.RawMessage(res), gasUsed, err
+	case EntryMigrate:
+		return vm.Migrate(checksum, env, msg, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntrySudo:
+		return vm.Sudo(checksum, env, msg, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryReply:
+		var reply types.Reply
+		if err := unmarshal(&reply); err != nil {
+			return nil, 0, err
+		}
+		return vm.Reply(checksum, env, reply, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryIBCChannelOpen:
+		var m types.IBCChannelOpenMsg
+		if err := unmarshal(&m); err != nil {
+			return nil, 0, err
+		}
+		return vm.IBCChannelOpen(checksum, env, m, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryIBCChannelConnect:
+		var m types.IBCChannelConnectMsg
+		if err := unmarshal(&m); err != nil {
+			return nil, 0, err
+		}
+		return vm.IBCChannelConnect(checksum, env, m, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryIBCChannelClose:
+		var m types.IBCChannelCloseMsg
+		if err := unmarshal(&m); err != nil {
+			return nil, 0, err
+		}
+		return vm.IBCChannelClose(checksum, env, m, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryIBCPacketReceive:
+		var m types.IBCPacketReceiveMsg
+		if err := unmarshal(&m); err != nil {
+			return nil, 0, err
+		}
+		return vm.IBCPacketReceive(checksum, env, m, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryIBCPacketAck:
+		var m types.IBCPacketAckMsg
+		if err := unmarshal(&m); err != nil {
+			return nil, 0, err
+		}
+		return vm.IBCPacketAck(checksum, env, m, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	case EntryIBCPacketTimeout:
+		var m types.IBCPacketTimeoutMsg
+		if err := unmarshal(&m); err != nil {
+			return nil, 0, err
+		}
+		return vm.IBCPacketTimeout(checksum, env, m, store, goapi, querier, gasMeter, GasLimit, deserCost)
+	default:
+		return nil, 0, setupError{fmt.Errorf("unknown entry point %q", call.Entry)}
+	}
+}
+
+func coinsOrEmpty(coins types.Coins) types.Coins {
+	if coins == nil {
+		return types.Coins{}
+	}
+	return coins
+}
+
+// Drift is a call whose measurement differs from the golden file
+type Drift struct {
+	Name string
+	// Golden is nil for new calls
+	Golden *Measurement
+	// Actual is nil for calls that were removed from the corpus
+	Actual *Measurement
+}
+
+// GasChange returns the relative change of the gas used, 0 if the call is new or was removed
+func (d Drift) GasChange() float64 {
+	if d.Golden == nil || d.Actual == nil || d.Golden.Gas == 0 {
+		return 0
+	}
+	return (float64(d.Actual.Gas) - float64(d.Golden.Gas)) / float64(d.Golden.Gas)
+}
+
+// Compare returns the calls whose gas changed by more than threshold (relative, e.g. 0.01 for 1%),
+// whose response changed or that exist only in one of golden and actual
+func Compare(golden, actual Measurements, threshold float64) []Drift {
+	var drifts []Drift
+	names := golden.names()
+	for name := range actual {
+		if _, ok := golden[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		drift := Drift{Name: name}
+		if g, ok := golden[name]; ok {
+			drift.Golden = &g
+		}
+		if a, ok := actual[name]; ok {
+			drift.Actual = &a
+		}
+		if drift.Golden != nil && drift.Actual != nil {
+			change := drift.GasChange()
+			if change < 0 {
+				change = -change
+			}
+			gasDrifted := change > threshold || (drift.Golden.Gas == 0 && drift.Actual.Gas != 0)
+			responseChanged := drift.Golden.Response != drift.Actual.Response || drift.Golden.Failed != drift.Actual.Failed
+			if !gasDrifted && !responseChanged {
+				continue
+			}
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts
+}
+
+// FormatDrifts renders drifts as a table
+func FormatDrifts(drifts []Drift) string {
+	width := len("CALL")
+	for _, d := range drifts {
+		if len(d.Name) > width {
+			width = len(d.Name)
+		}
+	}
+	var b strings.Builder
+	row := func(name, golden, actual, change, response string) {
+		fmt.Fprintf(&b, "%-*s  %12s  %12s  %8s  %s\n", width, name, golden, actual, change, response)
+	}
+	row("CALL", "GOLDEN GAS", "GAS", "CHANGE", "RESPONSE")
+	for _, d := range drifts {
+		switch {
+		case d.Golden == nil:
+			row(d.Name, "-", fmt.Sprint(d.Actual.Gas), "-", "new call")
+		case d.Actual == nil:
+			row(d.Name, fmt.Sprint(d.Golden.Gas), "-", "-", "removed call")
+		default:
+			response := "unchanged"
+			switch {
+			case d.Golden.Failed != d.Actual.Failed && d.Actual.Failed:
+				response = "now fails"
+			case d.Golden.Failed != d.Actual.Failed:
+				response = "now succeeds"
+			case d.Golden.Response != d.Actual.Response:
+				response = "changed"
+			}
+			row(d.Name, fmt.Sprint(d.Golden.Gas), fmt.Sprint(d.Actual.Gas), fmt.Sprintf("%+.2f%%", d.GasChange()*100), response)
+		}
+	}
+	return b.String()
+}
+
+// Verify measures the corpus and compares it with the golden file, failing t with a table of all drifts.
+// With update, the golden file is written instead. Without update, the golden file is never written
+// and a missing one fails t.
+func Verify(t testing.TB, vm *cosmwasm.VM, corpusPath, goldenPath string, threshold float64, update bool) {
+	t.Helper()
+	corpus, err := LoadCorpus(corpusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := Measure(vm, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update {
+		if err := actual.WriteFile(goldenPath); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated %s with %d calls", goldenPath, len(actual))
+		return
+	}
+	golden, err := ReadGolden(goldenPath)
+	if os.IsNotExist(err) {
+		t.Fatalf("%s does not exist, run with the update flag to create it and commit it", goldenPath)
+	}
+	if err != nil {
+		t.Fatalf("%s, run with the update flag to create it", err)
+	}
+	if drifts := Compare(golden, actual, threshold); len(drifts) != 0 {
+		t.Fatalf("%d calls drifted from %s (gas threshold %.2f%%), run with the update flag if this is intended:\n%s",
+			len(drifts), goldenPath, threshold*100, FormatDrifts(drifts))
+	}
+}
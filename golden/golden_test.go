@@ -0,0 +1,128 @@
+package golden
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cosmwasm "github.com/line/wasmvm"
+)
+
+var update = flag.Bool("update", false, "update the golden gas file")
+
+const (
+	testCorpus = "testdata/corpus.json"
+	testGolden = "testdata/gas.golden.json"
+)
+
+func TestLoadCorpus(t *testing.T) {
+	corpus, err := LoadCorpus(testCorpus)
+	require.NoError(t, err)
+	require.Len(t, corpus.Cases, 5)
+	for _, c := range corpus.Cases {
+		_, err := os.Stat(filepath.Join(corpus.dir, c.Contract))
+		require.NoError(t, err, c.Name)
+	}
+
+	invalid := &Corpus{Cases: []Case{{Name: "a", Contract: "a.wasm", Calls: []Call{{Name: "x", Entry: "instantiate"}, {Name: "x", Entry: "execute"}}}}}
+	require.EqualError(t, invalid.Validate(), "duplicate call a/x")
+	invalid.Cases[0].Calls[1] = Call{Name: "y", Entry: "foo"}
+	require.EqualError(t, invalid.Validate(), `call a/y has unknown entry point "foo"`)
+}
+
+func TestGoldenFileRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := Measurements{
+		"b/call": {Gas: 2, Response: "bb", Failed: true},
+		"a/call": {Gas: 1, Response: "aa"},
+	}
+	path := filepath.Join(dir, "gas.json")
+	require.NoError(t, m.WriteFile(path))
+	bz, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "a/call": {"gas":1,"response":"aa"},
+  "b/call": {"gas":2,"response":"bb","failed":true}
+}
+`, string(bz))
+
+	read, err := ReadGolden(path)
+	require.NoError(t, err)
+	assert.Equal(t, m, read)
+}
+
+func TestCompare(t *testing.T) {
+	golden := Measurements{
+		"c/stable":   {Gas: 1000, Response: "aa"},
+		"c/small":    {Gas: 1000, Response: "aa"},
+		"c/large":    {Gas: 1000, Response: "aa"},
+		"c/response": {Gas: 1000, Response: "aa"},
+		"c/fails":    {Gas: 1000, Response: "aa"},
+		"c/removed":  {Gas: 1000, Response: "aa"},
+	}
+	actual := Measurements{
+		"c/stable":   {Gas: 1000, Response: "aa"},
+		"c/small":    {Gas: 1010, Response: "aa"},
+		"c/large":    {Gas: 900, Response: "aa"},
+		"c/response": {Gas: 1000, Response: "bb"},
+		"c/fails":    {Gas: 1005, Response: "cc", Failed: true},
+		"c/new":      {Gas: 50, Response: "aa"},
+	}
+
+	drifts := Compare(golden, actual, 0.01)
+	names := make([]string, len(drifts))
+	for i, d := range drifts {
+		names[i] = d.Name
+	}
+	assert.Equal(t, []string{"c/fails", "c/large", "c/new", "c/removed", "c/response"}, names)
+	assert.InDelta(t, -0.1, drifts[1].GasChange(), 1e-9)
+	assert.Zero(t, drifts[2].GasChange())
+
+	assert.Equal(t, `CALL          GOLDEN GAS           GAS    CHANGE  RESPONSE
+c/fails             1000          1005    +0.50%  now fails
+c/large             1000           900   -10.00%  unchanged
+c/new                  -            50         -  new call
+c/removed           1000             -         -  removed call
+c/response          1000          1000    +0.00%  changed
+`, FormatDrifts(drifts))
+
+	assert.Empty(t, Compare(golden, golden, 0))
+}
+
+func withVM(t *testing.T) *cosmwasm.VM {
+	tmpdir, err := ioutil.TempDir("", "wasmvm-golden")
+	require.NoError(t, err)
+	vm, err := cosmwasm.NewVM(tmpdir, "staking,stargate,iterator", 32, false, 100)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		vm.Cleanup()
+		os.RemoveAll(tmpdir)
+	})
+	return vm
+}
+
+// TestGas checks the bundled contracts against the golden file. Run with -update after intended gas changes.
+func TestGas(t *testing.T) {
+	if _, err := os.Stat(testGolden); os.IsNotExist(err) && !*update {
+		t.Fatalf("%s does not exist, run make update-gas-golden against a real libwasmvm build and commit it", testGolden)
+	}
+	Verify(t, withVM(t), testCorpus, testGolden, 0.01, *update)
+}
+
+func TestMeasureIsDeterministic(t *testing.T) {
+	corpus, err := LoadCorpus(testCorpus)
+	require.NoError(t, err)
+	first, err := Measure(withVM(t), corpus)
+	require.NoError(t, err)
+	second, err := Measure(withVM(t), corpus)
+	require.NoError(t, err)
+	require.Empty(t, Compare(first, second, 0), FormatDrifts(Compare(first, second, 0)))
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+)
+
+// KeyRange is a range of keys a contract iterated over
+type KeyRange struct {
+	// Start and End are the bounds passed to the iterator. Start is inclusive, End is exclusive
+	// and nil means unbounded.
+	Start []byte
+	End   []byte
+	// Descending is true for reverse iterators
+	Descending bool
+	// Last is the last key the contract read from the iterator or nil if it read none
+	Last []byte
+	// Exhausted is true if the contract iterated until the end of the range
+	Exhausted bool
+}
+
+// Contains returns true if key is in the part of the range the contract observed.
+// Keys behind the last key read from a non exhausted iterator do not influence the contract.
+func (r KeyRange) Contains(key []byte) bool {
+	if r.Start != nil && bytes.Compare(key, r.Start) < 0 {
+		return false
+	}
+	if r.End != nil && bytes.Compare(key, r.End) >= 0 {
+		return false
+	}
+	if r.Exhausted {
+		return true
+	}
+	if r.Last == nil {
+		return false
+	}
+	if r.Descending {
+		return bytes.Compare(key, r.Last) >= 0
+	}
+	return bytes.Compare(key, r.Last) <= 0
+}
+
+// AccessSet is the read and write set of a contract call
+type AccessSet struct {
+	// Reads are the keys read with Get in the order of the first read. Missing keys are included.
+	Reads [][]byte
+	// Ranges are the iterated ranges in the order the iterators were created
+	Ranges []KeyRange
+	// Writes are all successful writes and deletes in the order they happened. Writes rejected by the
+	// storage limits or failing in the store are not included.
+	Writes []StoreWrite
+
+	// readKeys indexes Reads
+	readKeys map[string]struct{}
+}
+
+func (s *AccessSet) recordRead(key []byte) {
+	if s.readKeys == nil {
+		s.readKeys = make(map[string]struct{})
+	}
+	if _, ok := s.readKeys[string(key)]; ok {
+		return
+	}
+	s.readKeys[string(key)] = struct{}{}
+	s.Reads = append(s.Reads, append([]byte(nil), key...))
+}
+
+func (s *AccessSet) recordWrite(key, value []byte, deleted bool) {
+	write := StoreWrite{Key: append([]byte(nil), key...), Delete: deleted}
+	if !deleted {
+		write.Value = append([]byte{}, value...)
+	}
+	s.Writes = append(s.Writes, write)
+}
+
+// recordRange adds a range and returns an iterator updating it while the contract iterates
+func (s *AccessSet) recordRange(start, end []byte, descending bool, it Iterator) Iterator {
+	s.Ranges = append(s.Ranges, KeyRange{
+		Start:      copyOrNil(start),
+		End:        copyOrNil(end),
+		Descending: descending,
+	})
+	return &rangeRecordingIterator{Iterator: it, set: s, index: len(s.Ranges) - 1}
+}
+
+func copyOrNil(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// hasRead returns true if key was read or is in an observed range
+func (s *AccessSet) hasRead(key []byte) bool {
+	if len(s.readKeys) == len(s.Reads) {
+		if _, ok := s.readKeys[string(key)]; ok {
+			return true
+		}
+	} else {
+		// Reads was filled by the caller, not by a contract call
+		for _, read := range s.Reads {
+			if bytes.Equal(read, key) {
+				return true
+			}
+		}
+	}
+	for _, r := range s.Ranges {
+		if r.Contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConflictsWith returns true if the order in which the calls of s and other are committed can
+// change the outcome, i.e. if one call writes a key the other one read or if both write the same key.
+// Calls without conflict can be executed in parallel and committed in either order.
+func (s *AccessSet) ConflictsWith(other *AccessSet) bool {
+	for _, write := range s.Writes {
+		if other.hasRead(write.Key) {
+			return true
+		}
+	}
+	for _, write := range other.Writes {
+		if s.hasRead(write.Key) {
+			return true
+		}
+	}
+	if len(s.Writes) > 0 && len(other.Writes) > 0 {
+		written := make(map[string]struct{}, len(s.Writes))
+		for _, write := range s.Writes {
+			written[string(write.Key)] = struct{}{}
+		}
+		for _, write := range other.Writes {
+			if _, ok := written[string(write.Key)]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rangeRecordingIterator tracks how far the contract iterated over a recorded range
+type rangeRecordingIterator struct {
+	Iterator
+	set   *AccessSet
+	index int
+}
+
+func (it *rangeRecordingIterator) Valid() bool {
+	valid := it.Iterator.Valid()
+	if !valid {
+		it.set.Ranges[it.index].Exhausted = true
+	}
+	return valid
+}
+
+func (it *rangeRecordingIterator) Key() []byte {
+	key := it.Iterator.Key()
+	it.set.Ranges[it.index].Last = append([]byte(nil), key...)
+	return key
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+func TestKeyRangeContains(t *testing.T) {
+	cases := map[string]struct {
+		r        KeyRange
+		included []string
+		excluded []string
+	}{
+		"exhausted ascending": {
+			r:        KeyRange{Start: []byte("b"), End: []byte("d"), Exhausted: true},
+			included: []string{"b", "c", "cz"},
+			excluded: []string{"a", "d", "e"},
+		},
+		"unbounded exhausted": {
+			r:        KeyRange{Exhausted: true},
+			included: []string{"", "a", "zzz"},
+		},
+		"ascending stopped early": {
+			r:        KeyRange{Start: []byte("b"), Last: []byte("c")},
+			included: []string{"b", "bb", "c"},
+			excluded: []string{"a", "ca", "d"},
+		},
+		"descending stopped early": {
+			r:        KeyRange{End: []byte("d"), Descending: true, Last: []byte("b")},
+			included: []string{"b", "c", "cz"},
+			excluded: []string{"a", "d"},
+		},
+		"nothing read": {
+			r:        KeyRange{Start: []byte("a"), End: []byte("z")},
+			excluded: []string{"a", "m"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range tc.included {
+				assert.True(t, tc.r.Contains([]byte(key)), key)
+			}
+			for _, key := range tc.excluded {
+				assert.False(t, tc.r.Contains([]byte(key)), key)
+			}
+		})
+	}
+}
+
+func TestAccessSetConflictsWith(t *testing.T) {
+	read := func(keys ...string) *AccessSet {
+		set := &AccessSet{}
+		for _, key := range keys {
+			set.recordRead([]byte(key))
+		}
+		return set
+	}
+	write := func(keys ...string) *AccessSet {
+		set := &AccessSet{}
+		for _, key := range keys {
+			set.recordWrite([]byte(key), []byte("v"), false)
+		}
+		return set
+	}
+	scan := &AccessSet{Ranges: []KeyRange{{Start: []byte("m"), End: []byte("p"), Exhausted: true}}}
+
+	cases := map[string]struct {
+		a, b     *AccessSet
+		conflict bool
+	}{
+		"empty":               {&AccessSet{}, &AccessSet{}, false},
+		"reads only":          {read("a", "b"), read("a"), false},
+		"disjoint writes":     {write("a"), write("b"), false},
+		"same write":          {write("a"), write("a"), true},
+		"write read":          {write("a"), read("a"), true},
+		"read write":          {read("a"), write("a"), true},
+		"write in range":      {write("n"), scan, true},
+		"write outside range": {scan, write("p"), false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.conflict, tc.a.ConflictsWith(tc.b))
+			assert.Equal(t, tc.conflict, tc.b.ConflictsWith(tc.a))
+		})
+	}
+}
+
+func TestRangeRecordingIterator(t *testing.T) {
+	db := NewMemDB()
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, db.Set([]byte(key), []byte(key)))
+	}
+	set := &AccessSet{}
+
+	iter, err := db.Iterator(nil, nil)
+	require.NoError(t, err)
+	iter = set.recordRange(nil, nil, false, iter)
+	require.True(t, iter.Valid())
+	assert.Equal(t, []byte("a"), iter.Key())
+	iter.Next()
+	require.True(t, iter.Valid())
+	assert.Equal(t, []byte("b"), iter.Key())
+	assert.Equal(t, KeyRange{Last: []byte("b")}, set.Ranges[0])
+
+	iter, err = db.ReverseIterator([]byte("b"), nil)
+	require.NoError(t, err)
+	iter = set.recordRange([]byte("b"), nil, true, iter)
+	for ; iter.Valid(); iter.Next() {
+		iter.Key()
+	}
+	assert.Equal(t, KeyRange{Start: []byte("b"), Descending: true, Last: []byte("b"), Exhausted: true}, set.Ranges[1])
+}
+
+func TestQueueAccessSets(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	setup := setupQueueContract(t, cache)
+	checksum, querier, api := setup.checksum, setup.querier, setup.api
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+
+	query := func() *AccessSet {
+		gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+		igasMeter := GasMeter(gasMeter)
+		out := CallOutput{RecordAccess: true}
+		_, _, err := Query(cache, checksum, env, []byte(`{"sum":{}}`), &igasMeter, setup.Store(gasMeter), api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, &out)
+		require.NoError(t, err)
+		return out.Access
+	}
+	sum1 := query()
+	sum2 := query()
+	require.Len(t, sum1.Ranges, 1)
+	assert.True(t, sum1.Ranges[0].Exhausted)
+	assert.Empty(t, sum1.Writes)
+	assert.Equal(t, sum1, sum2)
+	assert.False(t, sum1.ConflictsWith(sum2))
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	out := CallOutput{RecordAccess: true}
+	_, _, err := Execute(cache, checksum, env, info, []byte(`{"enqueue":{"value":5}}`), &igasMeter, setup.Store(gasMeter), api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, &out)
+	require.NoError(t, err)
+	enqueue := out.Access
+	require.Len(t, enqueue.Writes, 1)
+	assert.True(t, enqueue.ConflictsWith(sum1))
+}
+
+func TestFailedWritesAreNotRecorded(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	checksum := createTestContract(t, cache)
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	store := AdaptKVStoreWithErrors(NewMockFailureKVStore(NewLookup(gasMeter)))
+	api := NewMockAPI()
+	querier := DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{})
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	out := CallOutput{RecordAccess: true}
+	_, _, err := Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, &out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mock failure - set")
+	require.NotNil(t, out.Access)
+	assert.Empty(t, out.Access.Writes)
+}
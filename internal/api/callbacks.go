@@ -38,20 +38,19 @@ import "C"
 import (
 	"encoding/json"
 	"fmt"
-	"log"
-	"reflect"
 	"runtime/debug"
 	"unsafe"
 
-	dbm "github.com/tendermint/tm-db"
-
 	"github.com/line/wasmvm/types"
 )
 
 // Note: we have to include all exports in the same file (at least since they both import bindings.h),
 // or get odd cgo build errors about duplicate definitions
 
-func recoverPanic(ret *C.GoError) {
+// recoverPanic turns panics in callbacks into error codes using the registered PanicClassifiers.
+// callID and location are used to report where a call ran out of gas. callID is 0 if unknown.
+// errOut receives the message of user errors.
+func recoverPanic(ret *C.GoError, errOut *C.UnmanagedVector, callID uint64, location types.GasLocation) {
 	if rec := recover(); rec != nil {
 		// This is used to handle ErrorOutOfGas panics.
 		//
@@ -63,30 +62,23 @@ func recoverPanic(ret *C.GoError) {
 		// use a panic; otherwise, use an error.' says this popular answer on SO: https://stackoverflow.com/a/44505268.
 		// Oh, and "If you're already worrying about discriminating different kinds of panics, you've lost sight of the ball."
 		// (Rob Pike) from https://eli.thegreenplace.net/2018/on-the-uses-and-misuses-of-panics-in-go/
-		//
-		// We don't want to import Cosmos SDK and also cannot use interfaces to detect these
-		// error types (as they have no methods). So, let's just rely on the descriptive names.
-		name := reflect.TypeOf(rec).Name()
-		switch name {
-		// These three types are "thrown" (which is not a thing in Go 🙃) in panics from the gas module
-		// (https://github.com/line/lbm-sdk/blob/main/store/types/gas.go):
-		// 1. ErrorOutOfGas
-		// 2. ErrorGasOverflow
-		// 3. ErrorNegativeGasConsumed
-		//
-		// In the baseapp, ErrorOutOfGas gets special treatment:
-		// - https://github.com/line/lbm-sdk/blob/main/baseapp/baseapp.go#L647
-		// - https://github.com/line/lbm-sdk/blob/main/baseapp/recovery.go#L50-L60
-		// This turns the panic into a regular error with a helpful error message.
-		//
-		// The other two gas related panic types indicate programming errors and are handled along
-		// with all other errors in https://github.com/line/lbm-sdk/blob/main/baseapp/recovery.go#L66-L77.
-		case "ErrorOutOfGas":
-			// TODO: figure out how to pass the text in its `Descriptor` field through all the FFI
+		category, hook := classifyPanic(rec)
+		switch category {
+		case PanicCategoryOutOfGas:
+			// The VM only gets the error code. The details are picked up from the record when the call returns.
+			recordOutOfGas(callID, location, panicDescriptor(rec))
 			*ret = C.GoError_OutOfGas
+		case PanicCategoryUser:
+			if errOut != nil && (*errOut).is_none {
+				*errOut = newUnmanagedVector([]byte(fmt.Sprint(rec)))
+			}
+			*ret = C.GoError_User
+		case PanicCategoryPanic:
+			*ret = C.GoError_Panic
 		default:
-			log.Printf("Panic in Go callback: %#v\n", rec)
-			debug.PrintStack()
+			if hook != nil {
+				hook(rec, debug.Stack())
+			}
 			*ret = C.GoError_Panic
 		}
 	}
@@ -114,12 +106,12 @@ type KVStore interface {
 	// Start must be less than end, or the Iterator is invalid.
 	// Iterator must be closed by caller.
 	// To iterate over entire domain, use store.Iterator(nil, nil)
-	Iterator(start, end []byte) dbm.Iterator
+	Iterator(start, end []byte) Iterator
 
 	// Iterator over a domain of keys in descending order. End is exclusive.
 	// Start must be less than end, or the Iterator is invalid.
 	// Iterator must be closed by caller.
-	ReverseIterator(start, end []byte) dbm.Iterator
+	ReverseIterator(start, end []byte) Iterator
 }
 
 var db_vtable = C.Db_vtable{
@@ -130,23 +122,41 @@ var db_vtable = C.Db_vtable{
 }
 
 type DBState struct {
-	Store KVStore
+	Store KVStoreWithErrors
 	// CallID is used to lookup the proper frame for iterators associated with this contract call (iterator.go)
 	CallID uint64
+	// Limits enforces the key and value length limits. Optional.
+	Limits *storageGuard
+	// Access records the read and write set of the call. Optional, see CallOutput.RecordAccess.
+	Access *AccessSet
+	// Commitment hashes the writes and the response of the call. Optional, see CallOutput.CommitWrites.
+	Commitment *writeCommitment
 }
 
 // use this to create C.Db in two steps, so the pointer lives as long as the calling stack
 
-// state := buildDBState(kv, callID)
+// state := buildDBState(kv, callID, cache.storage, out)
 // db := buildDB(&state, &gasMeter)
 // // then pass db into some FFI function
-func buildDBState(kv KVStore, callID uint64) DBState {
+func buildDBState(kv KVStore, callID uint64, limits *storageGuard, out *CallOutput) DBState {
+	access, commitment := out.start()
 	return DBState{
-		Store:  kv,
-		CallID: callID,
+		Store:      AdaptKVStore(kv),
+		CallID:     callID,
+		Limits:     limits,
+		Access:     access,
+		Commitment: commitment,
 	}
 }
 
+// dbCallID returns the call ID of the DBState behind ptr or 0 for a nil pointer
+func dbCallID(ptr *C.db_t) uint64 {
+	if ptr == nil {
+		return 0
+	}
+	return (*DBState)(unsafe.Pointer(ptr)).CallID
+}
+
 // contract: original pointer/struct referenced must live longer than C.Db struct
 // since this is only used internally, we can verify the code that this is the case
 func buildDB(state *DBState, gm *GasMeter) C.Db {
@@ -168,7 +178,7 @@ const frameLenLimit = 32768
 
 // contract: original pointer/struct referenced must live longer than C.Db struct
 // since this is only used internally, we can verify the code that this is the case
-func buildIterator(callID uint64, it dbm.Iterator) (C.iterator_t, error) {
+func buildIterator(callID uint64, it Iterator) (C.iterator_t, error) {
 	idx, err := storeIterator(callID, it, frameLenLimit)
 	if err != nil {
 		return C.iterator_t{}, err
@@ -181,7 +191,7 @@ func buildIterator(callID uint64, it dbm.Iterator) (C.iterator_t, error) {
 
 //export cGet
 func cGet(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *cu64, key C.U8SliceView, val *C.UnmanagedVector, errOut *C.UnmanagedVector) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, dbCallID(ptr), types.GasLocationStorageRead)
 
 	if ptr == nil || gasMeter == nil || usedGas == nil || val == nil || errOut == nil {
 		// we received an invalid pointer
@@ -192,16 +202,32 @@ func cGet(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *cu64, key C.U8SliceView
 	}
 
 	gm := *(*GasMeter)(unsafe.Pointer(gasMeter))
-	kv := *(*KVStore)(unsafe.Pointer(ptr))
+	state := (*DBState)(unsafe.Pointer(ptr))
+	kv := state.Store
 	k := copyU8Slice(key)
+	dumpPayload(state.CallID, PayloadFromVM, "read_db.key", k)
+	if err := state.Limits.checkKey(k); err != nil {
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
+
+	if state.Access != nil {
+		state.Access.recordRead(k)
+	}
 
 	gasBefore := gm.GasConsumed()
-	v := kv.Get(k)
+	v, err := kv.Get(k)
 	gasAfter := gm.GasConsumed()
 	*usedGas = (cu64)(gasAfter - gasBefore)
+	if err != nil {
+		// store the actual error message in the return buffer
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
 
 	// v will equal nil when the key is missing
 	// https://github.com/line/lbm-sdk/blob/786df84b8e0aaa0a1aff79ffbab0541e597ee004/store/types/store.go#L203
+	dumpPayload(state.CallID, PayloadToVM, "read_db.value", v)
 	*val = newUnmanagedVector(v)
 
 	return C.GoError_None
@@ -209,7 +235,7 @@ func cGet(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *cu64, key C.U8SliceView
 
 //export cSet
 func cSet(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, key C.U8SliceView, val C.U8SliceView, errOut *C.UnmanagedVector) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, dbCallID(ptr), types.GasLocationStorageWrite)
 
 	if ptr == nil || gasMeter == nil || usedGas == nil || errOut == nil {
 		// we received an invalid pointer
@@ -220,21 +246,41 @@ func cSet(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, key C.U8Sli
 	}
 
 	gm := *(*GasMeter)(unsafe.Pointer(gasMeter))
-	kv := *(*KVStore)(unsafe.Pointer(ptr))
+	state := (*DBState)(unsafe.Pointer(ptr))
+	kv := state.Store
 	k := copyU8Slice(key)
 	v := copyU8Slice(val)
+	dumpPayload(state.CallID, PayloadFromVM, "write_db.key", k)
+	dumpPayload(state.CallID, PayloadFromVM, "write_db.value", v)
+	if err := state.Limits.checkKey(k); err != nil {
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
+	if err := state.Limits.checkValue(v); err != nil {
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
 
 	gasBefore := gm.GasConsumed()
-	kv.Set(k, v)
+	err := kv.Set(k, v)
 	gasAfter := gm.GasConsumed()
 	*usedGas = (C.uint64_t)(gasAfter - gasBefore)
+	if err != nil {
+		// store the actual error message in the return buffer
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
+	if state.Access != nil {
+		state.Access.recordWrite(k, v, false)
+	}
+	state.Commitment.addSet(k, v)
 
 	return C.GoError_None
 }
 
 //export cDelete
 func cDelete(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, key C.U8SliceView, errOut *C.UnmanagedVector) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, dbCallID(ptr), types.GasLocationStorageWrite)
 
 	if ptr == nil || gasMeter == nil || usedGas == nil || errOut == nil {
 		// we received an invalid pointer
@@ -245,20 +291,35 @@ func cDelete(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, key C.U8
 	}
 
 	gm := *(*GasMeter)(unsafe.Pointer(gasMeter))
-	kv := *(*KVStore)(unsafe.Pointer(ptr))
+	state := (*DBState)(unsafe.Pointer(ptr))
+	kv := state.Store
 	k := copyU8Slice(key)
+	dumpPayload(state.CallID, PayloadFromVM, "remove_db.key", k)
+	if err := state.Limits.checkKey(k); err != nil {
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
 
 	gasBefore := gm.GasConsumed()
-	kv.Delete(k)
+	err := kv.Delete(k)
 	gasAfter := gm.GasConsumed()
 	*usedGas = (C.uint64_t)(gasAfter - gasBefore)
+	if err != nil {
+		// store the actual error message in the return buffer
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
+	if state.Access != nil {
+		state.Access.recordWrite(k, nil, true)
+	}
+	state.Commitment.addDelete(k)
 
 	return C.GoError_None
 }
 
 //export cScan
 func cScan(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, start C.U8SliceView, end C.U8SliceView, order ci32, out *C.GoIter, errOut *C.UnmanagedVector) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, dbCallID(ptr), types.GasLocationStorageRead)
 
 	if ptr == nil || gasMeter == nil || usedGas == nil || out == nil || errOut == nil {
 		// we received an invalid pointer
@@ -273,19 +334,30 @@ func cScan(ptr *C.db_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, start C.U8
 	kv := state.Store
 	s := copyU8Slice(start)
 	e := copyU8Slice(end)
+	dumpPayload(state.CallID, PayloadFromVM, "scan_db.start", s)
+	dumpPayload(state.CallID, PayloadFromVM, "scan_db.end", e)
 
-	var iter dbm.Iterator
+	var iter Iterator
+	var err error
 	gasBefore := gm.GasConsumed()
 	switch order {
 	case 1: // Ascending
-		iter = kv.Iterator(s, e)
+		iter, err = kv.Iterator(s, e)
 	case 2: // Descending
-		iter = kv.ReverseIterator(s, e)
+		iter, err = kv.ReverseIterator(s, e)
 	default:
 		return C.GoError_BadArgument
 	}
 	gasAfter := gm.GasConsumed()
 	*usedGas = (C.uint64_t)(gasAfter - gasBefore)
+	if err != nil {
+		// store the actual error message in the return buffer
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
+	if state.Access != nil {
+		iter = state.Access.recordRange(s, e, order == 2, iter)
+	}
 
 	cIterator, err := buildIterator(state.CallID, iter)
 	if err != nil {
@@ -307,7 +379,7 @@ func cNext(ref C.iterator_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, key *
 	// 		...
 	// 	}
 
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, uint64(ref.call_id), types.GasLocationStorageRead)
 	if ref.call_id == 0 || gasMeter == nil || usedGas == nil || key == nil || val == nil || errOut == nil {
 		// we received an invalid pointer
 		return C.GoError_BadArgument
@@ -330,11 +402,17 @@ func cNext(ref C.iterator_t, gasMeter *C.gas_meter_t, usedGas *C.uint64_t, key *
 	// call Next at the end, upon creation we have first data loaded
 	k := iter.Key()
 	v := iter.Value()
-	// check iter.Error() ????
 	iter.Next()
 	gasAfter := gm.GasConsumed()
 	*usedGas = (C.uint64_t)(gasAfter - gasBefore)
+	if err := iter.Error(); err != nil {
+		// store the actual error message in the return buffer
+		*errOut = newUnmanagedVector([]byte(err.Error()))
+		return C.GoError_User
+	}
 
+	dumpPayload(uint64(ref.call_id), PayloadToVM, "next_db.key", k)
+	dumpPayload(uint64(ref.call_id), PayloadToVM, "next_db.value", v)
 	*key = newUnmanagedVector(k)
 	*val = newUnmanagedVector(v)
 	return C.GoError_None
@@ -357,18 +435,40 @@ var api_vtable = C.GoApi_vtable{
 	canonicalize_address: (C.canonicalize_address_fn)(C.cCanonicalAddress_cgo),
 }
 
+type APIState struct {
+	API *GoAPI
+	// CallID is used to report where the contract call ran out of gas
+	CallID uint64
+}
+
+// use this to create C.GoApi in two steps, so the pointer lives as long as the calling stack
+func buildAPIState(api *GoAPI, callID uint64) APIState {
+	return APIState{
+		API:    api,
+		CallID: callID,
+	}
+}
+
+// apiCallID returns the call ID of the APIState behind ptr or 0 for a nil pointer
+func apiCallID(ptr *C.api_t) uint64 {
+	if ptr == nil {
+		return 0
+	}
+	return (*APIState)(unsafe.Pointer(ptr)).CallID
+}
+
 // contract: original pointer/struct referenced must live longer than C.GoApi struct
 // since this is only used internally, we can verify the code that this is the case
-func buildAPI(api *GoAPI) C.GoApi {
+func buildAPI(state *APIState) C.GoApi {
 	return C.GoApi{
-		state:  (*C.api_t)(unsafe.Pointer(api)),
+		state:  (*C.api_t)(unsafe.Pointer(state)),
 		vtable: api_vtable,
 	}
 }
 
 //export cHumanAddress
 func cHumanAddress(ptr *C.api_t, src C.U8SliceView, dest *C.UnmanagedVector, errOut *C.UnmanagedVector, used_gas *cu64) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, apiCallID(ptr), types.GasLocationAddressAPI)
 
 	if dest == nil || errOut == nil {
 		return C.GoError_BadArgument
@@ -377,8 +477,10 @@ func cHumanAddress(ptr *C.api_t, src C.U8SliceView, dest *C.UnmanagedVector, err
 		panic("Got a non-none UnmanagedVector we're about to override. This is a bug because someone has to drop the old one.")
 	}
 
-	api := (*GoAPI)(unsafe.Pointer(ptr))
+	state := (*APIState)(unsafe.Pointer(ptr))
+	api := state.API
 	s := copyU8Slice(src)
+	dumpPayload(state.CallID, PayloadFromVM, "humanize_address.source", s)
 
 	h, cost, err := api.HumanAddress(s)
 	*used_gas = cu64(cost)
@@ -390,13 +492,14 @@ func cHumanAddress(ptr *C.api_t, src C.U8SliceView, dest *C.UnmanagedVector, err
 	if len(h) == 0 {
 		panic(fmt.Sprintf("`api.HumanAddress()` returned an empty string for %q", s))
 	}
+	dumpPayload(state.CallID, PayloadToVM, "humanize_address.result", []byte(h))
 	*dest = newUnmanagedVector([]byte(h))
 	return C.GoError_None
 }
 
 //export cCanonicalAddress
 func cCanonicalAddress(ptr *C.api_t, src C.U8SliceView, dest *C.UnmanagedVector, errOut *C.UnmanagedVector, used_gas *cu64) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, apiCallID(ptr), types.GasLocationAddressAPI)
 
 	if dest == nil || errOut == nil {
 		return C.GoError_BadArgument
@@ -405,8 +508,11 @@ func cCanonicalAddress(ptr *C.api_t, src C.U8SliceView, dest *C.UnmanagedVector,
 		panic("Got a non-none UnmanagedVector we're about to override. This is a bug because someone has to drop the old one.")
 	}
 
-	api := (*GoAPI)(unsafe.Pointer(ptr))
-	s := string(copyU8Slice(src))
+	state := (*APIState)(unsafe.Pointer(ptr))
+	api := state.API
+	human := copyU8Slice(src)
+	dumpPayload(state.CallID, PayloadFromVM, "canonicalize_address.source", human)
+	s := string(human)
 	c, cost, err := api.CanonicalAddress(s)
 	*used_gas = cu64(cost)
 	if err != nil {
@@ -417,6 +523,7 @@ func cCanonicalAddress(ptr *C.api_t, src C.U8SliceView, dest *C.UnmanagedVector,
 	if len(c) == 0 {
 		panic(fmt.Sprintf("`api.CanonicalAddress()` returned an empty string for %q", s))
 	}
+	dumpPayload(state.CallID, PayloadToVM, "canonicalize_address.result", c)
 	*dest = newUnmanagedVector(c)
 	return C.GoError_None
 }
@@ -427,18 +534,40 @@ var querier_vtable = C.Querier_vtable{
 	query_external: (C.query_external_fn)(C.cQueryExternal_cgo),
 }
 
+type QuerierState struct {
+	Querier *Querier
+	// CallID is used to report where the contract call ran out of gas
+	CallID uint64
+}
+
+// use this to create C.GoQuerier in two steps, so the pointer lives as long as the calling stack
+func buildQuerierState(q *Querier, callID uint64) QuerierState {
+	return QuerierState{
+		Querier: q,
+		CallID:  callID,
+	}
+}
+
+// querierCallID returns the call ID of the QuerierState behind ptr or 0 for a nil pointer
+func querierCallID(ptr *C.querier_t) uint64 {
+	if ptr == nil {
+		return 0
+	}
+	return (*QuerierState)(unsafe.Pointer(ptr)).CallID
+}
+
 // contract: original pointer/struct referenced must live longer than C.GoQuerier struct
 // since this is only used internally, we can verify the code that this is the case
-func buildQuerier(q *Querier) C.GoQuerier {
+func buildQuerier(state *QuerierState) C.GoQuerier {
 	return C.GoQuerier{
-		state:  (*C.querier_t)(unsafe.Pointer(q)),
+		state:  (*C.querier_t)(unsafe.Pointer(state)),
 		vtable: querier_vtable,
 	}
 }
 
 //export cQueryExternal
 func cQueryExternal(ptr *C.querier_t, gasLimit C.uint64_t, usedGas *C.uint64_t, request C.U8SliceView, result *C.UnmanagedVector, errOut *C.UnmanagedVector) (ret C.GoError) {
-	defer recoverPanic(&ret)
+	defer recoverPanic(&ret, errOut, querierCallID(ptr), types.GasLocationQuery)
 
 	if ptr == nil || usedGas == nil || result == nil || errOut == nil {
 		// we received an invalid pointer
@@ -449,8 +578,10 @@ func cQueryExternal(ptr *C.querier_t, gasLimit C.uint64_t, usedGas *C.uint64_t,
 	}
 
 	// query the data
-	querier := *(*Querier)(unsafe.Pointer(ptr))
+	state := (*QuerierState)(unsafe.Pointer(ptr))
+	querier := *state.Querier
 	req := copyU8Slice(request)
+	dumpPayload(state.CallID, PayloadFromVM, "query_external.request", req)
 
 	gasBefore := querier.GasConsumed()
 	res := types.RustQuery(querier, req, uint64(gasLimit))
@@ -463,6 +594,7 @@ func cQueryExternal(ptr *C.querier_t, gasLimit C.uint64_t, usedGas *C.uint64_t,
 		*errOut = newUnmanagedVector([]byte(err.Error()))
 		return C.GoError_CannotSerialize
 	}
+	dumpPayload(state.CallID, PayloadToVM, "query_external.result", bz)
 	*result = newUnmanagedVector(bz)
 	return C.GoError_None
 }
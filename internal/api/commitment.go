@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// Record tags of the commitment encoding
+const (
+	commitmentTagSet      byte = 1
+	commitmentTagDelete   byte = 2
+	commitmentTagResponse byte = 3
+)
+
+// commitmentDomain is hashed first to separate write commitments from other SHA-256 hashes
+const commitmentDomain = "wasmvm/write-commitment/v1"
+
+// writeCommitment is a SHA-256 hash over the writes and deletes of a contract call and its
+// response bytes, in the order they happened. Two nodes executing the same call on the same state
+// must get the same commitment, so comparing it detects divergence before it shows up in the app hash.
+//
+// Every record is a tag byte followed by its length-prefixed fields (8 byte big endian lengths):
+// a write is 0x01 | key | value, a delete is 0x02 | key and a response is 0x03 | bytes.
+// Writes that fail in the store are not included. The commitment is only returned for successful
+// calls (see CallOutput), so writes of failed calls never end up in a commitment.
+type writeCommitment struct {
+	hash hash.Hash
+}
+
+func newWriteCommitment() *writeCommitment {
+	h := sha256.New()
+	h.Write([]byte(commitmentDomain))
+	return &writeCommitment{hash: h}
+}
+
+// sum returns the commitment over all records so far
+func (c *writeCommitment) sum() []byte {
+	return c.hash.Sum(nil)
+}
+
+func (c *writeCommitment) addRecord(tag byte, fields ...[]byte) {
+	if c == nil {
+		return
+	}
+	c.hash.Write([]byte{tag})
+	var length [8]byte
+	for _, field := range fields {
+		binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+		c.hash.Write(length[:])
+		c.hash.Write(field)
+	}
+}
+
+func (c *writeCommitment) addSet(key, value []byte) {
+	c.addRecord(commitmentTagSet, key, value)
+}
+
+func (c *writeCommitment) addDelete(key []byte) {
+	c.addRecord(commitmentTagDelete, key)
+}
+
+func (c *writeCommitment) addResponse(data []byte) {
+	c.addRecord(commitmentTagResponse, data)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+func TestWriteCommitment(t *testing.T) {
+	build := func(records func(c *writeCommitment)) []byte {
+		c := newWriteCommitment()
+		records(c)
+		return c.sum()
+	}
+
+	base := func(c *writeCommitment) {
+		c.addSet([]byte("foo"), []byte("bar"))
+		c.addDelete([]byte("baz"))
+		c.addResponse([]byte(`{"ok":{}}`))
+	}
+	sum := build(base)
+	// the encoding must never change, nodes running different versions have to agree
+	assert.Equal(t, "2eb8e1840a4e5be24329cf88c2aa4d679cafcead623837d7f10d1b8eb0059bf7", hex.EncodeToString(sum))
+	assert.Equal(t, sum, build(base))
+
+	// order matters
+	assert.NotEqual(t, sum, build(func(c *writeCommitment) {
+		c.addDelete([]byte("baz"))
+		c.addSet([]byte("foo"), []byte("bar"))
+		c.addResponse([]byte(`{"ok":{}}`))
+	}))
+	// field boundaries are unambiguous
+	assert.NotEqual(t, build(func(c *writeCommitment) { c.addSet([]byte("ab"), []byte("c")) }),
+		build(func(c *writeCommitment) { c.addSet([]byte("a"), []byte("bc")) }))
+	// an empty value is different from a delete
+	assert.NotEqual(t, build(func(c *writeCommitment) { c.addSet([]byte("a"), []byte{}) }),
+		build(func(c *writeCommitment) { c.addDelete([]byte("a")) }))
+	// the response is included
+	assert.NotEqual(t, build(func(c *writeCommitment) { c.addResponse([]byte("a")) }),
+		build(func(c *writeCommitment) { c.addResponse([]byte("b")) }))
+
+	// a nil commitment ignores all records
+	var none *writeCommitment
+	none.addSet([]byte("foo"), []byte("bar"))
+	none.addResponse(nil)
+}
+
+func TestExecuteWriteCommitment(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+
+	run := func() []byte {
+		setup := setupQueueContract(t, cache)
+		gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+		igasMeter := GasMeter(gasMeter)
+		out := CallOutput{CommitWrites: true}
+		_, _, err := Execute(cache, setup.checksum, env, info, []byte(`{"enqueue":{"value":5}}`), &igasMeter, setup.Store(gasMeter), setup.api, &setup.querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, &out)
+		require.NoError(t, err)
+		assert.Nil(t, out.Access)
+		return out.Commitment
+	}
+	first := run()
+	assert.Equal(t, first, run())
+	assert.NotEqual(t, newWriteCommitment().sum(), first)
+
+	// a query does not write, but the response is included
+	setup := setupQueueContract(t, cache)
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	out := CallOutput{CommitWrites: true}
+	data, _, err := Query(cache, setup.checksum, env, []byte(`{"sum":{}}`), &igasMeter, setup.Store(gasMeter), setup.api, &setup.querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, &out)
+	require.NoError(t, err)
+	expected := newWriteCommitment()
+	expected.addResponse(data)
+	assert.Equal(t, expected.sum(), out.Commitment)
+}
+
+func TestFailedCallHasNoWriteCommitment(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	checksum := createTestContract(t, cache)
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	store := AdaptKVStoreWithErrors(NewMockFailureKVStore(NewLookup(gasMeter)))
+	api := NewMockAPI()
+	querier := DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{})
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+
+	out := CallOutput{CommitWrites: true, Commitment: []byte("previous call")}
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, &out)
+	require.Error(t, err)
+	assert.Nil(t, out.Commitment)
+}
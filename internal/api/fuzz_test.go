@@ -0,0 +1,146 @@
+//go:build go1.18
+// +build go1.18
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+// fuzzGasLimit keeps contracts that loop on fuzzed input short
+const fuzzGasLimit = 5_000_000_000
+
+// fuzzContract stores a test contract in a fresh cache and instantiates it. Fuzz iterations must run
+// on a clone of the returned store, so that inputs found by the fuzzer reproduce without the writes of
+// the iterations before.
+func fuzzContract(f *testing.F, wasmFile string, initMsg []byte) (Cache, []byte, *Lookup) {
+	tmpdir, err := ioutil.TempDir("", "wasmvm-fuzz")
+	require.NoError(f, err)
+	cache, err := InitCache(tmpdir, TESTING_FEATURES, TESTING_CACHE_SIZE, TESTING_MEMORY_LIMIT)
+	require.NoError(f, err)
+	f.Cleanup(func() {
+		ReleaseCache(cache)
+		os.RemoveAll(tmpdir)
+	})
+
+	wasm, err := ioutil.ReadFile(wasmFile)
+	require.NoError(f, err)
+	checksum, err := Create(cache, wasm)
+	require.NoError(f, err)
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	store := NewLookup(gasMeter)
+	var querier Querier = DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{types.NewCoin(100, "ATOM")})
+	env, err := json.Marshal(MockEnv())
+	require.NoError(f, err)
+	info, err := json.Marshal(MockInfo("creator", nil))
+	require.NoError(f, err)
+	res, _, err := Instantiate(cache, checksum, env, info, initMsg, &igasMeter, store, NewMockAPI(), &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.NoError(f, err)
+	var result types.ContractResult
+	require.NoError(f, json.Unmarshal(res, &result))
+	require.Empty(f, result.Err)
+	return cache, checksum, store
+}
+
+// requireNoCallState checks that a finished call left no iterators or out of gas records behind
+func requireNoCallState(t *testing.T) {
+	iteratorFramesMutex.Lock()
+	frames := len(iteratorFrames)
+	iteratorFramesMutex.Unlock()
+	require.Zero(t, frames, "leaked iterator frames")
+
+	outOfGasRecordsMutex.Lock()
+	records := len(outOfGasRecords)
+	outOfGasRecordsMutex.Unlock()
+	require.Zero(t, records, "leaked out of gas records")
+}
+
+// requireValidResult checks that the VM returned a result that can be decoded, or an error
+func requireValidResult(t *testing.T, res []byte, err error, result interface{}) {
+	if err != nil {
+		require.Nil(t, res)
+		return
+	}
+	require.NoError(t, json.Unmarshal(res, result), "invalid result %s", res)
+}
+
+// FuzzExecute sends arbitrary messages from arbitrary senders to hackatom
+func FuzzExecute(f *testing.F) {
+	cache, checksum, seeded := fuzzContract(f, "../../testdata/hackatom.wasm", []byte(`{"verifier": "fred", "beneficiary": "bob"}`))
+	f.Add([]byte(`{"release":{}}`), "fred")
+	f.Add([]byte(`{"release":{}}`), "mallory")
+	f.Add([]byte(`{"panic":{}}`), "fred")
+	f.Add([]byte(`{"user_errors_in_api_calls":{}}`), "fred")
+	f.Add([]byte(`{"cpu_loop":{}}`), "fred")
+	f.Add([]byte(`{"storage_loop":{}}`), "fred")
+	f.Add([]byte(`{"allocate_large_memory":{"pages":48}}`), "fred")
+	f.Add([]byte(`{"message_loop":{}}`), "")
+	f.Add([]byte(`{`), "fred")
+	f.Add([]byte{}, "fred")
+
+	f.Fuzz(func(t *testing.T, msg []byte, sender string) {
+		gasMeter := NewMockGasMeter(fuzzGasLimit)
+		igasMeter := GasMeter(gasMeter)
+		store := seeded.Clone(gasMeter)
+		var querier Querier = DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{types.NewCoin(100, "ATOM")})
+		env, err := json.Marshal(MockEnv())
+		require.NoError(t, err)
+		info, err := json.Marshal(MockInfo(sender, nil))
+		require.NoError(t, err)
+
+		res, _, err := Execute(cache, checksum, env, info, msg, &igasMeter, store, NewMockAPI(), &querier, fuzzGasLimit, TESTING_PRINT_DEBUG, nil)
+		var result types.ContractResult
+		requireValidResult(t, res, err, &result)
+		requireNoCallState(t)
+	})
+}
+
+// FuzzQuery sends arbitrary queries to queue, which iterates over its storage
+func FuzzQuery(f *testing.F) {
+	cache, checksum, seeded := fuzzContract(f, "../../testdata/queue.wasm", []byte(`{}`))
+	for _, value := range []int{7, 11, 13} {
+		gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+		igasMeter := GasMeter(gasMeter)
+		seeded.SetGasMeter(gasMeter)
+		var querier Querier = DefaultQuerier(MOCK_CONTRACT_ADDR, nil)
+		env, err := json.Marshal(MockEnv())
+		require.NoError(f, err)
+		info, err := json.Marshal(MockInfo("creator", nil))
+		require.NoError(f, err)
+		msg, err := json.Marshal(map[string]interface{}{"enqueue": map[string]int{"value": value}})
+		require.NoError(f, err)
+		_, _, err = Execute(cache, checksum, env, info, msg, &igasMeter, seeded, NewMockAPI(), &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+		require.NoError(f, err)
+	}
+
+	f.Add([]byte(`{"count":{}}`))
+	f.Add([]byte(`{"sum":{}}`))
+	f.Add([]byte(`{"reducer":{}}`))
+	f.Add([]byte(`{"list":{}}`))
+	f.Add([]byte(`{"open_iterators":{"count":5}}`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte{0xff})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		gasMeter := NewMockGasMeter(fuzzGasLimit)
+		igasMeter := GasMeter(gasMeter)
+		store := seeded.Clone(gasMeter)
+		var querier Querier = DefaultQuerier(MOCK_CONTRACT_ADDR, nil)
+		env, err := json.Marshal(MockEnv())
+		require.NoError(t, err)
+
+		res, _, err := Query(cache, checksum, env, msg, &igasMeter, store, NewMockAPI(), &querier, fuzzGasLimit, TESTING_PRINT_DEBUG, nil)
+		var result types.QueryResponse
+		requireValidResult(t, res, err, &result)
+		requireNoCallState(t)
+	})
+}
@@ -0,0 +1,180 @@
+package api
+
+// Gas consumption descriptors, the same as in lbm-sdk store/types/gas.go
+const (
+	GasIterNextCostFlatDesc = "IterNextFlat"
+	GasValuePerByteDesc     = "ValuePerByte"
+	GasWritePerByteDesc     = "WritePerByte"
+	GasReadPerByteDesc      = "ReadPerByte"
+	GasWriteCostFlatDesc    = "WriteFlat"
+	GasReadCostFlatDesc     = "ReadFlat"
+	GasHasDesc              = "Has"
+	GasDeleteDesc           = "Delete"
+)
+
+// KVGasConfig defines gas cost for each operation on a GasKVStore.
+// This is a copy of KVGasConfig from lbm-sdk store/types/gas.go
+type KVGasConfig struct {
+	HasCost          Gas
+	DeleteCost       Gas
+	ReadCostFlat     Gas
+	ReadCostPerByte  Gas
+	WriteCostFlat    Gas
+	WriteCostPerByte Gas
+	IterNextCostFlat Gas
+}
+
+// DefaultKVGasConfig returns the default gas config of the SDK for KVStores
+func DefaultKVGasConfig() KVGasConfig {
+	return KVGasConfig{
+		HasCost:          1000,
+		DeleteCost:       1000,
+		ReadCostFlat:     1000,
+		ReadCostPerByte:  3,
+		WriteCostFlat:    2000,
+		WriteCostPerByte: 30,
+		IterNextCostFlat: 30,
+	}
+}
+
+// GasKVStore wraps a KVStore and consumes gas for each operation like the gaskv store of the SDK.
+// This is useful for hosts that are not built on the SDK, e.g. tests and simulators, where the
+// parent store does not charge gas itself.
+type GasKVStore struct {
+	gasMeter  FullGasMeter
+	gasConfig KVGasConfig
+	parent    KVStore
+}
+
+var _ MeteredStore = (*GasKVStore)(nil)
+
+// NewGasKVStore returns a store consuming gas on gasMeter for all operations on parent
+func NewGasKVStore(parent KVStore, gasMeter FullGasMeter, gasConfig KVGasConfig) *GasKVStore {
+	return &GasKVStore{
+		gasMeter:  gasMeter,
+		gasConfig: gasConfig,
+		parent:    parent,
+	}
+}
+
+// GasMeter returns the gas meter the store consumes from
+func (gs *GasKVStore) GasMeter() FullGasMeter {
+	return gs.gasMeter
+}
+
+// Unmetered returns the parent store
+func (gs *GasKVStore) Unmetered() KVStore {
+	return gs.parent
+}
+
+// Metered returns a store consuming gas like this one for all operations on parent
+func (gs *GasKVStore) Metered(parent KVStore) KVStore {
+	return NewGasKVStore(parent, gs.gasMeter, gs.gasConfig)
+}
+
+func (gs *GasKVStore) Get(key []byte) []byte {
+	gs.gasMeter.ConsumeGas(gs.gasConfig.ReadCostFlat, GasReadCostFlatDesc)
+	value := gs.parent.Get(key)
+
+	gs.gasMeter.ConsumeGas(gs.gasConfig.ReadCostPerByte*Gas(len(key)), GasReadPerByteDesc)
+	gs.gasMeter.ConsumeGas(gs.gasConfig.ReadCostPerByte*Gas(len(value)), GasReadPerByteDesc)
+	return value
+}
+
+func (gs *GasKVStore) Set(key, value []byte) {
+	gs.gasMeter.ConsumeGas(gs.gasConfig.WriteCostFlat, GasWriteCostFlatDesc)
+	gs.gasMeter.ConsumeGas(gs.gasConfig.WriteCostPerByte*Gas(len(key)), GasWritePerByteDesc)
+	gs.gasMeter.ConsumeGas(gs.gasConfig.WriteCostPerByte*Gas(len(value)), GasWritePerByteDesc)
+	gs.parent.Set(key, value)
+}
+
+// Has returns true if the key exists. The parent's Get is used, but only the flat has cost is charged.
+func (gs *GasKVStore) Has(key []byte) bool {
+	gs.gasMeter.ConsumeGas(gs.gasConfig.HasCost, GasHasDesc)
+	return gs.parent.Get(key) != nil
+}
+
+func (gs *GasKVStore) Delete(key []byte) {
+	gs.gasMeter.ConsumeGas(gs.gasConfig.DeleteCost, GasDeleteDesc)
+	gs.parent.Delete(key)
+}
+
+func (gs *GasKVStore) Iterator(start, end []byte) Iterator {
+	return gs.iterator(start, end, true)
+}
+
+func (gs *GasKVStore) ReverseIterator(start, end []byte) Iterator {
+	return gs.iterator(start, end, false)
+}
+
+func (gs *GasKVStore) iterator(start, end []byte, ascending bool) Iterator {
+	var parent Iterator
+	if ascending {
+		parent = gs.parent.Iterator(start, end)
+	} else {
+		parent = gs.parent.ReverseIterator(start, end)
+	}
+
+	gi := newGasIterator(gs.gasMeter, gs.gasConfig, parent)
+	gi.consumeSeekGas()
+	return gi
+}
+
+type gasIterator struct {
+	gasMeter  FullGasMeter
+	gasConfig KVGasConfig
+	parent    Iterator
+}
+
+var _ Iterator = (*gasIterator)(nil)
+
+func newGasIterator(gasMeter FullGasMeter, gasConfig KVGasConfig, parent Iterator) *gasIterator {
+	return &gasIterator{
+		gasMeter:  gasMeter,
+		gasConfig: gasConfig,
+		parent:    parent,
+	}
+}
+
+func (gi *gasIterator) Domain() (start []byte, end []byte) {
+	return gi.parent.Domain()
+}
+
+func (gi *gasIterator) Valid() bool {
+	return gi.parent.Valid()
+}
+
+// Next moves the iterator to the next element and consumes gas for reading it
+func (gi *gasIterator) Next() {
+	gi.parent.Next()
+	gi.consumeSeekGas()
+}
+
+func (gi *gasIterator) Key() []byte {
+	return gi.parent.Key()
+}
+
+func (gi *gasIterator) Value() []byte {
+	return gi.parent.Value()
+}
+
+func (gi *gasIterator) Close() error {
+	return gi.parent.Close()
+}
+
+func (gi *gasIterator) Error() error {
+	return gi.parent.Error()
+}
+
+// consumeSeekGas consumes the flat cost of a seek and the per byte read cost of the
+// current key and value
+func (gi *gasIterator) consumeSeekGas() {
+	if gi.Valid() {
+		key := gi.Key()
+		value := gi.Value()
+
+		gi.gasMeter.ConsumeGas(gi.gasConfig.ReadCostPerByte*Gas(len(key)), GasValuePerByteDesc)
+		gi.gasMeter.ConsumeGas(gi.gasConfig.ReadCostPerByte*Gas(len(value)), GasValuePerByteDesc)
+	}
+	gi.gasMeter.ConsumeGas(gi.gasConfig.IterNextCostFlat, GasIterNextCostFlatDesc)
+}
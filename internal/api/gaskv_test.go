@@ -0,0 +1,82 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasMeter(t *testing.T) {
+	meter := NewGasMeter(1000)
+	meter.ConsumeGas(400, "foo")
+	assert.Equal(t, Gas(400), meter.GasConsumed())
+	assert.False(t, meter.IsOutOfGas())
+	meter.RefundGas(100, "foo")
+	assert.Equal(t, Gas(300), meter.GasConsumed())
+	assert.PanicsWithValue(t, ErrorNegativeGasConsumed{Descriptor: "bar"}, func() { meter.RefundGas(301, "bar") })
+
+	meter.ConsumeGas(700, "foo")
+	assert.True(t, meter.IsOutOfGas())
+	assert.False(t, meter.IsPastLimit())
+	assert.PanicsWithValue(t, ErrorOutOfGas{Descriptor: "bar"}, func() { meter.ConsumeGas(1, "bar") })
+	assert.True(t, meter.IsPastLimit())
+	assert.Equal(t, Gas(1000), meter.GasConsumedToLimit())
+	assert.Equal(t, Gas(1001), meter.GasConsumed())
+
+	infinite := NewInfiniteGasMeter()
+	infinite.ConsumeGas(1<<63, "foo")
+	assert.False(t, infinite.IsOutOfGas())
+	assert.PanicsWithValue(t, ErrorGasOverflow{Descriptor: "bar"}, func() { infinite.ConsumeGas(1<<63, "bar") })
+}
+
+func TestGasKVStore(t *testing.T) {
+	config := DefaultKVGasConfig()
+	meter := NewGasMeter(100_000)
+	store := NewGasKVStore(NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT)), meter, config)
+
+	key := []byte("foo")
+	value := []byte("some value")
+
+	store.Set(key, value)
+	expected := config.WriteCostFlat + config.WriteCostPerByte*Gas(len(key)+len(value))
+	assert.Equal(t, expected, meter.GasConsumed())
+
+	assert.Equal(t, value, store.Get(key))
+	expected += config.ReadCostFlat + config.ReadCostPerByte*Gas(len(key)+len(value))
+	assert.Equal(t, expected, meter.GasConsumed())
+
+	// missing keys only charge the key
+	assert.Nil(t, store.Get([]byte("bar")))
+	expected += config.ReadCostFlat + config.ReadCostPerByte*3
+	assert.Equal(t, expected, meter.GasConsumed())
+
+	assert.True(t, store.Has(key))
+	expected += config.HasCost
+	assert.Equal(t, expected, meter.GasConsumed())
+
+	// iteration charges the seek to each element including the end
+	store.Set([]byte("foo2"), []byte("v"))
+	expected += config.WriteCostFlat + config.WriteCostPerByte*5
+	it := store.Iterator(nil, nil)
+	expected += config.IterNextCostFlat + config.ReadCostPerByte*Gas(len(key)+len(value))
+	assert.Equal(t, expected, meter.GasConsumed())
+	it.Next()
+	expected += config.IterNextCostFlat + config.ReadCostPerByte*5
+	assert.Equal(t, expected, meter.GasConsumed())
+	it.Next()
+	expected += config.IterNextCostFlat
+	assert.Equal(t, expected, meter.GasConsumed())
+	require.False(t, it.Valid())
+	require.NoError(t, it.Close())
+
+	store.Delete(key)
+	expected += config.DeleteCost
+	assert.Equal(t, expected, meter.GasConsumed())
+	assert.False(t, store.Has(key))
+
+	// runs out of gas
+	assert.PanicsWithValue(t, ErrorOutOfGas{Descriptor: GasWritePerByteDesc}, func() {
+		store.Set(key, make([]byte, 10_000))
+	})
+}
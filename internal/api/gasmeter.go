@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"math"
+)
+
+// ErrorNegativeGasConsumed defines an error thrown when the amount of gas refunded results in a
+// negative gas consumed amount.
+type ErrorNegativeGasConsumed struct {
+	Descriptor string
+}
+
+// FullGasMeter is a copy of the full GasMeter interface from lbm-sdk.
+// Defined in https://github.com/line/lbm-sdk/blob/main/store/types/gas.go
+// Gas meters of the SDK implement it, so they can be used with GasKVStore.
+type FullGasMeter interface {
+	GasMeter
+	GasConsumedToLimit() Gas
+	Limit() Gas
+	ConsumeGas(amount Gas, descriptor string)
+	RefundGas(amount Gas, descriptor string)
+	IsPastLimit() bool
+	IsOutOfGas() bool
+	String() string
+}
+
+// This code is borrowed from lbm-sdk store/types/gas.go
+
+type basicGasMeter struct {
+	limit    Gas
+	consumed Gas
+}
+
+var _ FullGasMeter = (*basicGasMeter)(nil)
+
+// NewGasMeter returns a gas meter that panics with ErrorOutOfGas when more than limit is consumed
+func NewGasMeter(limit Gas) FullGasMeter {
+	return &basicGasMeter{
+		limit:    limit,
+		consumed: 0,
+	}
+}
+
+func (g *basicGasMeter) GasConsumed() Gas {
+	return g.consumed
+}
+
+func (g *basicGasMeter) Limit() Gas {
+	return g.limit
+}
+
+func (g *basicGasMeter) GasConsumedToLimit() Gas {
+	if g.IsPastLimit() {
+		return g.limit
+	}
+	return g.consumed
+}
+
+func (g *basicGasMeter) ConsumeGas(amount Gas, descriptor string) {
+	var overflow bool
+	g.consumed, overflow = addUint64Overflow(g.consumed, amount)
+	if overflow {
+		g.consumed = math.MaxUint64
+		panic(ErrorGasOverflow{descriptor})
+	}
+
+	if g.consumed > g.limit {
+		panic(ErrorOutOfGas{descriptor})
+	}
+}
+
+// RefundGas will deduct the given amount from the gas consumed. If the amount is greater than the
+// gas consumed, the function will panic.
+func (g *basicGasMeter) RefundGas(amount Gas, descriptor string) {
+	if g.consumed < amount {
+		panic(ErrorNegativeGasConsumed{Descriptor: descriptor})
+	}
+	g.consumed -= amount
+}
+
+func (g *basicGasMeter) IsPastLimit() bool {
+	return g.consumed > g.limit
+}
+
+func (g *basicGasMeter) IsOutOfGas() bool {
+	return g.consumed >= g.limit
+}
+
+func (g *basicGasMeter) String() string {
+	return fmt.Sprintf("BasicGasMeter:\n  limit: %d\n  consumed: %d", g.limit, g.consumed)
+}
+
+type infiniteGasMeter struct {
+	consumed Gas
+}
+
+var _ FullGasMeter = (*infiniteGasMeter)(nil)
+
+// NewInfiniteGasMeter returns a gas meter without a limit
+func NewInfiniteGasMeter() FullGasMeter {
+	return &infiniteGasMeter{
+		consumed: 0,
+	}
+}
+
+func (g *infiniteGasMeter) GasConsumed() Gas {
+	return g.consumed
+}
+
+func (g *infiniteGasMeter) GasConsumedToLimit() Gas {
+	return g.consumed
+}
+
+func (g *infiniteGasMeter) Limit() Gas {
+	return 0
+}
+
+func (g *infiniteGasMeter) ConsumeGas(amount Gas, descriptor string) {
+	var overflow bool
+	g.consumed, overflow = addUint64Overflow(g.consumed, amount)
+	if overflow {
+		panic(ErrorGasOverflow{descriptor})
+	}
+}
+
+func (g *infiniteGasMeter) RefundGas(amount Gas, descriptor string) {
+	if g.consumed < amount {
+		panic(ErrorNegativeGasConsumed{Descriptor: descriptor})
+	}
+	g.consumed -= amount
+}
+
+func (g *infiniteGasMeter) IsPastLimit() bool {
+	return false
+}
+
+func (g *infiniteGasMeter) IsOutOfGas() bool {
+	return false
+}
+
+func (g *infiniteGasMeter) String() string {
+	return fmt.Sprintf("InfiniteGasMeter:\n  consumed: %d", g.consumed)
+}
@@ -3,12 +3,37 @@ package api
 import (
 	"fmt"
 	"sync"
-
-	dbm "github.com/tendermint/tm-db"
 )
 
+// Iterator is the iterator over a KVStore used by the VM. It has the same methods as the iterators
+// of tm-db and cosmos-db, so KVStore implementations can return those directly.
+type Iterator interface {
+	// Domain returns the start (inclusive) and end (exclusive) limits of the iterator.
+	Domain() (start []byte, end []byte)
+
+	// Valid returns whether the current iterator is valid. Once invalid, the Iterator remains
+	// invalid forever.
+	Valid() bool
+
+	// Next moves the iterator to the next key in the database, as defined by order of iteration.
+	// If Valid returns false, this method will panic.
+	Next()
+
+	// Key returns the key at the current position. Panics if the iterator is invalid.
+	Key() (key []byte)
+
+	// Value returns the value at the current position. Panics if the iterator is invalid.
+	Value() (value []byte)
+
+	// Error returns the last error encountered by the iterator, if any.
+	Error() error
+
+	// Close closes the iterator, relasing any allocated resources.
+	Close() error
+}
+
 // frame stores all Iterators for one contract call
-type frame []dbm.Iterator
+type frame []Iterator
 
 // iteratorFrames contains one frame for each contract call, indexed by contract call ID.
 var iteratorFrames = make(map[uint64]frame)
@@ -41,6 +66,10 @@ func removeFrame(callID uint64) frame {
 
 // endCall is called at the end of a contract call to remove one item the iteratorFrames
 func endCall(callID uint64) {
+	// drop the out of gas record in case the error was not created from it
+	_, _ = takeOutOfGas(callID)
+	// report the vectors received from Rust that were not destroyed
+	reportVectors(callID)
 	// we pull removeFrame in another function so we don't hold the mutex while cleaning up the removed frame
 	remove := removeFrame(callID)
 	// free all iterators in the frame when we release it
@@ -52,7 +81,7 @@ func endCall(callID uint64) {
 // storeIterator will add this to the end of the frame for the given ID and return a reference to it.
 // We start counting with 1, so the 0 value is flagged as an error. This means we must
 // remember to do idx-1 when retrieving
-func storeIterator(callID uint64, it dbm.Iterator, frameLenLimit int) (uint64, error) {
+func storeIterator(callID uint64, it Iterator, frameLenLimit int) (uint64, error) {
 	iteratorFramesMutex.Lock()
 	defer iteratorFramesMutex.Unlock()
 
@@ -71,7 +100,7 @@ func storeIterator(callID uint64, it dbm.Iterator, frameLenLimit int) (uint64, e
 // retrieveIterator will recover an iterator based on index. This ensures it will not be garbage collected.
 // We start counting with 1, in storeIterator so the 0 value is flagged as an error. This means we must
 // remember to do idx-1 when retrieving
-func retrieveIterator(callID uint64, index uint64) dbm.Iterator {
+func retrieveIterator(callID uint64, index uint64) Iterator {
 	iteratorFramesMutex.Lock()
 	defer iteratorFramesMutex.Unlock()
 	myFrame := iteratorFrames[callID]
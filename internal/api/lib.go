@@ -30,6 +30,8 @@ type cu8_ptr = *C.uint8_t
 
 type Cache struct {
 	ptr *C.cache_t
+	// storage enforces the storage limits, see SetStorageLimits
+	storage *storageGuard
 }
 
 type Querier = types.Querier
@@ -38,18 +40,19 @@ func InitCache(dataDir string, supportedFeatures string, cacheSize uint32, insta
 	dataDirBytes := []byte(dataDir)
 	supportedFeaturesBytes := []byte(supportedFeatures)
 
-	d := makeView(dataDirBytes)
+	d := dumpView(0, "data_dir", dataDirBytes)
 	defer runtime.KeepAlive(dataDirBytes)
-	f := makeView(supportedFeaturesBytes)
+	f := dumpView(0, "features", supportedFeaturesBytes)
 	defer runtime.KeepAlive(supportedFeaturesBytes)
 
 	errmsg := newUnmanagedVector(nil)
 
 	ptr, err := C.init_cache(d, f, cu32(cacheSize), cu32(instanceMemoryLimit), &errmsg)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return Cache{}, errorWithMessage(err, errmsg)
 	}
-	return Cache{ptr: ptr}, nil
+	return Cache{ptr: ptr, storage: newStorageGuard()}, nil
 }
 
 func ReleaseCache(cache Cache) {
@@ -57,10 +60,12 @@ func ReleaseCache(cache Cache) {
 }
 
 func Create(cache Cache, wasm []byte) ([]byte, error) {
-	w := makeView(wasm)
+	w := dumpView(0, "wasm", wasm)
 	defer runtime.KeepAlive(wasm)
 	errmsg := newUnmanagedVector(nil)
 	checksum, err := C.save_wasm(cache.ptr, w, &errmsg)
+	receivedVector(0, vectorChecksum, checksum)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return nil, errorWithMessage(err, errmsg)
 	}
@@ -68,10 +73,12 @@ func Create(cache Cache, wasm []byte) ([]byte, error) {
 }
 
 func GetCode(cache Cache, checksum []byte) ([]byte, error) {
-	cs := makeView(checksum)
+	cs := dumpView(0, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
 	errmsg := newUnmanagedVector(nil)
 	wasm, err := C.load_wasm(cache.ptr, cs, &errmsg)
+	receivedVector(0, vectorCode, wasm)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return nil, errorWithMessage(err, errmsg)
 	}
@@ -79,10 +86,11 @@ func GetCode(cache Cache, checksum []byte) ([]byte, error) {
 }
 
 func Pin(cache Cache, checksum []byte) error {
-	cs := makeView(checksum)
+	cs := dumpView(0, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
 	errmsg := newUnmanagedVector(nil)
 	_, err := C.pin(cache.ptr, cs, &errmsg)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return errorWithMessage(err, errmsg)
 	}
@@ -90,10 +98,11 @@ func Pin(cache Cache, checksum []byte) error {
 }
 
 func Unpin(cache Cache, checksum []byte) error {
-	cs := makeView(checksum)
+	cs := dumpView(0, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
 	errmsg := newUnmanagedVector(nil)
 	_, err := C.unpin(cache.ptr, cs, &errmsg)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return errorWithMessage(err, errmsg)
 	}
@@ -101,10 +110,12 @@ func Unpin(cache Cache, checksum []byte) error {
 }
 
 func AnalyzeCode(cache Cache, checksum []byte) (*types.AnalysisReport, error) {
-	cs := makeView(checksum)
+	cs := dumpView(0, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
 	errmsg := newUnmanagedVector(nil)
 	report, err := C.analyze_code(cache.ptr, cs, &errmsg)
+	receivedVector(0, vectorCapabilities, report.required_capabilities)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return nil, errorWithMessage(err, errmsg)
 	}
@@ -120,6 +131,7 @@ func AnalyzeCode(cache Cache, checksum []byte) (*types.AnalysisReport, error) {
 func GetMetrics(cache Cache) (*types.Metrics, error) {
 	errmsg := newUnmanagedVector(nil)
 	metrics, err := C.get_metrics(cache.ptr, &errmsg)
+	receivedVector(0, vectorErrorMessage, errmsg)
 	if err != nil {
 		return nil, errorWithMessage(err, errmsg)
 	}
@@ -133,6 +145,7 @@ func GetMetrics(cache Cache) (*types.Metrics, error) {
 		ElementsMemoryCache:       uint64(metrics.elements_memory_cache),
 		SizePinnedMemoryCache:     uint64(metrics.size_pinned_memory_cache),
 		SizeMemoryCache:           uint64(metrics.size_memory_cache),
+		Storage:                   cache.storage.metrics(),
 	}, nil
 }
 
@@ -148,32 +161,39 @@ func Instantiate(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	i := makeView(info)
+	i := dumpView(callID, "info", info)
 	defer runtime.KeepAlive(info)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.instantiate(cache.ptr, cs, e, i, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func Execute(
@@ -188,32 +208,143 @@ func Execute(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	i := makeView(info)
+	i := dumpView(callID, "info", info)
 	defer runtime.KeepAlive(info)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
+	dbState := buildDBState(store, callID, cache.storage, out)
+	db := buildDB(&dbState, gasMeter)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
+	var gasUsed cu64
+	errmsg := newUnmanagedVector(nil)
+
+	res, err := C.execute(cache.ptr, cs, e, i, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
+	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
+		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
+	}
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
+}
+
+// BatchCall is a single execute message in an ExecuteBatch call
+type BatchCall struct {
+	Env      []byte
+	Info     []byte
+	Msg      []byte
+	GasLimit uint64
+	// Store, API and Querier replace the ones passed to ExecuteBatch for this call if set,
+	// e.g. to charge each call with its own gas config.
+	Store   KVStore
+	API     *GoAPI
+	Querier *Querier
+	// Output requests optional outputs of this call, see CallOutput. Optional.
+	Output *CallOutput
+	// Finish is called with the result of this call before the next call starts, e.g. to commit
+	// its writes. Optional.
+	Finish func(result BatchCallResult)
+}
+
+// BatchCallResult holds the raw result of one BatchCall. The fields have the same
+// meaning as the return values of Execute.
+type BatchCallResult struct {
+	Data    []byte
+	GasUsed uint64
+	Err     error
+}
+
+// ExecuteBatch runs the given calls against the same contract code one after another.
+// The checksum view, the database, the API and the querier are only set up once and shared
+// by all calls. Each call still gets its own call ID, such that iterators do not outlive the
+// call that created them, and the result of each call is independent of the others except
+// for the state changes they leave in the store.
+func ExecuteBatch(
+	cache Cache,
+	checksum []byte,
+	calls []BatchCall,
+	gasMeter *GasMeter,
+	store KVStore,
+	api *GoAPI,
+	querier *Querier,
+	printDebug bool,
+) []BatchCallResult {
+	cs := dumpView(0, "checksum", checksum)
+	defer runtime.KeepAlive(checksum)
+
+	dbState := buildDBState(store, 0, cache.storage, nil)
+	db := buildDB(&dbState, gasMeter)
+	apiState := buildAPIState(api, 0)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, 0)
+	q := buildQuerier(&querierState)
+
+	results := make([]BatchCallResult, len(calls))
+	for idx, call := range calls {
+		results[idx] = executeInBatch(cache, cs, call, &dbState, &apiState, &querierState, db, a, q, printDebug)
+		if call.Finish != nil {
+			call.Finish(results[idx])
+		}
+	}
+	return results
+}
+
+func executeInBatch(cache Cache, cs C.ByteSliceView, call BatchCall, dbState *DBState, apiState *APIState, querierState *QuerierState, db C.Db, a C.GoApi, q C.GoQuerier, printDebug bool) BatchCallResult {
 	callID := startCall()
 	defer endCall(callID)
 
-	dbState := buildDBState(store, callID)
-	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	e := dumpView(callID, "env", call.Env)
+	defer runtime.KeepAlive(call.Env)
+	i := dumpView(callID, "info", call.Info)
+	defer runtime.KeepAlive(call.Info)
+	m := dumpView(callID, "msg", call.Msg)
+	defer runtime.KeepAlive(call.Msg)
+
+	dbState.CallID = callID
+	dbState.Access, dbState.Commitment = call.Output.start()
+	apiState.CallID = callID
+	querierState.CallID = callID
+	if call.Store != nil {
+		defer func(store KVStoreWithErrors) { dbState.Store = store }(dbState.Store)
+		dbState.Store = AdaptKVStore(call.Store)
+	}
+	if call.API != nil {
+		defer func(api *GoAPI) { apiState.API = api }(apiState.API)
+		apiState.API = call.API
+	}
+	if call.Querier != nil {
+		defer func(querier *Querier) { querierState.Querier = querier }(querierState.Querier)
+		querierState.Querier = call.Querier
+	}
+
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
-	res, err := C.execute(cache.ptr, cs, e, i, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	res, err := C.execute(cache.ptr, cs, e, i, m, db, a, q, cu64(call.GasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return BatchCallResult{GasUsed: uint64(gasUsed), Err: errorWithGas(err, errmsg, callID, call.GasLimit, uint64(gasUsed))}
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	call.Output.finish(dbState.Commitment, data)
+	return BatchCallResult{Data: data, GasUsed: uint64(gasUsed)}
 }
 
 func Migrate(
@@ -227,30 +358,37 @@ func Migrate(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.migrate(cache.ptr, cs, e, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func Sudo(
@@ -264,30 +402,37 @@ func Sudo(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.sudo(cache.ptr, cs, e, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func Reply(
@@ -301,30 +446,37 @@ func Reply(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	r := makeView(reply)
+	r := dumpView(callID, "msg", reply)
 	defer runtime.KeepAlive(reply)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.reply(cache.ptr, cs, e, r, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func Query(
@@ -338,30 +490,37 @@ func Query(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.query(cache.ptr, cs, e, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func IBCChannelOpen(
@@ -375,30 +534,37 @@ func IBCChannelOpen(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.ibc_channel_open(cache.ptr, cs, e, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func IBCChannelConnect(
@@ -412,30 +578,37 @@ func IBCChannelConnect(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.ibc_channel_connect(cache.ptr, cs, e, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func IBCChannelClose(
@@ -449,30 +622,37 @@ func IBCChannelClose(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	m := makeView(msg)
+	m := dumpView(callID, "msg", msg)
 	defer runtime.KeepAlive(msg)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.ibc_channel_close(cache.ptr, cs, e, m, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func IBCPacketReceive(
@@ -486,30 +666,37 @@ func IBCPacketReceive(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	pa := makeView(packet)
+	pa := dumpView(callID, "msg", packet)
 	defer runtime.KeepAlive(packet)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.ibc_packet_receive(cache.ptr, cs, e, pa, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func IBCPacketAck(
@@ -523,30 +710,37 @@ func IBCPacketAck(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	ac := makeView(ack)
+	ac := dumpView(callID, "msg", ack)
 	defer runtime.KeepAlive(ack)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.ibc_packet_ack(cache.ptr, cs, e, ac, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 func IBCPacketTimeout(
@@ -560,34 +754,50 @@ func IBCPacketTimeout(
 	querier *Querier,
 	gasLimit uint64,
 	printDebug bool,
+	out *CallOutput,
 ) ([]byte, uint64, error) {
-	cs := makeView(checksum)
+	callID := startCall()
+	defer endCall(callID)
+
+	cs := dumpView(callID, "checksum", checksum)
 	defer runtime.KeepAlive(checksum)
-	e := makeView(env)
+	e := dumpView(callID, "env", env)
 	defer runtime.KeepAlive(env)
-	pa := makeView(packet)
+	pa := dumpView(callID, "msg", packet)
 	defer runtime.KeepAlive(packet)
 
-	callID := startCall()
-	defer endCall(callID)
-
-	dbState := buildDBState(store, callID)
+	dbState := buildDBState(store, callID, cache.storage, out)
 	db := buildDB(&dbState, gasMeter)
-	a := buildAPI(api)
-	q := buildQuerier(querier)
+	apiState := buildAPIState(api, callID)
+	a := buildAPI(&apiState)
+	querierState := buildQuerierState(querier, callID)
+	q := buildQuerier(&querierState)
 	var gasUsed cu64
 	errmsg := newUnmanagedVector(nil)
 
 	res, err := C.ibc_packet_timeout(cache.ptr, cs, e, pa, db, a, q, cu64(gasLimit), cbool(printDebug), &gasUsed, &errmsg)
+	receivedVector(callID, vectorResult, res)
+	receivedVector(callID, vectorErrorMessage, errmsg)
 	if err != nil && err.(syscall.Errno) != C.ErrnoValue_Success {
 		// Depending on the nature of the error, `gasUsed` will either have a meaningful value, or just 0.
-		return nil, uint64(gasUsed), errorWithMessage(err, errmsg)
+		return nil, uint64(gasUsed), errorWithGas(err, errmsg, callID, gasLimit, uint64(gasUsed))
 	}
-	return copyAndDestroyUnmanagedVector(res), uint64(gasUsed), nil
+	data := copyAndDestroyUnmanagedVector(res)
+	out.finish(dbState.Commitment, data)
+	return data, uint64(gasUsed), nil
 }
 
 /**** To error module ***/
 
+// errorWithGas works like errorWithMessage but fills in the details of out of gas errors of a contract call
+func errorWithGas(err error, b C.UnmanagedVector, callID uint64, gasLimit uint64, gasUsed uint64) error {
+	if errno, ok := err.(syscall.Errno); ok && int(errno) == 2 {
+		_ = copyAndDestroyUnmanagedVector(b)
+		return newOutOfGasError(callID, gasLimit, gasUsed)
+	}
+	return errorWithMessage(err, b)
+}
+
 func errorWithMessage(err error, b C.UnmanagedVector) error {
 	// this checks for out of gas as a special case
 	if errno, ok := err.(syscall.Errno); ok && int(errno) == 2 {
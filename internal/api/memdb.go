@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	errKeyEmpty   = errors.New("key cannot be empty")
+	errValueNil   = errors.New("value cannot be nil")
+	errDomainSize = errors.New("iterator start must be less than end")
+)
+
+// MemDB is a simple in-memory KVStoreWithErrors used by the mocks and tools. It follows the behaviour of
+// tm-db's MemDB: empty keys and nil values are rejected and iterators are sorted by key.
+// Iterators work on a snapshot of the database taken at creation.
+type MemDB struct {
+	mtx  sync.RWMutex
+	data map[string][]byte
+}
+
+var _ KVStoreWithErrors = (*MemDB)(nil)
+
+func NewMemDB() *MemDB {
+	return &MemDB{data: make(map[string][]byte)}
+}
+
+func (db *MemDB) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errKeyEmpty
+	}
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	return db.data[string(key)], nil
+}
+
+func (db *MemDB) Set(key []byte, value []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	if value == nil {
+		return errValueNil
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	db.data[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (db *MemDB) Delete(key []byte) error {
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	delete(db.data, string(key))
+	return nil
+}
+
+func (db *MemDB) Iterator(start, end []byte) (Iterator, error) {
+	return db.newIterator(start, end, true)
+}
+
+func (db *MemDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return db.newIterator(start, end, false)
+}
+
+func (db *MemDB) newIterator(start, end []byte, ascending bool) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, errKeyEmpty
+	}
+	if start != nil && end != nil && bytes.Compare(start, end) > 0 {
+		return nil, errDomainSize
+	}
+
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	items := make([]memDBItem, 0)
+	for k, v := range db.data {
+		key := []byte(k)
+		if start != nil && bytes.Compare(key, start) < 0 {
+			continue
+		}
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			continue
+		}
+		items = append(items, memDBItem{key: key, value: v})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if ascending {
+			return bytes.Compare(items[i].key, items[j].key) < 0
+		}
+		return bytes.Compare(items[i].key, items[j].key) > 0
+	})
+	return &memDBIterator{items: items, start: start, end: end}, nil
+}
+
+type memDBItem struct {
+	key   []byte
+	value []byte
+}
+
+type memDBIterator struct {
+	items []memDBItem
+	start []byte
+	end   []byte
+}
+
+var _ Iterator = (*memDBIterator)(nil)
+
+func (it *memDBIterator) Domain() ([]byte, []byte) {
+	return it.start, it.end
+}
+
+func (it *memDBIterator) Valid() bool {
+	return len(it.items) > 0
+}
+
+func (it *memDBIterator) Next() {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	it.items = it.items[1:]
+}
+
+func (it *memDBIterator) Key() []byte {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	return it.items[0].key
+}
+
+func (it *memDBIterator) Value() []byte {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	return it.items[0].value
+}
+
+func (it *memDBIterator) Error() error {
+	return nil
+}
+
+func (it *memDBIterator) Close() error {
+	it.items = nil
+	return nil
+}
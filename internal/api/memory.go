@@ -29,6 +29,12 @@ func makeView(s []byte) C.ByteSliceView {
 	}
 }
 
+// dumpView works like makeView and passes s to the payload dump, if enabled. callID is 0 outside of contract calls.
+func dumpView(callID uint64, name string, s []byte) C.ByteSliceView {
+	dumpPayload(callID, PayloadToVM, name, s)
+	return makeView(s)
+}
+
 // Creates a C.UnmanagedVector, which cannot be done in test files directly
 func constructUnmanagedVector(is_none cbool, ptr cu8_ptr, len cusize, cap cusize) C.UnmanagedVector {
 	return C.UnmanagedVector{
@@ -54,17 +60,39 @@ func newUnmanagedVector(data []byte) C.UnmanagedVector {
 	}
 }
 
-func copyAndDestroyUnmanagedVector(v C.UnmanagedVector) []byte {
-	var out []byte
+// vectorAllocation returns the address of the memory owned by v, or 0 if v owns none
+func vectorAllocation(v C.UnmanagedVector) uintptr {
+	if v.is_none || v.cap == cusize(0) {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(v.ptr))
+}
+
+// receivedVector records that Rust handed v to Go, which has to destroy it with copyAndDestroyUnmanagedVector.
+// callID is 0 outside of contract calls. Vectors are only tracked if built with the vectordebug tag.
+// Vectors other than none error messages are passed to the payload dump, if enabled.
+func receivedVector(callID uint64, kind vectorKind, v C.UnmanagedVector) {
+	trackVector(callID, kind, vectorAllocation(v))
+	if payloadDumpEnabled() && !(kind == vectorErrorMessage && bool(v.is_none)) {
+		dumpPayload(callID, PayloadFromVM, string(kind), copyUnmanagedVector(v))
+	}
+}
+
+// copyUnmanagedVector copies the contents of v without destroying it
+func copyUnmanagedVector(v C.UnmanagedVector) []byte {
 	if v.is_none {
-		out = nil
+		return nil
 	} else if v.cap == cusize(0) {
 		// There is no allocation we can copy
-		out = []byte{}
-	} else {
-		// C.GoBytes create a copy (https://stackoverflow.com/a/40950744/2013738)
-		out = C.GoBytes(unsafe.Pointer(v.ptr), cint(v.len))
+		return []byte{}
 	}
+	// C.GoBytes create a copy (https://stackoverflow.com/a/40950744/2013738)
+	return C.GoBytes(unsafe.Pointer(v.ptr), cint(v.len))
+}
+
+func copyAndDestroyUnmanagedVector(v C.UnmanagedVector) []byte {
+	untrackVector(vectorAllocation(v))
+	out := copyUnmanagedVector(v)
 	C.destroy_unmanaged_vector(v)
 	return out
 }
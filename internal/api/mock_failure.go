@@ -18,3 +18,24 @@ func NewMockFailureAPI() *GoAPI {
 		CanonicalAddress: MockFailureCanonicalAddress,
 	}
 }
+
+/***** Mock KVStoreWithErrors ****/
+
+// MockFailureKVStore reads from the wrapped store and fails all writes
+type MockFailureKVStore struct {
+	KVStoreWithErrors
+}
+
+var _ KVStoreWithErrors = MockFailureKVStore{}
+
+func NewMockFailureKVStore(kv KVStore) MockFailureKVStore {
+	return MockFailureKVStore{AdaptKVStore(kv)}
+}
+
+func (s MockFailureKVStore) Set(key, value []byte) error {
+	return fmt.Errorf("mock failure - set %q", key)
+}
+
+func (s MockFailureKVStore) Delete(key []byte) error {
+	return fmt.Errorf("mock failure - delete %q", key)
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	dbm "github.com/tendermint/tm-db"
 
 	"github.com/line/wasmvm/types"
 )
@@ -177,6 +176,20 @@ func MockIBCPacketTimeout(myChannel string, data []byte) types.IBCPacketTimeoutM
 	}
 }
 
+/*** UnmanagedVector leaks ****/
+
+// RequireNoVectorLeaks fails the test if a contract call of the test ended with UnmanagedVectors that Go
+// did not destroy, or if more vectors are outstanding at the end of the test than at its start.
+// The vectors are only tracked in builds with the vectordebug tag, otherwise this check always passes.
+func RequireNoVectorLeaks(t testing.TB) {
+	_ = TakeVectorLeaks()
+	outstanding := OutstandingVectors()
+	t.Cleanup(func() {
+		require.Empty(t, TakeVectorLeaks(), "UnmanagedVectors leaked in contract calls")
+		require.Equal(t, outstanding, OutstandingVectors(), "UnmanagedVectors not destroyed")
+	})
+}
+
 /*** Mock GasMeter ****/
 // This code is borrowed from lbm-sdk store/types/gas.go
 
@@ -255,13 +268,13 @@ const (
 )
 
 type Lookup struct {
-	db    *dbm.MemDB
+	db    KVStoreWithErrors
 	meter MockGasMeter
 }
 
 func NewLookup(meter MockGasMeter) *Lookup {
 	return &Lookup{
-		db:    dbm.NewMemDB(),
+		db:    NewMemDB(),
 		meter: meter,
 	}
 }
@@ -277,6 +290,42 @@ func (l *Lookup) WithGasMeter(meter MockGasMeter) *Lookup {
 	}
 }
 
+// Clone returns a Lookup on a copy of the current data consuming gas on meter. Writes to the clone
+// do not affect l and the other way round.
+func (l *Lookup) Clone(meter MockGasMeter) *Lookup {
+	db := NewMemDB()
+	it, err := l.db.Iterator(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	defer it.Close()
+	for ; it.Valid(); it.Next() {
+		if err := db.Set(it.Key(), it.Value()); err != nil {
+			panic(err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		panic(err)
+	}
+	return &Lookup{
+		db:    db,
+		meter: meter,
+	}
+}
+
+// Unmetered returns the underlying DB
+func (l Lookup) Unmetered() KVStore {
+	return AdaptKVStoreWithErrors(l.db)
+}
+
+// Metered returns a Lookup charging the same prices on the same meter for all operations on parent
+func (l Lookup) Metered(parent KVStore) KVStore {
+	return &Lookup{
+		db:    AdaptKVStore(parent),
+		meter: l.meter,
+	}
+}
+
 // Get wraps the underlying DB's Get method panicing on error.
 func (l Lookup) Get(key []byte) []byte {
 	l.meter.ConsumeGas(GetPrice, "get")
@@ -305,7 +354,7 @@ func (l Lookup) Delete(key []byte) {
 }
 
 // Iterator wraps the underlying DB's Iterator method panicing on error.
-func (l Lookup) Iterator(start, end []byte) dbm.Iterator {
+func (l Lookup) Iterator(start, end []byte) Iterator {
 	l.meter.ConsumeGas(RangePrice, "range")
 	iter, err := l.db.Iterator(start, end)
 	if err != nil {
@@ -316,7 +365,7 @@ func (l Lookup) Iterator(start, end []byte) dbm.Iterator {
 }
 
 // ReverseIterator wraps the underlying DB's ReverseIterator method panicing on error.
-func (l Lookup) ReverseIterator(start, end []byte) dbm.Iterator {
+func (l Lookup) ReverseIterator(start, end []byte) Iterator {
 	l.meter.ConsumeGas(RangePrice, "range")
 	iter, err := l.db.ReverseIterator(start, end)
 	if err != nil {
@@ -326,7 +375,7 @@ func (l Lookup) ReverseIterator(start, end []byte) dbm.Iterator {
 	return iter
 }
 
-var _ KVStore = (*Lookup)(nil)
+var _ MeteredStore = (*Lookup)(nil)
 
 /***** Mock GoAPI ****/
 
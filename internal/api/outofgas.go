@@ -0,0 +1,72 @@
+package api
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/line/wasmvm/types"
+)
+
+// outOfGasRecord remembers in which host callback a contract call ran out of gas
+type outOfGasRecord struct {
+	location   types.GasLocation
+	descriptor string
+}
+
+// outOfGasRecords contains the first out of gas panic of each contract call, indexed by contract call ID.
+var outOfGasRecords = make(map[uint64]outOfGasRecord)
+var outOfGasRecordsMutex sync.Mutex
+
+// recordOutOfGas stores where the given call ran out of gas. Only the first record of a call is kept.
+func recordOutOfGas(callID uint64, location types.GasLocation, descriptor string) {
+	if callID == 0 {
+		return
+	}
+	outOfGasRecordsMutex.Lock()
+	defer outOfGasRecordsMutex.Unlock()
+	if _, exists := outOfGasRecords[callID]; !exists {
+		outOfGasRecords[callID] = outOfGasRecord{location: location, descriptor: descriptor}
+	}
+}
+
+// takeOutOfGas returns and removes the record of the given call
+func takeOutOfGas(callID uint64) (outOfGasRecord, bool) {
+	outOfGasRecordsMutex.Lock()
+	defer outOfGasRecordsMutex.Unlock()
+	record, ok := outOfGasRecords[callID]
+	delete(outOfGasRecords, callID)
+	return record, ok
+}
+
+// newOutOfGasError creates the error for a call that the VM stopped with an out of gas error
+func newOutOfGasError(callID uint64, gasLimit uint64, gasUsed uint64) types.OutOfGasError {
+	err := types.OutOfGasError{
+		Limit:    gasLimit,
+		Used:     gasUsed,
+		Location: types.GasLocationWasm,
+	}
+	if record, ok := takeOutOfGas(callID); ok {
+		err.Location = record.location
+		err.Descriptor = record.descriptor
+	}
+	return err
+}
+
+// panicDescriptor returns the `Descriptor` string field of the SDK's gas panics (a struct or a pointer to one)
+func panicDescriptor(rec interface{}) string {
+	v := reflect.ValueOf(rec)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	field := v.FieldByName("Descriptor")
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return ""
+	}
+	return field.String()
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/line/wasmvm/types"
+)
+
+func TestPanicDescriptor(t *testing.T) {
+	assert.Equal(t, "WriteFlat", panicDescriptor(ErrorOutOfGas{Descriptor: "WriteFlat"}))
+	assert.Equal(t, "ReadFlat", panicDescriptor(&ErrorOutOfGas{Descriptor: "ReadFlat"}))
+	assert.Equal(t, "", panicDescriptor((*ErrorOutOfGas)(nil)))
+	assert.Equal(t, "", panicDescriptor("foo"))
+	assert.Equal(t, "", panicDescriptor(struct{ Descriptor int }{7}))
+}
+
+func TestNewOutOfGasError(t *testing.T) {
+	// without a record, the VM ran out of gas itself
+	err := newOutOfGasError(12345, 1000, 1001)
+	assert.Equal(t, types.OutOfGasError{Limit: 1000, Used: 1001, Location: types.GasLocationWasm}, err)
+	assert.Equal(t, "Out of gas in wasm (gas used: 1001, limit: 1000)", err.Error())
+
+	// the first record wins
+	recordOutOfGas(12345, types.GasLocationStorageWrite, "WriteFlat")
+	recordOutOfGas(12345, types.GasLocationQuery, "other")
+	err = newOutOfGasError(12345, 1000, 700)
+	assert.Equal(t, types.OutOfGasError{Limit: 1000, Used: 700, Location: types.GasLocationStorageWrite, Descriptor: "WriteFlat"}, err)
+	assert.Equal(t, "Out of gas in storage write: WriteFlat (gas used: 700, limit: 1000)", err.Error())
+
+	// the record was consumed
+	_, ok := takeOutOfGas(12345)
+	assert.False(t, ok)
+
+	// unknown calls are not recorded
+	recordOutOfGas(0, types.GasLocationQuery, "")
+	_, ok = takeOutOfGas(0)
+	assert.False(t, ok)
+}
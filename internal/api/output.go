@@ -0,0 +1,61 @@
+package api
+
+// CallOutput requests optional outputs of a contract call and receives them when the call returns.
+// A nil *CallOutput requests none.
+type CallOutput struct {
+	// RecordAccess requests the read and write set of the call in Access
+	RecordAccess bool
+	// CommitWrites requests the write commitment of the call in Commitment
+	CommitWrites bool
+	// KeepResult requests the raw result bytes returned by the VM in Result
+	KeepResult bool
+
+	// Access is the read and write set of the call if RecordAccess is set. It is also set if the call fails.
+	Access *AccessSet
+	// Commitment is the write commitment over the writes, deletes and the response of the call if
+	// CommitWrites is set and the call succeeded. It is nil otherwise. See writeCommitment.
+	Commitment []byte
+	// Result is the raw result returned by the VM if KeepResult is set, before the host decodes it.
+	// It is also set if the contract returned an error result, but not if the VM call failed.
+	Result []byte
+}
+
+// Reset clears the outputs of a previous call
+func (out *CallOutput) Reset() {
+	if out == nil {
+		return
+	}
+	out.Access = nil
+	out.Commitment = nil
+	out.Result = nil
+}
+
+// start resets the outputs for a new call and returns what the call records into, nil if not requested
+func (out *CallOutput) start() (*AccessSet, *writeCommitment) {
+	if out == nil {
+		return nil, nil
+	}
+	out.Reset()
+	var commitment *writeCommitment
+	if out.RecordAccess {
+		out.Access = &AccessSet{}
+	}
+	if out.CommitWrites {
+		commitment = newWriteCommitment()
+	}
+	return out.Access, commitment
+}
+
+// finish completes the outputs of a call that returned data without error
+func (out *CallOutput) finish(commitment *writeCommitment, data []byte) {
+	if out == nil {
+		return
+	}
+	if out.KeepResult {
+		out.Result = data
+	}
+	if commitment != nil {
+		commitment.addResponse(data)
+		out.Commitment = commitment.sum()
+	}
+}
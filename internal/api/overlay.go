@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"sort"
+)
+
+// StoreWrite is a single write or delete that was applied to an OverlayStore.
+type StoreWrite struct {
+	Key []byte
+	// Value is the written value. It is nil for deletes.
+	Value  []byte
+	Delete bool
+}
+
+type overlayEntry struct {
+	value   []byte
+	deleted bool
+}
+
+// OverlayStore is a KVStore that keeps all writes and deletes in memory on top of a parent store.
+// The parent is only read from until Commit is called, so dropping the overlay discards all changes.
+//
+// Note that writes are not forwarded to the parent, so the parent cannot charge gas for them.
+// Use BranchStore to put the overlay below the gas metering of a MeteredStore.
+type OverlayStore struct {
+	parent  KVStore
+	entries map[string]overlayEntry
+	writes  []StoreWrite
+}
+
+var _ KVStore = (*OverlayStore)(nil)
+
+// NewOverlayStore creates an empty overlay on top of parent
+func NewOverlayStore(parent KVStore) *OverlayStore {
+	return &OverlayStore{
+		parent:  parent,
+		entries: make(map[string]overlayEntry),
+	}
+}
+
+// MeteredStore is a KVStore that charges gas itself, like GasKVStore or the SDK's gaskv store.
+// BranchStore puts overlays below the metering of such stores, such that discardable writes and
+// deletes are charged like real ones.
+type MeteredStore interface {
+	KVStore
+	// Unmetered returns the store below the metering
+	Unmetered() KVStore
+	// Metered returns a store that charges the same gas as this one for all operations on parent
+	Metered(parent KVStore) KVStore
+}
+
+// BranchStore creates an overlay on top of store and returns it together with the store that
+// should be passed to the contract calls. For a MeteredStore the overlay is placed below the metering,
+// recursively, so all operations consume the same gas as on store. Otherwise the overlay is returned
+// as is and store cannot charge gas for writes.
+func BranchStore(store KVStore) (*OverlayStore, KVStore) {
+	if metered, ok := store.(MeteredStore); ok {
+		overlay, branch := BranchStore(metered.Unmetered())
+		return overlay, metered.Metered(branch)
+	}
+	overlay := NewOverlayStore(store)
+	return overlay, overlay
+}
+
+// Writes returns all writes and deletes in the order they were applied
+func (o *OverlayStore) Writes() []StoreWrite {
+	return o.writes
+}
+
+// Commit applies all writes and deletes to the parent in the order they happened and empties the overlay.
+// The parent is written without gas metering if the overlay was created with BranchStore. Errors of stores
+// adapted with AdaptKVStoreWithErrors below the parent are returned. The writes before the failing one
+// remain in the parent in this case.
+func (o *OverlayStore) Commit() error {
+	parent := AdaptKVStore(o.parent)
+	for _, write := range o.writes {
+		var err error
+		if write.Delete {
+			err = parent.Delete(write.Key)
+		} else {
+			err = parent.Set(write.Key, write.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	o.entries = make(map[string]overlayEntry)
+	o.writes = nil
+	return nil
+}
+
+func (o *OverlayStore) Get(key []byte) []byte {
+	if entry, ok := o.entries[string(key)]; ok {
+		if entry.deleted {
+			return nil
+		}
+		return entry.value
+	}
+	return o.parent.Get(key)
+}
+
+func (o *OverlayStore) Set(key, value []byte) {
+	k := append([]byte(nil), key...)
+	v := append([]byte{}, value...)
+	o.entries[string(k)] = overlayEntry{value: v}
+	o.writes = append(o.writes, StoreWrite{Key: k, Value: v})
+}
+
+func (o *OverlayStore) Delete(key []byte) {
+	k := append([]byte(nil), key...)
+	o.entries[string(k)] = overlayEntry{deleted: true}
+	o.writes = append(o.writes, StoreWrite{Key: k, Delete: true})
+}
+
+func (o *OverlayStore) Iterator(start, end []byte) Iterator {
+	return newOverlayIterator(o.parent.Iterator(start, end), o.sortedEntries(start, end, true), start, end, true)
+}
+
+func (o *OverlayStore) ReverseIterator(start, end []byte) Iterator {
+	return newOverlayIterator(o.parent.ReverseIterator(start, end), o.sortedEntries(start, end, false), start, end, false)
+}
+
+type sortedEntry struct {
+	key []byte
+	overlayEntry
+}
+
+// sortedEntries returns a snapshot of the overlay entries in [start, end) in iteration order
+func (o *OverlayStore) sortedEntries(start, end []byte, ascending bool) []sortedEntry {
+	var out []sortedEntry
+	for k, entry := range o.entries {
+		key := []byte(k)
+		if start != nil && bytes.Compare(key, start) < 0 {
+			continue
+		}
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			continue
+		}
+		out = append(out, sortedEntry{key: key, overlayEntry: entry})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if ascending {
+			return bytes.Compare(out[i].key, out[j].key) < 0
+		}
+		return bytes.Compare(out[i].key, out[j].key) > 0
+	})
+	return out
+}
+
+// overlayIterator merges the parent iterator with a snapshot of the overlay entries.
+// Overlay entries win over parent entries with the same key and deleted entries are skipped.
+type overlayIterator struct {
+	parent    Iterator
+	entries   []sortedEntry
+	start     []byte
+	end       []byte
+	ascending bool
+	// the current position, valid if useEntry or the parent is valid
+	useEntry bool
+}
+
+var _ Iterator = (*overlayIterator)(nil)
+
+func newOverlayIterator(parent Iterator, entries []sortedEntry, start, end []byte, ascending bool) *overlayIterator {
+	it := &overlayIterator{
+		parent:    parent,
+		entries:   entries,
+		start:     start,
+		end:       end,
+		ascending: ascending,
+	}
+	it.seek()
+	return it
+}
+
+// before returns true if a comes before b in iteration order
+func (it *overlayIterator) before(a, b []byte) bool {
+	if it.ascending {
+		return bytes.Compare(a, b) < 0
+	}
+	return bytes.Compare(a, b) > 0
+}
+
+// seek moves to the next visible item, starting at the current position of both sources
+func (it *overlayIterator) seek() {
+	for {
+		parentValid := it.parent.Valid()
+		if len(it.entries) == 0 {
+			it.useEntry = false
+			return
+		}
+		entry := it.entries[0]
+		if parentValid {
+			parentKey := it.parent.Key()
+			if it.before(parentKey, entry.key) {
+				it.useEntry = false
+				return
+			}
+			if bytes.Equal(parentKey, entry.key) {
+				// shadowed by the overlay
+				it.parent.Next()
+			}
+		}
+		if entry.deleted {
+			it.entries = it.entries[1:]
+			continue
+		}
+		it.useEntry = true
+		return
+	}
+}
+
+func (it *overlayIterator) Domain() ([]byte, []byte) {
+	return it.start, it.end
+}
+
+func (it *overlayIterator) Valid() bool {
+	return it.useEntry || it.parent.Valid()
+}
+
+func (it *overlayIterator) Next() {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	if it.useEntry {
+		it.entries = it.entries[1:]
+	} else {
+		it.parent.Next()
+	}
+	it.seek()
+}
+
+func (it *overlayIterator) Key() []byte {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	if it.useEntry {
+		return it.entries[0].key
+	}
+	return it.parent.Key()
+}
+
+func (it *overlayIterator) Value() []byte {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	if it.useEntry {
+		return it.entries[0].value
+	}
+	return it.parent.Value()
+}
+
+func (it *overlayIterator) Error() error {
+	return it.parent.Error()
+}
+
+func (it *overlayIterator) Close() error {
+	return it.parent.Close()
+}
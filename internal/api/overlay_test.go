@@ -0,0 +1,116 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, it Iterator) []string {
+	defer it.Close()
+	var out []string
+	for ; it.Valid(); it.Next() {
+		out = append(out, string(it.Key())+"="+string(it.Value()))
+	}
+	require.NoError(t, it.Error())
+	return out
+}
+
+func TestOverlayStore(t *testing.T) {
+	parent := NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT))
+	parent.Set([]byte("a"), []byte("1"))
+	parent.Set([]byte("c"), []byte("3"))
+	parent.Set([]byte("e"), []byte("5"))
+	parent.Set([]byte("g"), []byte("7"))
+
+	overlay := NewOverlayStore(parent)
+	overlay.Set([]byte("b"), []byte("2"))
+	overlay.Set([]byte("c"), []byte("33"))
+	overlay.Delete([]byte("e"))
+	overlay.Delete([]byte("x"))
+	overlay.Set([]byte("h"), []byte("8"))
+
+	// reads see the overlay
+	assert.Equal(t, []byte("1"), overlay.Get([]byte("a")))
+	assert.Equal(t, []byte("2"), overlay.Get([]byte("b")))
+	assert.Equal(t, []byte("33"), overlay.Get([]byte("c")))
+	assert.Nil(t, overlay.Get([]byte("e")))
+
+	// the parent is untouched
+	assert.Nil(t, parent.Get([]byte("b")))
+	assert.Equal(t, []byte("3"), parent.Get([]byte("c")))
+	assert.Equal(t, []byte("5"), parent.Get([]byte("e")))
+
+	// iteration merges both
+	assert.Equal(t, []string{"a=1", "b=2", "c=33", "g=7", "h=8"}, collect(t, overlay.Iterator(nil, nil)))
+	assert.Equal(t, []string{"h=8", "g=7", "c=33", "b=2", "a=1"}, collect(t, overlay.ReverseIterator(nil, nil)))
+	assert.Equal(t, []string{"b=2", "c=33"}, collect(t, overlay.Iterator([]byte("b"), []byte("e"))))
+	assert.Equal(t, []string{"g=7", "c=33", "b=2"}, collect(t, overlay.ReverseIterator([]byte("b"), []byte("h"))))
+	assert.Equal(t, []string(nil), collect(t, overlay.Iterator([]byte("d"), []byte("f"))))
+
+	// iterators are a snapshot of the overlay at creation
+	it := overlay.Iterator(nil, nil)
+	overlay.Delete([]byte("b"))
+	assert.Equal(t, []string{"a=1", "b=2", "c=33", "g=7", "h=8"}, collect(t, it))
+	assert.Equal(t, []string{"a=1", "c=33", "g=7", "h=8"}, collect(t, overlay.Iterator(nil, nil)))
+
+	// all writes in order
+	expected := []StoreWrite{
+		{Key: []byte("b"), Value: []byte("2")},
+		{Key: []byte("c"), Value: []byte("33")},
+		{Key: []byte("e"), Delete: true},
+		{Key: []byte("x"), Delete: true},
+		{Key: []byte("h"), Value: []byte("8")},
+		{Key: []byte("b"), Delete: true},
+	}
+	assert.Equal(t, expected, overlay.Writes())
+}
+
+func TestOverlayStoreCopiesInput(t *testing.T) {
+	overlay := NewOverlayStore(NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT)))
+	key := []byte("foo")
+	value := []byte("bar")
+	overlay.Set(key, value)
+	key[0] = 'x'
+	value[0] = 'x'
+	assert.Equal(t, []byte("bar"), overlay.Get([]byte("foo")))
+	assert.Equal(t, []byte("foo"), overlay.Writes()[0].Key)
+}
+
+func TestBranchStoreChargesWrites(t *testing.T) {
+	lookupMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	gasMeter := NewGasMeter(TESTING_GAS_LIMIT)
+	store := NewGasKVStore(NewLookup(lookupMeter), gasMeter, DefaultKVGasConfig())
+	store.Set([]byte("a"), []byte("1"))
+	lookupBefore, gasBefore := lookupMeter.GasConsumed(), gasMeter.GasConsumed()
+
+	overlay, branch := BranchStore(store)
+	branch.Set([]byte("b"), []byte("2"))
+	branch.Delete([]byte("a"))
+
+	// both meters charged like for the real store
+	assert.Equal(t, uint64(SetPrice+RemovePrice), lookupMeter.GasConsumed()-lookupBefore)
+	config := DefaultKVGasConfig()
+	assert.Equal(t, config.WriteCostFlat+2*config.WriteCostPerByte+config.DeleteCost, gasMeter.GasConsumed()-gasBefore)
+
+	// but the writes only went to the overlay
+	assert.Len(t, overlay.Writes(), 2)
+	assert.Nil(t, branch.Get([]byte("a")))
+	assert.Equal(t, []byte("1"), store.Get([]byte("a")))
+	assert.Nil(t, store.Get([]byte("b")))
+
+	// committing does not charge the writes again
+	lookupBefore, gasBefore = lookupMeter.GasConsumed(), gasMeter.GasConsumed()
+	require.NoError(t, overlay.Commit())
+	assert.Equal(t, lookupBefore, lookupMeter.GasConsumed())
+	assert.Equal(t, gasBefore, gasMeter.GasConsumed())
+	assert.Empty(t, overlay.Writes())
+	assert.Nil(t, store.Get([]byte("a")))
+	assert.Equal(t, []byte("2"), store.Get([]byte("b")))
+
+	// stores that do not meter are branched directly
+	plain := NewOverlayStore(NewLookup(lookupMeter))
+	overlay, branch = BranchStore(plain)
+	assert.Same(t, overlay, branch)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+)
+
+// PanicCategory determines the error a panic in a host callback is turned into
+type PanicCategory int
+
+const (
+	// PanicCategoryUnexpected panics abort the contract call with a panic error (GoError_Panic)
+	// and are passed to the unexpected panic hook
+	PanicCategoryUnexpected PanicCategory = iota
+	// PanicCategoryOutOfGas panics abort the contract call with an OutOfGasError (GoError_OutOfGas)
+	PanicCategoryOutOfGas
+	// PanicCategoryPanic panics abort the contract call with a panic error (GoError_Panic)
+	// without calling the unexpected panic hook
+	PanicCategoryPanic
+	// PanicCategoryUser panics are returned to the contract as a user error (GoError_User)
+	// with the formatted panic value as message
+	PanicCategoryUser
+)
+
+// PanicClassifier assigns a category to panics in host callbacks.
+// A classifier matches if Type and Match are both nil or satisfied.
+type PanicClassifier struct {
+	// Type matches panic values of this type or of a pointer to this type. Optional.
+	Type reflect.Type
+	// Match is an additional predicate on the panic value. Optional.
+	Match    func(rec interface{}) bool
+	Category PanicCategory
+}
+
+// NewTypePanicClassifier creates a classifier matching panics with values of the same type as example
+// or a pointer to it, e.g. NewTypePanicClassifier(storetypes.ErrorOutOfGas{}, PanicCategoryOutOfGas)
+func NewTypePanicClassifier(example interface{}, category PanicCategory) PanicClassifier {
+	return PanicClassifier{Type: reflect.TypeOf(example), Category: category}
+}
+
+func (c PanicClassifier) matches(rec interface{}) bool {
+	if c.Type != nil {
+		t := reflect.TypeOf(rec)
+		if t != c.Type && !(t.Kind() == reflect.Ptr && t.Elem() == c.Type) {
+			return false
+		}
+	}
+	return c.Match == nil || c.Match(rec)
+}
+
+// UnexpectedPanicHook receives panics in host callbacks that were not classified, together with
+// the stack trace of the panicking goroutine
+type UnexpectedPanicHook func(rec interface{}, stack []byte)
+
+// defaultPanicClassifiers are checked after all registered classifiers.
+//
+// We don't want to import Cosmos SDK and also cannot use interfaces to detect these
+// error types (as they have no methods). So, let's just rely on the descriptive names.
+// These three types are "thrown" (which is not a thing in Go 🙃) in panics from the gas module
+// (https://github.com/line/lbm-sdk/blob/main/store/types/gas.go):
+// 1. ErrorOutOfGas
+// 2. ErrorGasOverflow
+// 3. ErrorNegativeGasConsumed
+//
+// In the baseapp, ErrorOutOfGas gets special treatment:
+// - https://github.com/line/lbm-sdk/blob/main/baseapp/baseapp.go#L647
+// - https://github.com/line/lbm-sdk/blob/main/baseapp/recovery.go#L50-L60
+// This turns the panic into a regular error with a helpful error message.
+//
+// The other two gas related panic types indicate programming errors and are handled along
+// with all other errors in https://github.com/line/lbm-sdk/blob/main/baseapp/recovery.go#L66-L77.
+// SDK forks with other type names can register their own classifiers.
+var defaultPanicClassifiers = []PanicClassifier{
+	{
+		Match: func(rec interface{}) bool {
+			t := reflect.TypeOf(rec)
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			return t.Name() == "ErrorOutOfGas"
+		},
+		Category: PanicCategoryOutOfGas,
+	},
+}
+
+// logPanic is the default UnexpectedPanicHook
+func logPanic(rec interface{}, stack []byte) {
+	log.Printf("Panic in Go callback: %#v\n%s", rec, stack)
+}
+
+var (
+	panicClassifiers    []PanicClassifier
+	unexpectedPanicHook UnexpectedPanicHook = logPanic
+	panicHandlingMutex  sync.RWMutex
+)
+
+// RegisterPanicClassifier adds a classifier for panics in host callbacks. Classifiers are checked
+// in registration order and the first match wins. The built-in classifier for `ErrorOutOfGas` is
+// checked last.
+func RegisterPanicClassifier(classifier PanicClassifier) {
+	panicHandlingMutex.Lock()
+	defer panicHandlingMutex.Unlock()
+	panicClassifiers = append(panicClassifiers, classifier)
+}
+
+// ResetPanicClassifiers removes all registered classifiers
+func ResetPanicClassifiers() {
+	panicHandlingMutex.Lock()
+	defer panicHandlingMutex.Unlock()
+	panicClassifiers = nil
+}
+
+// SetUnexpectedPanicHook sets the function receiving unexpected panics in host callbacks.
+// The default logs the panic and its stack trace. Pass nil to ignore unexpected panics.
+func SetUnexpectedPanicHook(hook UnexpectedPanicHook) {
+	panicHandlingMutex.Lock()
+	defer panicHandlingMutex.Unlock()
+	unexpectedPanicHook = hook
+}
+
+// ClassifiedPanic is a panic value whose category is already known, e.g. a recorded panic raised
+// again on replay. It is not passed to the classifiers.
+type ClassifiedPanic struct {
+	Category PanicCategory
+	// Message is the formatted original panic value, which is the error of PanicCategoryUser panics
+	Message string
+	// Descriptor is the descriptor of the original panic, which is reported for PanicCategoryOutOfGas panics
+	Descriptor string
+}
+
+func (p ClassifiedPanic) String() string {
+	return p.Message
+}
+
+// ClassifyPanic returns the category and the details of a panic in a host callback
+func ClassifyPanic(rec interface{}) ClassifiedPanic {
+	category, _ := classifyPanic(rec)
+	return ClassifiedPanic{Category: category, Message: fmt.Sprint(rec), Descriptor: panicDescriptor(rec)}
+}
+
+// classifyPanic returns the category of the first matching classifier
+// and the hook to call for unexpected panics
+func classifyPanic(rec interface{}) (PanicCategory, UnexpectedPanicHook) {
+	panicHandlingMutex.RLock()
+	defer panicHandlingMutex.RUnlock()
+	if classified, ok := rec.(ClassifiedPanic); ok {
+		return classified.Category, unexpectedPanicHook
+	}
+	for _, classifier := range panicClassifiers {
+		if classifier.matches(rec) {
+			return classifier.Category, unexpectedPanicHook
+		}
+	}
+	for _, classifier := range defaultPanicClassifiers {
+		if classifier.matches(rec) {
+			return classifier.Category, unexpectedPanicHook
+		}
+	}
+	return PanicCategoryUnexpected, unexpectedPanicHook
+}
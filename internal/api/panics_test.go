@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type forkOutOfGas struct {
+	Descriptor string
+}
+
+func TestClassifyPanic(t *testing.T) {
+	defer ResetPanicClassifiers()
+
+	// built-in name based detection for values and pointers
+	category, _ := classifyPanic(ErrorOutOfGas{Descriptor: "foo"})
+	assert.Equal(t, PanicCategoryOutOfGas, category)
+	category, _ = classifyPanic(&ErrorOutOfGas{Descriptor: "foo"})
+	assert.Equal(t, PanicCategoryOutOfGas, category)
+	category, _ = classifyPanic(ErrorGasOverflow{Descriptor: "foo"})
+	assert.Equal(t, PanicCategoryUnexpected, category)
+	category, _ = classifyPanic(forkOutOfGas{})
+	assert.Equal(t, PanicCategoryUnexpected, category)
+
+	// by type, which also matches pointers
+	RegisterPanicClassifier(NewTypePanicClassifier(forkOutOfGas{}, PanicCategoryOutOfGas))
+	RegisterPanicClassifier(NewTypePanicClassifier(ErrorOutOfGas{}, PanicCategoryOutOfGas))
+	category, _ = classifyPanic(forkOutOfGas{})
+	assert.Equal(t, PanicCategoryOutOfGas, category)
+	category, _ = classifyPanic(&forkOutOfGas{})
+	assert.Equal(t, PanicCategoryOutOfGas, category)
+	category, _ = classifyPanic(&ErrorOutOfGas{})
+	assert.Equal(t, PanicCategoryOutOfGas, category)
+
+	// by predicate
+	RegisterPanicClassifier(PanicClassifier{
+		Match: func(rec interface{}) bool {
+			err, ok := rec.(error)
+			return ok && strings.HasPrefix(err.Error(), "invalid")
+		},
+		Category: PanicCategoryUser,
+	})
+	category, _ = classifyPanic(errors.New("invalid key"))
+	assert.Equal(t, PanicCategoryUser, category)
+	category, _ = classifyPanic(errors.New("other"))
+	assert.Equal(t, PanicCategoryUnexpected, category)
+
+	// first match wins
+	RegisterPanicClassifier(NewTypePanicClassifier(ErrorGasOverflow{}, PanicCategoryPanic))
+	RegisterPanicClassifier(NewTypePanicClassifier(ErrorGasOverflow{}, PanicCategoryUser))
+	category, _ = classifyPanic(ErrorGasOverflow{})
+	assert.Equal(t, PanicCategoryPanic, category)
+
+	ResetPanicClassifiers()
+	category, _ = classifyPanic(forkOutOfGas{})
+	assert.Equal(t, PanicCategoryUnexpected, category)
+}
+
+func TestClassifiedPanic(t *testing.T) {
+	defer ResetPanicClassifiers()
+
+	classified := ClassifyPanic(ErrorOutOfGas{Descriptor: "foo"})
+	assert.Equal(t, ClassifiedPanic{Category: PanicCategoryOutOfGas, Message: "{foo}", Descriptor: "foo"}, classified)
+	classified = ClassifyPanic("boom")
+	assert.Equal(t, ClassifiedPanic{Category: PanicCategoryUnexpected, Message: "boom"}, classified)
+
+	// the category of a classified panic is kept, even if a classifier matches all panics
+	RegisterPanicClassifier(PanicClassifier{Category: PanicCategoryUser})
+	category, _ := classifyPanic(ClassifiedPanic{Category: PanicCategoryOutOfGas, Descriptor: "foo"})
+	assert.Equal(t, PanicCategoryOutOfGas, category)
+	assert.Equal(t, "foo", panicDescriptor(ClassifiedPanic{Descriptor: "foo"}))
+	assert.Equal(t, "boom", fmt.Sprint(ClassifiedPanic{Message: "boom"}))
+}
+
+func TestUnexpectedPanicHook(t *testing.T) {
+	defer SetUnexpectedPanicHook(logPanic)
+
+	var received interface{}
+	SetUnexpectedPanicHook(func(rec interface{}, stack []byte) {
+		received = rec
+	})
+	_, hook := classifyPanic("boom")
+	require.NotNil(t, hook)
+	hook("boom", nil)
+	assert.Equal(t, "boom", received)
+
+	SetUnexpectedPanicHook(nil)
+	_, hook = classifyPanic("boom")
+	assert.Nil(t, hook)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// PayloadDirection tells on which side of the FFI boundary a payload was created
+type PayloadDirection int
+
+const (
+	// PayloadToVM payloads are passed from Go to Rust: the arguments of calls and the results of callbacks
+	PayloadToVM PayloadDirection = iota
+	// PayloadFromVM payloads are passed from Rust to Go: the results and error messages of calls and the
+	// arguments of callbacks
+	PayloadFromVM
+)
+
+func (d PayloadDirection) String() string {
+	switch d {
+	case PayloadToVM:
+		return "to_vm"
+	case PayloadFromVM:
+		return "from_vm"
+	default:
+		return fmt.Sprintf("PayloadDirection(%d)", int(d))
+	}
+}
+
+// DefaultPayloadMaxSize is the number of bytes kept of each payload if PayloadDumpOptions.MaxSize is 0
+const DefaultPayloadMaxSize = 4096
+
+// Payload is a byte slice that crossed the FFI boundary
+type Payload struct {
+	// CallID is the ID of the contract call, or 0 for calls that do not run a contract (e.g. Create)
+	CallID    uint64
+	Direction PayloadDirection
+	// Name is what the payload contains, e.g. "env", "msg", "result" or "read_db.key" for the
+	// arguments and results of callbacks
+	Name string
+	// Size is the length of the original payload
+	Size int
+	// IsNil is set for nil slices and Rust's None
+	IsNil bool
+	// SHA256 is the hash of the original payload
+	SHA256 [sha256.Size]byte
+	// Data is a copy of the payload, shortened to the maximum size. It is nil if the payload was redacted.
+	Data      []byte
+	Truncated bool
+	Redacted  bool
+}
+
+func (p Payload) String() string {
+	prefix := fmt.Sprintf("call %d %s %s", p.CallID, p.Direction, p.Name)
+	switch {
+	case p.IsNil:
+		return prefix + ": nil"
+	case p.Redacted:
+		return fmt.Sprintf("%s: %d bytes redacted, sha256 %X", prefix, p.Size, p.SHA256)
+	case p.Truncated:
+		return fmt.Sprintf("%s: %q... (%d of %d bytes)", prefix, p.Data, len(p.Data), p.Size)
+	default:
+		return fmt.Sprintf("%s: %q", prefix, p.Data)
+	}
+}
+
+// RedactionRule redacts the data of payloads called Name that are larger than MinSize bytes.
+// An empty Name matches all payloads. Redacted payloads keep their size and hash.
+type RedactionRule struct {
+	Name    string
+	MinSize int
+}
+
+func (r RedactionRule) matches(name string, size int) bool {
+	return (r.Name == "" || r.Name == name) && size > r.MinSize
+}
+
+// PayloadDumpOptions configure the payload dump
+type PayloadDumpOptions struct {
+	// Sink receives every payload. It is called from all goroutines running contracts and must not
+	// call into the VM.
+	Sink func(Payload)
+	// MaxSize is the number of bytes kept of each payload. Longer payloads are truncated.
+	// 0 uses DefaultPayloadMaxSize and a negative size keeps whole payloads.
+	MaxSize int
+	// Redact contains the rules for payloads whose data is not dumped at all, e.g. the code in Create
+	Redact []RedactionRule
+}
+
+// payloadDumpHolder allows storing a nil config in payloadDump
+type payloadDumpHolder struct {
+	options *PayloadDumpOptions
+}
+
+var payloadDump atomic.Value
+
+// SetPayloadDump passes every payload crossing the FFI boundary to options.Sink. Pass nil to disable the dump.
+func SetPayloadDump(options *PayloadDumpOptions) {
+	if options == nil || options.Sink == nil {
+		payloadDump.Store(payloadDumpHolder{})
+		return
+	}
+	copied := *options
+	copied.Redact = append([]RedactionRule(nil), options.Redact...)
+	if copied.MaxSize == 0 {
+		copied.MaxSize = DefaultPayloadMaxSize
+	}
+	payloadDump.Store(payloadDumpHolder{options: &copied})
+}
+
+func currentPayloadDump() *PayloadDumpOptions {
+	holder, _ := payloadDump.Load().(payloadDumpHolder)
+	return holder.options
+}
+
+func payloadDumpEnabled() bool {
+	return currentPayloadDump() != nil
+}
+
+// dumpPayload passes a copy of data to the sink if the dump is enabled
+func dumpPayload(callID uint64, direction PayloadDirection, name string, data []byte) {
+	options := currentPayloadDump()
+	if options == nil {
+		return
+	}
+	p := Payload{
+		CallID:    callID,
+		Direction: direction,
+		Name:      name,
+		Size:      len(data),
+		IsNil:     data == nil,
+		SHA256:    sha256.Sum256(data),
+	}
+	for _, rule := range options.Redact {
+		if rule.matches(name, len(data)) {
+			p.Redacted = true
+			break
+		}
+	}
+	if !p.Redacted && !p.IsNil {
+		if options.MaxSize >= 0 && len(data) > options.MaxSize {
+			data = data[:options.MaxSize]
+			p.Truncated = true
+		}
+		p.Data = append([]byte{}, data...)
+	}
+	options.Sink(p)
+}
+
+// LogPayload writes the payload to the standard logger. It can be used as PayloadDumpOptions.Sink.
+func LogPayload(p Payload) {
+	log.Print(p.String())
+}
+
+// PayloadRecorder keeps all payloads it receives, grouped by call ID. Its Record method can be
+// used as PayloadDumpOptions.Sink.
+type PayloadRecorder struct {
+	mutex sync.Mutex
+	calls map[uint64][]Payload
+}
+
+func NewPayloadRecorder() *PayloadRecorder {
+	return &PayloadRecorder{calls: make(map[uint64][]Payload)}
+}
+
+// Record adds the payload to the payloads of its call
+func (r *PayloadRecorder) Record(p Payload) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls[p.CallID] = append(r.calls[p.CallID], p)
+}
+
+// Payloads returns the payloads of the given call in the order they crossed the boundary
+func (r *PayloadRecorder) Payloads(callID uint64) []Payload {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Payload(nil), r.calls[callID]...)
+}
+
+// CallIDs returns the IDs of all calls with recorded payloads in ascending order
+func (r *PayloadRecorder) CallIDs() []uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	ids := make([]uint64, 0, len(r.calls))
+	for id := range r.calls {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Reset removes all recorded payloads
+func (r *PayloadRecorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = make(map[uint64][]Payload)
+}
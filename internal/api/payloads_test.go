@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+func withPayloadRecorder(t *testing.T, options PayloadDumpOptions) *PayloadRecorder {
+	recorder := NewPayloadRecorder()
+	options.Sink = recorder.Record
+	SetPayloadDump(&options)
+	t.Cleanup(func() { SetPayloadDump(nil) })
+	return recorder
+}
+
+func TestDumpPayload(t *testing.T) {
+	recorder := withPayloadRecorder(t, PayloadDumpOptions{
+		MaxSize: 4,
+		Redact:  []RedactionRule{{Name: "wasm"}, {Name: "msg", MinSize: 6}},
+	})
+
+	dumpPayload(3, PayloadToVM, "env", []byte("abc"))
+	dumpPayload(3, PayloadToVM, "msg", []byte("abcdef"))
+	dumpPayload(3, PayloadToVM, "msg", []byte("abcdefg"))
+	dumpPayload(3, PayloadFromVM, "read_db.value", nil)
+	dumpPayload(0, PayloadToVM, "wasm", []byte("\x00asm"))
+
+	assert.Equal(t, []uint64{0, 3}, recorder.CallIDs())
+	assert.Equal(t, []Payload{
+		{CallID: 3, Direction: PayloadToVM, Name: "env", Size: 3, SHA256: sha256.Sum256([]byte("abc")), Data: []byte("abc")},
+		{CallID: 3, Direction: PayloadToVM, Name: "msg", Size: 6, SHA256: sha256.Sum256([]byte("abcdef")), Data: []byte("abcd"), Truncated: true},
+		{CallID: 3, Direction: PayloadToVM, Name: "msg", Size: 7, SHA256: sha256.Sum256([]byte("abcdefg")), Redacted: true},
+		{CallID: 3, Direction: PayloadFromVM, Name: "read_db.value", IsNil: true, SHA256: sha256.Sum256(nil)},
+	}, recorder.Payloads(3))
+	assert.Equal(t, []Payload{
+		{CallID: 0, Direction: PayloadToVM, Name: "wasm", Size: 4, SHA256: sha256.Sum256([]byte("\x00asm")), Redacted: true},
+	}, recorder.Payloads(0))
+
+	recorder.Reset()
+	assert.Empty(t, recorder.CallIDs())
+
+	SetPayloadDump(nil)
+	dumpPayload(4, PayloadToVM, "env", []byte("abc"))
+	assert.Empty(t, recorder.CallIDs())
+}
+
+func TestDumpPayloadCopiesData(t *testing.T) {
+	recorder := withPayloadRecorder(t, PayloadDumpOptions{MaxSize: -1})
+	data := []byte("abc")
+	dumpPayload(1, PayloadToVM, "msg", data)
+	data[0] = 'x'
+	assert.Equal(t, []byte("abc"), recorder.Payloads(1)[0].Data)
+}
+
+func TestPayloadString(t *testing.T) {
+	p := Payload{CallID: 2, Direction: PayloadFromVM, Name: "result", Size: 10, Data: []byte(`{"ok"`), Truncated: true}
+	assert.Equal(t, `call 2 from_vm result: "{\"ok\""... (5 of 10 bytes)`, p.String())
+	p = Payload{CallID: 2, Direction: PayloadToVM, Name: "wasm", Size: 2, SHA256: [32]byte{0xab}, Redacted: true}
+	assert.Equal(t, "call 2 to_vm wasm: 2 bytes redacted, sha256 AB00000000000000000000000000000000000000000000000000000000000000", p.String())
+	p = Payload{Direction: PayloadFromVM, Name: "error message", IsNil: true}
+	assert.Equal(t, "call 0 from_vm error message: nil", p.String())
+}
+
+func TestDumpPayloadsOfExecute(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	wasm, err := ioutil.ReadFile("../../testdata/hackatom.wasm")
+	require.NoError(t, err)
+	checksum, err := Create(cache, wasm)
+	require.NoError(t, err)
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	store := NewLookup(gasMeter)
+	api := NewMockAPI()
+	var querier Querier = DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{types.NewCoin(100, "ATOM")})
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err = Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.NoError(t, err)
+
+	recorder := withPayloadRecorder(t, PayloadDumpOptions{})
+	info = MockInfoBin(t, "fred")
+	res, _, err := Execute(cache, checksum, env, info, []byte(`{"release":{}}`), &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.NoError(t, err)
+
+	ids := recorder.CallIDs()
+	require.Len(t, ids, 1)
+	payloads := recorder.Payloads(ids[0])
+	names := make([]string, len(payloads))
+	for i, p := range payloads {
+		names[i] = p.Name
+	}
+	assert.Equal(t, []string{"checksum", "env", "info", "msg"}, names[:4])
+	assert.Contains(t, names, "read_db.key")
+	assert.Contains(t, names, "query_external.request")
+	last := payloads[len(payloads)-1]
+	assert.Equal(t, "result", last.Name)
+	assert.Equal(t, PayloadFromVM, last.Direction)
+	assert.Equal(t, res, last.Data)
+
+	var result types.ContractResult
+	require.NoError(t, json.Unmarshal(res, &result))
+}
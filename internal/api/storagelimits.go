@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/line/wasmvm/types"
+)
+
+// storageGuard enforces the StorageLimits of a cache in the storage callbacks and keeps the metrics.
+// Keys of reads, writes and deletes are checked, the bounds of iterators are not.
+type storageGuard struct {
+	// the counters are accessed atomically and come first to be 64 bit aligned
+	largestKey      uint64
+	largestValue    uint64
+	keysNearLimit   uint64
+	valuesNearLimit uint64
+	rejectedKeys    uint64
+	rejectedValues  uint64
+
+	mtx    sync.RWMutex
+	limits types.StorageLimits
+}
+
+func newStorageGuard() *storageGuard {
+	return &storageGuard{}
+}
+
+func (g *storageGuard) setLimits(limits types.StorageLimits) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.limits = limits
+}
+
+func (g *storageGuard) getLimits() types.StorageLimits {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	return g.limits
+}
+
+// checkKey returns an error if key is longer than the limit. g can be nil.
+func (g *storageGuard) checkKey(key []byte) error {
+	if g == nil {
+		return nil
+	}
+	limit := g.getLimits().MaxKeyLength
+	return check("key", uint64(len(key)), limit, &g.largestKey, &g.keysNearLimit, &g.rejectedKeys)
+}
+
+// checkValue returns an error if value is longer than the limit. g can be nil.
+func (g *storageGuard) checkValue(value []byte) error {
+	if g == nil {
+		return nil
+	}
+	limit := g.getLimits().MaxValueLength
+	return check("value", uint64(len(value)), limit, &g.largestValue, &g.valuesNearLimit, &g.rejectedValues)
+}
+
+func check(name string, length uint64, limit uint64, largest *uint64, nearLimit *uint64, rejected *uint64) error {
+	for {
+		current := atomic.LoadUint64(largest)
+		if length <= current || atomic.CompareAndSwapUint64(largest, current, length) {
+			break
+		}
+	}
+	if limit == 0 {
+		return nil
+	}
+	if length > limit {
+		atomic.AddUint64(rejected, 1)
+		return fmt.Errorf("Storage %s too long: %d bytes exceed the limit of %d bytes", name, length, limit)
+	}
+	// length >= 90% of limit without overflowing
+	if length >= limit-limit/10 {
+		atomic.AddUint64(nearLimit, 1)
+	}
+	return nil
+}
+
+func (g *storageGuard) metrics() types.StorageMetrics {
+	return types.StorageMetrics{
+		LargestKey:      atomic.LoadUint64(&g.largestKey),
+		LargestValue:    atomic.LoadUint64(&g.largestValue),
+		KeysNearLimit:   atomic.LoadUint64(&g.keysNearLimit),
+		ValuesNearLimit: atomic.LoadUint64(&g.valuesNearLimit),
+		RejectedKeys:    atomic.LoadUint64(&g.rejectedKeys),
+		RejectedValues:  atomic.LoadUint64(&g.rejectedValues),
+	}
+}
+
+// SetStorageLimits sets the maximum key and value lengths of storage calls for all contract calls using cache
+func SetStorageLimits(cache Cache, limits types.StorageLimits) {
+	cache.storage.setLimits(limits)
+}
+
+// GetStorageMetrics returns the storage metrics of all contract calls using cache
+func GetStorageMetrics(cache Cache) types.StorageMetrics {
+	return cache.storage.metrics()
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+func TestStorageGuard(t *testing.T) {
+	guard := newStorageGuard()
+
+	// no limits, only tracking
+	require.NoError(t, guard.checkKey(make([]byte, 50)))
+	require.NoError(t, guard.checkValue(make([]byte, 5000)))
+	assert.Equal(t, types.StorageMetrics{LargestKey: 50, LargestValue: 5000}, guard.metrics())
+
+	guard.setLimits(types.StorageLimits{MaxKeyLength: 100, MaxValueLength: 1000})
+	require.NoError(t, guard.checkKey(make([]byte, 89)))
+	require.NoError(t, guard.checkKey(make([]byte, 90)))
+	require.NoError(t, guard.checkKey(make([]byte, 100)))
+	err := guard.checkKey(make([]byte, 101))
+	require.EqualError(t, err, "Storage key too long: 101 bytes exceed the limit of 100 bytes")
+	err = guard.checkValue(make([]byte, 1001))
+	require.EqualError(t, err, "Storage value too long: 1001 bytes exceed the limit of 1000 bytes")
+	require.NoError(t, guard.checkValue(make([]byte, 950)))
+
+	assert.Equal(t, types.StorageMetrics{
+		LargestKey:      101,
+		LargestValue:    5000,
+		KeysNearLimit:   2,
+		ValuesNearLimit: 1,
+		RejectedKeys:    1,
+		RejectedValues:  1,
+	}, guard.metrics())
+
+	// a nil guard allows everything
+	var none *storageGuard
+	require.NoError(t, none.checkKey(make([]byte, 1000)))
+}
+
+func TestInstantiateWithStorageLimits(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	checksum := createTestContract(t, cache)
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	store := NewLookup(gasMeter)
+	api := NewMockAPI()
+	querier := DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{})
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+
+	// the config stored by hackatom is larger than 10 bytes
+	SetStorageLimits(cache, types.StorageLimits{MaxValueLength: 10})
+	_, _, err := Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Storage value too long")
+	assert.Equal(t, uint64(1), GetStorageMetrics(cache).RejectedValues)
+
+	SetStorageLimits(cache, types.StorageLimits{MaxKeyLength: 64, MaxValueLength: 1024})
+	_, _, err = Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.NoError(t, err)
+	metrics := GetStorageMetrics(cache)
+	assert.NotZero(t, metrics.LargestKey)
+	assert.Greater(t, metrics.LargestValue, uint64(10))
+}
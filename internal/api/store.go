@@ -0,0 +1,187 @@
+package api
+
+// KVStoreWithErrors is a variant of KVStore for backends that can fail, e.g. disk based stores.
+// Errors are passed to the VM as backend errors with their messages instead of being
+// turned into panics.
+type KVStoreWithErrors interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte) error
+	Delete(key []byte) error
+
+	// Iterator over a domain of keys in ascending order. End is exclusive.
+	// Start must be less than end, or the Iterator is invalid.
+	// Iterator must be closed by caller.
+	// Errors that happen during iteration are reported by the iterator's Error method.
+	Iterator(start, end []byte) (Iterator, error)
+
+	// Iterator over a domain of keys in descending order. End is exclusive.
+	// Start must be less than end, or the Iterator is invalid.
+	// Iterator must be closed by caller.
+	ReverseIterator(start, end []byte) (Iterator, error)
+}
+
+// AdaptKVStore turns a KVStore into a KVStoreWithErrors. The errors of a KVStoreWithErrors adapted with
+// AdaptKVStoreWithErrors are returned, even if it is wrapped in other stores like GasKVStore or
+// OverlayStore. Other panics of kv, like the SDK's out of gas panics, are not recovered.
+func AdaptKVStore(kv KVStore) KVStoreWithErrors {
+	if adapter, ok := kv.(*kvStoreWithErrorsAdapter); ok {
+		return adapter.store
+	}
+	return kvStoreAdapter{store: kv}
+}
+
+// AdaptKVStoreWithErrors turns a KVStoreWithErrors into a KVStore that can be passed to the contract calls.
+// The contract calls use the errors of store directly, also when the returned KVStore is wrapped in other
+// stores like GasKVStore. When the returned KVStore is used outside of the VM, errors are raised as panics.
+func AdaptKVStoreWithErrors(store KVStoreWithErrors) KVStore {
+	if adapter, ok := store.(kvStoreAdapter); ok {
+		return adapter.store
+	}
+	return &kvStoreWithErrorsAdapter{store: store}
+}
+
+type kvStoreAdapter struct {
+	store KVStore
+}
+
+var _ KVStoreWithErrors = kvStoreAdapter{}
+
+func (a kvStoreAdapter) Get(key []byte) (value []byte, err error) {
+	defer recoverStoreError(&err)
+	return a.store.Get(key), nil
+}
+
+func (a kvStoreAdapter) Set(key, value []byte) (err error) {
+	defer recoverStoreError(&err)
+	a.store.Set(key, value)
+	return nil
+}
+
+func (a kvStoreAdapter) Delete(key []byte) (err error) {
+	defer recoverStoreError(&err)
+	a.store.Delete(key)
+	return nil
+}
+
+func (a kvStoreAdapter) Iterator(start, end []byte) (it Iterator, err error) {
+	defer recoverStoreError(&err)
+	return a.store.Iterator(start, end), nil
+}
+
+func (a kvStoreAdapter) ReverseIterator(start, end []byte) (it Iterator, err error) {
+	defer recoverStoreError(&err)
+	return a.store.ReverseIterator(start, end), nil
+}
+
+// storeError is the panic value of a kvStoreWithErrorsAdapter. It passes the error of the
+// KVStoreWithErrors through the stores wrapping the adapter, where kvStoreAdapter turns it back
+// into an error.
+type storeError struct {
+	err error
+}
+
+func (e storeError) Error() string {
+	return e.err.Error()
+}
+
+func (e storeError) Unwrap() error {
+	return e.err
+}
+
+// recoverStoreError recovers a storeError panic into err and lets all other panics continue
+func recoverStoreError(err *error) {
+	if r := recover(); r != nil {
+		se, ok := r.(storeError)
+		if !ok {
+			panic(r)
+		}
+		*err = se.err
+	}
+}
+
+type kvStoreWithErrorsAdapter struct {
+	store KVStoreWithErrors
+}
+
+var _ KVStore = (*kvStoreWithErrorsAdapter)(nil)
+
+func (a *kvStoreWithErrorsAdapter) Get(key []byte) []byte {
+	value, err := a.store.Get(key)
+	if err != nil {
+		panic(storeError{err: err})
+	}
+	return value
+}
+
+func (a *kvStoreWithErrorsAdapter) Set(key, value []byte) {
+	if err := a.store.Set(key, value); err != nil {
+		panic(storeError{err: err})
+	}
+}
+
+func (a *kvStoreWithErrorsAdapter) Delete(key []byte) {
+	if err := a.store.Delete(key); err != nil {
+		panic(storeError{err: err})
+	}
+}
+
+func (a *kvStoreWithErrorsAdapter) Iterator(start, end []byte) Iterator {
+	it, err := a.store.Iterator(start, end)
+	if err != nil {
+		panic(storeError{err: err})
+	}
+	return it
+}
+
+func (a *kvStoreWithErrorsAdapter) ReverseIterator(start, end []byte) Iterator {
+	it, err := a.store.ReverseIterator(start, end)
+	if err != nil {
+		panic(storeError{err: err})
+	}
+	return it
+}
+
+// KVStoreFuncs is a KVStoreWithErrors built from functions. It adapts stores with error returning
+// methods like the DB of cosmos-db without importing their packages. Their iterators implement
+// Iterator, so e.g. a cosmos-db DB is adapted with
+//
+//	KVStoreFuncs{
+//		GetFunc:    db.Get,
+//		SetFunc:    db.Set,
+//		DeleteFunc: db.Delete,
+//		IteratorFunc: func(start, end []byte) (Iterator, error) {
+//			return db.Iterator(start, end)
+//		},
+//		ReverseIteratorFunc: func(start, end []byte) (Iterator, error) {
+//			return db.ReverseIterator(start, end)
+//		},
+//	}
+type KVStoreFuncs struct {
+	GetFunc             func(key []byte) ([]byte, error)
+	SetFunc             func(key, value []byte) error
+	DeleteFunc          func(key []byte) error
+	IteratorFunc        func(start, end []byte) (Iterator, error)
+	ReverseIteratorFunc func(start, end []byte) (Iterator, error)
+}
+
+var _ KVStoreWithErrors = KVStoreFuncs{}
+
+func (f KVStoreFuncs) Get(key []byte) ([]byte, error) {
+	return f.GetFunc(key)
+}
+
+func (f KVStoreFuncs) Set(key, value []byte) error {
+	return f.SetFunc(key, value)
+}
+
+func (f KVStoreFuncs) Delete(key []byte) error {
+	return f.DeleteFunc(key)
+}
+
+func (f KVStoreFuncs) Iterator(start, end []byte) (Iterator, error) {
+	return f.IteratorFunc(start, end)
+}
+
+func (f KVStoreFuncs) ReverseIterator(start, end []byte) (Iterator, error) {
+	return f.ReverseIteratorFunc(start, end)
+}
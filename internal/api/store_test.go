@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+func TestKVStoreAdapters(t *testing.T) {
+	kv := NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT))
+	store := AdaptKVStore(kv)
+
+	require.NoError(t, store.Set([]byte("foo"), []byte("bar")))
+	value, err := store.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), value)
+	it, err := store.Iterator(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, it.Valid())
+	it.Close()
+	require.NoError(t, store.Delete([]byte("foo")))
+	assert.Nil(t, kv.Get([]byte("foo")))
+
+	// adapting back and forth returns the original
+	assert.Equal(t, KVStore(kv), AdaptKVStoreWithErrors(store))
+	failing := NewMockFailureKVStore(kv)
+	assert.Equal(t, KVStoreWithErrors(failing), AdaptKVStore(AdaptKVStoreWithErrors(failing)))
+
+	// errors become panics outside of the VM
+	assert.PanicsWithError(t, `mock failure - set "foo"`, func() {
+		AdaptKVStoreWithErrors(failing).Set([]byte("foo"), []byte("bar"))
+	})
+}
+
+func TestLookupClone(t *testing.T) {
+	lookup := NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT))
+	lookup.Set([]byte("a"), []byte("1"))
+	lookup.Set([]byte("b"), []byte("2"))
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	clone := lookup.Clone(gasMeter)
+	assert.Equal(t, []byte("1"), clone.Get([]byte("a")))
+	assert.Equal(t, uint64(GetPrice), gasMeter.GasConsumed())
+	clone.Set([]byte("a"), []byte("3"))
+	clone.Delete([]byte("b"))
+	lookup.Set([]byte("c"), []byte("4"))
+
+	assert.Equal(t, []byte("1"), lookup.Get([]byte("a")))
+	assert.Equal(t, []byte("2"), lookup.Get([]byte("b")))
+	assert.Equal(t, []byte("3"), clone.Get([]byte("a")))
+	assert.Nil(t, clone.Get([]byte("b")))
+	assert.Nil(t, clone.Get([]byte("c")))
+}
+
+func TestStoreErrorsThroughWrappers(t *testing.T) {
+	failing := AdaptKVStoreWithErrors(NewMockFailureKVStore(NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT))))
+	gasMeter := NewGasMeter(TESTING_GAS_LIMIT)
+	wrapped := NewGasKVStore(NewGasKVStore(failing, gasMeter, DefaultKVGasConfig()), gasMeter, DefaultKVGasConfig())
+
+	store := buildDBState(wrapped, 0, nil, nil).Store
+	err := store.Set([]byte("foo"), []byte("bar"))
+	assert.EqualError(t, err, `mock failure - set "foo"`)
+	err = store.Delete([]byte("foo"))
+	assert.EqualError(t, err, `mock failure - delete "foo"`)
+	value, err := store.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// other panics are not recovered
+	assert.PanicsWithValue(t, ErrorOutOfGas{Descriptor: GasWriteCostFlatDesc}, func() {
+		tiny := AdaptKVStore(NewGasKVStore(failing, NewGasMeter(1), DefaultKVGasConfig()))
+		_ = tiny.Set([]byte("foo"), []byte("bar"))
+	})
+}
+
+func TestInstantiateWithStoreErrors(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	checksum := createTestContract(t, cache)
+
+	gasMeter := NewMockGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	store := AdaptKVStoreWithErrors(NewMockFailureKVStore(NewLookup(gasMeter)))
+	api := NewMockAPI()
+	querier := DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{})
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mock failure - set")
+	var outOfGas types.OutOfGasError
+	assert.False(t, errors.As(err, &outOfGas))
+}
+
+func TestInstantiateWithWrappedStoreErrors(t *testing.T) {
+	cache, cleanup := withCache(t)
+	defer cleanup()
+	checksum := createTestContract(t, cache)
+
+	gasMeter := NewGasMeter(TESTING_GAS_LIMIT)
+	igasMeter := GasMeter(gasMeter)
+	failing := AdaptKVStoreWithErrors(NewMockFailureKVStore(NewLookup(NewMockGasMeter(TESTING_GAS_LIMIT))))
+	store := NewGasKVStore(failing, gasMeter, DefaultKVGasConfig())
+	api := NewMockAPI()
+	querier := DefaultQuerier(MOCK_CONTRACT_ADDR, types.Coins{})
+	env := MockEnvBin(t)
+	info := MockInfoBin(t, "creator")
+
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := Instantiate(cache, checksum, env, info, msg, &igasMeter, store, api, &querier, TESTING_GAS_LIMIT, TESTING_PRINT_DEBUG, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mock failure - set")
+	assert.NotContains(t, err.Error(), "panic")
+}
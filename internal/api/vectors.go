@@ -0,0 +1,33 @@
+package api
+
+// vectorKind describes what an UnmanagedVector returned by Rust contains
+type vectorKind string
+
+const (
+	vectorResult       vectorKind = "result"
+	vectorErrorMessage vectorKind = "error message"
+	vectorChecksum     vectorKind = "checksum"
+	vectorCode         vectorKind = "code"
+	vectorCapabilities vectorKind = "capabilities"
+)
+
+// VectorCounts counts the UnmanagedVectors of one kind that Rust handed to Go in a contract call.
+// Go owns those and has to destroy every one of them. Vectors that own no memory are not counted.
+//
+// Vectors created by the Go callbacks are owned by Rust and do not show up here.
+type VectorCounts struct {
+	Received  uint64
+	Destroyed uint64
+}
+
+// Outstanding returns the number of vectors that were not destroyed yet
+func (c VectorCounts) Outstanding() uint64 {
+	return c.Received - c.Destroyed
+}
+
+// VectorLeak reports UnmanagedVectors of one kind that were not destroyed when a contract call ended
+type VectorLeak struct {
+	CallID uint64
+	Kind   string
+	Counts VectorCounts
+}
@@ -0,0 +1,104 @@
+//go:build vectordebug
+// +build vectordebug
+
+package api
+
+import (
+	"log"
+	"sync"
+)
+
+// vectorOwner is the call and kind of an UnmanagedVector that Go has to destroy
+type vectorOwner struct {
+	callID uint64
+	kind   vectorKind
+}
+
+var (
+	vectorsMutex sync.Mutex
+	// ownedVectors contains all vectors Go has to destroy, indexed by the pointer to their data
+	ownedVectors = make(map[uintptr]vectorOwner)
+	// vectorCounts contains the counts of every running contract call, indexed by call ID
+	vectorCounts = make(map[uint64]map[vectorKind]*VectorCounts)
+	vectorLeaks  []VectorLeak
+)
+
+// callVectorCounts returns the counts of a call and kind. Must be called with vectorsMutex held.
+func callVectorCounts(callID uint64, kind vectorKind, create bool) *VectorCounts {
+	kinds := vectorCounts[callID]
+	if kinds == nil {
+		if !create {
+			return nil
+		}
+		kinds = make(map[vectorKind]*VectorCounts)
+		vectorCounts[callID] = kinds
+	}
+	counts := kinds[kind]
+	if counts == nil && create {
+		counts = &VectorCounts{}
+		kinds[kind] = counts
+	}
+	return counts
+}
+
+// trackVector records that Go received the vector with data at ptr. ptr is 0 if the vector owns no memory.
+func trackVector(callID uint64, kind vectorKind, ptr uintptr) {
+	if ptr == 0 {
+		return
+	}
+	vectorsMutex.Lock()
+	defer vectorsMutex.Unlock()
+	ownedVectors[ptr] = vectorOwner{callID: callID, kind: kind}
+	callVectorCounts(callID, kind, true).Received++
+}
+
+// untrackVector records that Go destroyed the vector with data at ptr
+func untrackVector(ptr uintptr) {
+	if ptr == 0 {
+		return
+	}
+	vectorsMutex.Lock()
+	defer vectorsMutex.Unlock()
+	owner, ok := ownedVectors[ptr]
+	if !ok {
+		return
+	}
+	delete(ownedVectors, ptr)
+	// the call may have ended already, in which case the vector was reported as leaked
+	if counts := callVectorCounts(owner.callID, owner.kind, false); counts != nil {
+		counts.Destroyed++
+	}
+}
+
+// reportVectors drops the counts of a call at its end and reports all vectors that were not destroyed
+func reportVectors(callID uint64) {
+	vectorsMutex.Lock()
+	defer vectorsMutex.Unlock()
+	kinds := vectorCounts[callID]
+	delete(vectorCounts, callID)
+	for kind, counts := range kinds {
+		if counts.Outstanding() == 0 {
+			continue
+		}
+		log.Printf("UnmanagedVector leak: call %d ended with %d of %d %s vectors not destroyed", callID, counts.Outstanding(), counts.Received, kind)
+		vectorLeaks = append(vectorLeaks, VectorLeak{CallID: callID, Kind: string(kind), Counts: *counts})
+	}
+}
+
+// OutstandingVectors returns the number of UnmanagedVectors that Go received from Rust and did not destroy yet.
+// This is only tracked in builds with the vectordebug tag and always 0 otherwise.
+func OutstandingVectors() int {
+	vectorsMutex.Lock()
+	defer vectorsMutex.Unlock()
+	return len(ownedVectors)
+}
+
+// TakeVectorLeaks returns and removes the leaks reported at the end of contract calls.
+// This is only tracked in builds with the vectordebug tag and always empty otherwise.
+func TakeVectorLeaks() []VectorLeak {
+	vectorsMutex.Lock()
+	defer vectorsMutex.Unlock()
+	leaks := vectorLeaks
+	vectorLeaks = nil
+	return leaks
+}
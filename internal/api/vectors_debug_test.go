@@ -0,0 +1,46 @@
+//go:build vectordebug
+// +build vectordebug
+
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectorAccounting(t *testing.T) {
+	_ = TakeVectorLeaks()
+	outstanding := OutstandingVectors()
+
+	callID := startCall()
+	trackVector(callID, vectorResult, 0x1000)
+	trackVector(callID, vectorErrorMessage, 0x2000)
+	trackVector(callID, vectorResult, 0)
+	require.Equal(t, outstanding+2, OutstandingVectors())
+	require.Equal(t, VectorCounts{Received: 1}, *vectorCounts[callID][vectorResult])
+
+	untrackVector(0x1000)
+	untrackVector(0x1000)
+	require.Equal(t, VectorCounts{Received: 1, Destroyed: 1}, *vectorCounts[callID][vectorResult])
+	require.Equal(t, outstanding+1, OutstandingVectors())
+
+	endCall(callID)
+	require.Equal(t, []VectorLeak{{CallID: callID, Kind: "error message", Counts: VectorCounts{Received: 1}}}, TakeVectorLeaks())
+	require.Empty(t, TakeVectorLeaks())
+	require.NotContains(t, vectorCounts, callID)
+
+	// destroying a leaked vector after the end of its call is still accounted for
+	untrackVector(0x2000)
+	require.Equal(t, outstanding, OutstandingVectors())
+	require.NotContains(t, vectorCounts, callID)
+}
+
+func TestVectorAccountingNoLeak(t *testing.T) {
+	_ = TakeVectorLeaks()
+	callID := startCall()
+	trackVector(callID, vectorResult, 0x3000)
+	untrackVector(0x3000)
+	endCall(callID)
+	require.Empty(t, TakeVectorLeaks())
+}
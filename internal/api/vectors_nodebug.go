@@ -0,0 +1,22 @@
+//go:build !vectordebug
+// +build !vectordebug
+
+package api
+
+func trackVector(callID uint64, kind vectorKind, ptr uintptr) {}
+
+func untrackVector(ptr uintptr) {}
+
+func reportVectors(callID uint64) {}
+
+// OutstandingVectors returns the number of UnmanagedVectors that Go received from Rust and did not destroy yet.
+// This is only tracked in builds with the vectordebug tag and always 0 otherwise.
+func OutstandingVectors() int {
+	return 0
+}
+
+// TakeVectorLeaks returns and removes the leaks reported at the end of contract calls.
+// This is only tracked in builds with the vectordebug tag and always empty otherwise.
+func TakeVectorLeaks() []VectorLeak {
+	return nil
+}
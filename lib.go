@@ -2,6 +2,7 @@ package cosmwasm
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/line/wasmvm/internal/api"
@@ -17,9 +18,62 @@ type WasmCode []byte
 // KVStore is a reference to some sub-kvstore that is valid for one instance of a code
 type KVStore = api.KVStore
 
+// Iterator is the iterator returned by a KVStore. Iterators of tm-db and cosmos-db implement it.
+type Iterator = api.Iterator
+
+// KVStoreFuncs builds a KVStoreWithErrors from functions, e.g. to adapt a cosmos-db DB.
+// For tm-db based stores, see the github.com/line/wasmvm/tmdb module.
+type KVStoreFuncs = api.KVStoreFuncs
+
+// KVStoreWithErrors is a variant of KVStore for backends that can fail. Use AdaptKVStoreWithErrors
+// to pass it to the contract calls.
+type KVStoreWithErrors = api.KVStoreWithErrors
+
+// MemDB is an in-memory KVStoreWithErrors for tools and tests that do not run on a chain database.
+type MemDB = api.MemDB
+
+// NewMemDB creates an empty MemDB
+func NewMemDB() *MemDB {
+	return api.NewMemDB()
+}
+
+// AdaptKVStore turns a KVStore into a KVStoreWithErrors. Only the errors of stores adapted with
+// AdaptKVStoreWithErrors below kv are returned.
+func AdaptKVStore(kv KVStore) KVStoreWithErrors {
+	return api.AdaptKVStore(kv)
+}
+
+// AdaptKVStoreWithErrors turns a KVStoreWithErrors into a KVStore that can be passed to the contract calls.
+// Errors of store are reported to the VM as backend errors with their messages, also when the returned
+// KVStore is wrapped in other stores like GasKVStore. When the returned KVStore is used outside of the VM,
+// errors are raised as panics.
+func AdaptKVStoreWithErrors(store KVStoreWithErrors) KVStore {
+	return api.AdaptKVStoreWithErrors(store)
+}
+
 // GoAPI is a reference to some "precompiles", go callbacks
 type GoAPI = api.GoAPI
 
+// NewPlainGoAPI returns a GoAPI for tools without a chain that uses the address bytes as canonical address,
+// so every non-empty address up to 64 bytes is valid. The calls cost canonicalizeCost and humanizeCost gas.
+func NewPlainGoAPI(canonicalizeCost, humanizeCost uint64) GoAPI {
+	const maxAddressLength = 64
+	return GoAPI{
+		HumanAddress: func(canon []byte) (string, uint64, error) {
+			if len(canon) == 0 || len(canon) > maxAddressLength {
+				return "", humanizeCost, errors.New("invalid canonical address length")
+			}
+			return string(canon), humanizeCost, nil
+		},
+		CanonicalAddress: func(human string) ([]byte, uint64, error) {
+			if len(human) == 0 || len(human) > maxAddressLength {
+				return nil, canonicalizeCost, errors.New("invalid address length")
+			}
+			return []byte(human), canonicalizeCost, nil
+		},
+	}
+}
+
 // Querier lets us make read-only queries on other modules
 type Querier = types.Querier
 
@@ -32,6 +86,10 @@ type GasMeter = api.GasMeter
 type VM struct {
 	cache      api.Cache
 	printDebug bool
+	// gasSchedule is optional, see SetGasSchedule
+	gasSchedule *types.GasSchedule
+	// recorder is optional, see SetCallRecorder
+	recorder CallRecorder
 }
 
 // NewVM creates a new VM.
@@ -107,6 +165,14 @@ func (vm *VM) GetMetrics() (*types.Metrics, error) {
 	return api.GetMetrics(vm.cache)
 }
 
+// SetStorageLimits sets the maximum lengths of keys and values contracts can use when reading, writing
+// and deleting storage entries. Longer keys and values are rejected with a backend error before they
+// reach the KVStore. The VM applies its own, larger limits in any case. The default is no limit.
+// How close contracts get to the limits is reported in the Storage field of GetMetrics.
+func (vm *VM) SetStorageLimits(limits types.StorageLimits) {
+	api.SetStorageLimits(vm.cache, limits)
+}
+
 // Instantiate will create a new contract based on the given Checksum.
 // We can set the initMsg (contract "genesis") here, and it then receives
 // an account and address and can be invoked (Execute) many times.
@@ -126,33 +192,74 @@ func (vm *VM) Instantiate(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
 	}
+	return vm.instantiate(checksum, envBin, env.Block.Height, info, initMsg, store, goapi, querier, gasMeter, gasLimit, deserCost, out)
+}
+
+// InstantiateEncodedEnv works like Instantiate but takes the already JSON encoded Env,
+// e.g. created by types.BlockEnv, and its block height, which selects the gas config.
+func (vm *VM) InstantiateEncodedEnv(
+	checksum Checksum,
+	envBin []byte,
+	height uint64,
+	info types.MessageInfo,
+	initMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+	outputs ...*CallOutput,
+) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
+	return vm.instantiate(checksum, envBin, height, info, initMsg, store, goapi, querier, gasMeter, gasLimit, deserCost, out)
+}
+
+// instantiate implements Instantiate and InstantiateEncodedEnv. height selects the gas config.
+func (vm *VM) instantiate(
+	checksum Checksum,
+	envBin []byte,
+	height uint64,
+	info types.MessageInfo,
+	initMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+	out *CallOutput,
+) (*types.Response, uint64, error) {
 	infoBin, err := json.Marshal(info)
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.Instantiate(vm.cache, checksum, envBin, infoBin, initMsg, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryInstantiate, checksum, envBin, infoBin, initMsg, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.Instantiate(vm.cache, checksum, envBin, infoBin, initMsg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var result types.ContractResult
 	err = json.Unmarshal(data, &result)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if result.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", result.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", result.Err))
 	}
 	return result.Ok, gasUsed, nil
 }
@@ -174,33 +281,192 @@ func (vm *VM) Execute(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
 	}
+	return vm.execute(checksum, envBin, env.Block.Height, info, executeMsg, store, goapi, querier, gasMeter, gasLimit, deserCost, out)
+}
+
+// ExecuteEncodedEnv works like Execute but takes the already JSON encoded Env,
+// e.g. created by types.BlockEnv, and its block height, which selects the gas config.
+func (vm *VM) ExecuteEncodedEnv(
+	checksum Checksum,
+	envBin []byte,
+	height uint64,
+	info types.MessageInfo,
+	executeMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+	outputs ...*CallOutput,
+) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
+	return vm.execute(checksum, envBin, height, info, executeMsg, store, goapi, querier, gasMeter, gasLimit, deserCost, out)
+}
+
+// execute implements Execute and ExecuteEncodedEnv. height selects the gas config.
+func (vm *VM) execute(
+	checksum Checksum,
+	envBin []byte,
+	height uint64,
+	info types.MessageInfo,
+	executeMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+	out *CallOutput,
+) (*types.Response, uint64, error) {
 	infoBin, err := json.Marshal(info)
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.Execute(vm.cache, checksum, envBin, infoBin, executeMsg, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryExecute, checksum, envBin, infoBin, executeMsg, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
+	data, gasUsed, err := api.Execute(vm.cache, checksum, envBin, infoBin, executeMsg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
+	}
+	var result types.ContractResult
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
+	}
+	if result.Err != "" {
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", result.Err))
+	}
+	return result.Ok, gasUsed, nil
+}
+
+// BatchItem is one message to be executed as part of ExecuteBatch.
+type BatchItem struct {
+	Env types.Env
+	// EncodedEnv is the already JSON encoded Env, e.g. created by types.BlockEnv.
+	// If set, it is used instead of Env.
+	EncodedEnv []byte
+	// Height is the block height of EncodedEnv, e.g. BlockEnv.Height(). Only used with EncodedEnv.
+	Height   uint64
+	Info     types.MessageInfo
+	Msg      []byte
+	GasLimit uint64
+	// Output requests optional outputs of this item, see CallOutput. Optional.
+	Output *CallOutput
+}
 
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+// BatchResult is the outcome of executing one BatchItem. Response, GasUsed and Err
+// have the same meaning as the return values of Execute.
+type BatchResult struct {
+	Response *types.Response
+	GasUsed  uint64
+	Err      error
+}
+
+// ExecuteBatch calls a given contract with many messages in sequence. Every item runs on its own
+// branch of store (see BranchStore), which is written to store only if the item succeeds. So later
+// items see the state changes of earlier successful ones and a failing item leaves no partial writes
+// behind, like a failing transaction on chain. The API and the querier are set up for the FFI once
+// per batch, but each item still crosses the FFI and encodes its Env and MessageInfo, so the cost per
+// item is about the one of Execute (compare BenchmarkExecuteBatch/batch and BenchmarkExecuteBatch/individual).
+//
+// A failing item does not abort the batch. Its error is returned in the item's BatchResult and
+// execution continues with the next item. The returned slice has the same length and order as items.
+// Writing a successful item's branch to store can fail for stores adapted with AdaptKVStoreWithErrors,
+// the item then fails with that error.
+//
+// If a gas schedule is set, every item is charged with the config at its height, like with Execute.
+func (vm *VM) ExecuteBatch(
+	checksum Checksum,
+	items []BatchItem,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	deserCost types.UFraction,
+) []BatchResult {
+	results := make([]BatchResult, len(items))
+	calls := make([]api.BatchCall, 0, len(items))
+	for idx, item := range items {
+		item.Output.Reset()
+		envBin, height := item.EncodedEnv, item.Height
+		if envBin == nil {
+			var err error
+			envBin, err = json.Marshal(item.Env)
+			if err != nil {
+				results[idx].Err = err
+				continue
+			}
+			height = item.Env.Block.Height
+		}
+		infoBin, err := json.Marshal(item.Info)
+		if err != nil {
+			results[idx].Err = err
+			continue
+		}
+		// each call gets its own branch of store and goapi and querier priced with its own config
+		// and is recorded on its own
+		overlay, callStore := BranchStore(store)
+		callAPI, callQuerier := goapi, querier
+		rec := vm.startRecording(EntryExecute, checksum, envBin, infoBin, item.Msg, item.GasLimit, &callStore, &callAPI, &callQuerier, gasMeter)
+		gas, err := startGas(vm.gasConfig(height, deserCost), item.GasLimit, &callAPI, &callQuerier)
+		if err != nil {
+			results[idx].Err = err
+			continue
+		}
+		idx, out := idx, item.Output
+		calls = append(calls, api.BatchCall{
+			Env:      envBin,
+			Info:     infoBin,
+			Msg:      item.Msg,
+			GasLimit: gas.vmLimit,
+			Store:    callStore,
+			API:      &callAPI,
+			Querier:  &callQuerier,
+			Output:   out,
+			Finish: func(res api.BatchCallResult) {
+				resp, gasUsed, err := vm.parseBatchResult(res, gas, rec, out)
+				// the next items must see the writes of this one
+				if err == nil {
+					if err = overlay.Commit(); err != nil {
+						resp, err = nil, failed(out, err)
+					}
+				}
+				results[idx] = BatchResult{Response: resp, GasUsed: gasUsed, Err: err}
+			},
+		})
+	}
+
+	api.ExecuteBatch(vm.cache, checksum, calls, &gasMeter, store, &goapi, &querier, vm.printDebug)
+	return results
+}
+
+func (vm *VM) parseBatchResult(res api.BatchCallResult, gas callGas, rec *callRecording, out *CallOutput) (*types.Response, uint64, error) {
+	gasUsed, err := gas.finish(res.GasUsed, res.Data, res.Err)
+	rec.finish(gas.config, res.Data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
 
-	gasUsed += gasForDeserialization
 	var result types.ContractResult
-	err = json.Unmarshal(data, &result)
+	err = json.Unmarshal(res.Data, &result)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if result.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", result.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", result.Err))
 	}
 	return result.Ok, gasUsed, nil
 }
@@ -218,29 +484,68 @@ func (vm *VM) Query(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) ([]byte, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.Query(vm.cache, checksum, envBin, queryMsg, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	return vm.query(checksum, envBin, env.Block.Height, queryMsg, store, goapi, querier, gasMeter, gasLimit, deserCost, out)
+}
+
+// QueryEncodedEnv works like Query but takes the already JSON encoded Env,
+// e.g. created by types.BlockEnv, and its block height, which selects the gas config.
+func (vm *VM) QueryEncodedEnv(
+	checksum Checksum,
+	envBin []byte,
+	height uint64,
+	queryMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+	outputs ...*CallOutput,
+) ([]byte, uint64, error) {
+	out := callOutput(outputs)
+	return vm.query(checksum, envBin, height, queryMsg, store, goapi, querier, gasMeter, gasLimit, deserCost, out)
+}
+
+// query implements Query and QueryEncodedEnv. height selects the gas config.
+func (vm *VM) query(
+	checksum Checksum,
+	envBin []byte,
+	height uint64,
+	queryMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+	out *CallOutput,
+) ([]byte, uint64, error) {
+	rec := vm.startRecording(EntryQuery, checksum, envBin, nil, queryMsg, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.Query(vm.cache, checksum, envBin, queryMsg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.QueryResponse
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -261,29 +566,32 @@ func (vm *VM) Migrate(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.Migrate(vm.cache, checksum, envBin, migrateMsg, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryMigrate, checksum, envBin, nil, migrateMsg, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.Migrate(vm.cache, checksum, envBin, migrateMsg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.ContractResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -304,29 +612,32 @@ func (vm *VM) Sudo(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.Sudo(vm.cache, checksum, envBin, sudoMsg, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntrySudo, checksum, envBin, nil, sudoMsg, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.Sudo(vm.cache, checksum, envBin, sudoMsg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.ContractResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -345,7 +656,9 @@ func (vm *VM) Reply(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.Response, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -354,24 +667,25 @@ func (vm *VM) Reply(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.Reply(vm.cache, checksum, envBin, replyBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryReply, checksum, envBin, nil, replyBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.Reply(vm.cache, checksum, envBin, replyBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.ContractResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -388,7 +702,9 @@ func (vm *VM) IBCChannelOpen(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.IBC3ChannelOpenResponse, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -397,24 +713,25 @@ func (vm *VM) IBCChannelOpen(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.IBCChannelOpen(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryIBCChannelOpen, checksum, envBin, nil, msgBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.IBCChannelOpen(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.IBCChannelOpenResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -431,7 +748,9 @@ func (vm *VM) IBCChannelConnect(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.IBCBasicResponse, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -440,24 +759,25 @@ func (vm *VM) IBCChannelConnect(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.IBCChannelConnect(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryIBCChannelConnect, checksum, envBin, nil, msgBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.IBCChannelConnect(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.IBCBasicResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -474,7 +794,9 @@ func (vm *VM) IBCChannelClose(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.IBCBasicResponse, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -483,24 +805,25 @@ func (vm *VM) IBCChannelClose(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.IBCChannelClose(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryIBCChannelClose, checksum, envBin, nil, msgBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.IBCChannelClose(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.IBCBasicResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -517,7 +840,9 @@ func (vm *VM) IBCPacketReceive(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.IBCReceiveResult, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -526,21 +851,26 @@ func (vm *VM) IBCPacketReceive(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.IBCPacketReceive(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryIBCPacketReceive, checksum, envBin, nil, msgBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.IBCPacketReceive(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.IBCReceiveResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
+	}
+	if resp.Err != "" {
+		// the contract error is returned in the result, but the call failed
+		_ = failed(out, nil)
 	}
 	return &resp, gasUsed, nil
 }
@@ -558,7 +888,9 @@ func (vm *VM) IBCPacketAck(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.IBCBasicResponse, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -567,24 +899,25 @@ func (vm *VM) IBCPacketAck(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.IBCPacketAck(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryIBCPacketAck, checksum, envBin, nil, msgBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.IBCPacketAck(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.IBCBasicResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -602,7 +935,9 @@ func (vm *VM) IBCPacketTimeout(
 	gasMeter GasMeter,
 	gasLimit uint64,
 	deserCost types.UFraction,
+	outputs ...*CallOutput,
 ) (*types.IBCBasicResponse, uint64, error) {
+	out := callOutput(outputs)
 	envBin, err := json.Marshal(env)
 	if err != nil {
 		return nil, 0, err
@@ -611,24 +946,25 @@ func (vm *VM) IBCPacketTimeout(
 	if err != nil {
 		return nil, 0, err
 	}
-	data, gasUsed, err := api.IBCPacketTimeout(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gasLimit, vm.printDebug)
+	rec := vm.startRecording(EntryIBCPacketTimeout, checksum, envBin, nil, msgBin, gasLimit, &store, &goapi, &querier, gasMeter)
+	gas, err := startGas(vm.gasConfig(env.Block.Height, deserCost), gasLimit, &goapi, &querier)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, 0, err
 	}
-
-	gasForDeserialization := deserCost.Mul(uint64(len(data))).Floor()
-	if gasLimit < gasForDeserialization+gasUsed {
-		return nil, gasUsed, fmt.Errorf("Insufficient gas left to deserialize contract execution result (%d bytes)", len(data))
+	data, gasUsed, err := api.IBCPacketTimeout(vm.cache, checksum, envBin, msgBin, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, out)
+	gasUsed, err = gas.finish(gasUsed, data, err)
+	rec.finish(gas.config, data, gasUsed, err)
+	if err != nil {
+		return nil, gasUsed, failed(out, err)
 	}
-	gasUsed += gasForDeserialization
 
 	var resp types.IBCBasicResult
 	err = json.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, gasUsed, err
+		return nil, gasUsed, failed(out, err)
 	}
 	if resp.Err != "" {
-		return nil, gasUsed, fmt.Errorf("%s", resp.Err)
+		return nil, gasUsed, failed(out, fmt.Errorf("%s", resp.Err))
 	}
 	return resp.Ok, gasUsed, nil
 }
@@ -1,7 +1,9 @@
 package cosmwasm
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -23,7 +25,8 @@ const (
 const CYBERPUNK_TEST_CONTRACT = "./testdata/cyberpunk.wasm"
 const HACKATOM_TEST_CONTRACT = "./testdata/hackatom.wasm"
 
-func withVM(t *testing.T) *VM {
+func withVM(t testing.TB) *VM {
+	api.RequireNoVectorLeaks(t)
 	tmpdir, err := ioutil.TempDir("", "wasmvm-testing")
 	require.NoError(t, err)
 	vm, err := NewVM(tmpdir, TESTING_FEATURES, TESTING_MEMORY_LIMIT, TESTING_PRINT_DEBUG, TESTING_CACHE_SIZE)
@@ -36,7 +39,7 @@ func withVM(t *testing.T) *VM {
 	return vm
 }
 
-func createTestContract(t *testing.T, vm *VM, path string) Checksum {
+func createTestContract(t testing.TB, vm *VM, path string) Checksum {
 	wasm, err := ioutil.ReadFile(path)
 	require.NoError(t, err)
 	checksum, err := vm.Create(wasm)
@@ -44,6 +47,25 @@ func createTestContract(t *testing.T, vm *VM, path string) Checksum {
 	return checksum
 }
 
+func TestPlainGoAPI(t *testing.T) {
+	goapi := NewPlainGoAPI(440, 550)
+	canon, cost, err := goapi.CanonicalAddress("creator")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("creator"), canon)
+	assert.Equal(t, uint64(440), cost)
+	human, cost, err := goapi.HumanAddress(canon)
+	require.NoError(t, err)
+	assert.Equal(t, "creator", human)
+	assert.Equal(t, uint64(550), cost)
+
+	_, cost, err = goapi.CanonicalAddress("")
+	require.Error(t, err)
+	assert.Equal(t, uint64(440), cost)
+	_, _, err = goapi.HumanAddress(make([]byte, 65))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid canonical address length")
+}
+
 func TestCreateAndGet(t *testing.T) {
 	vm := withVM(t)
 
@@ -277,3 +299,319 @@ func TestGetMetrics(t *testing.T) {
 	require.Equal(t, uint64(0), metrics.SizePinnedMemoryCache)
 	require.InEpsilon(t, 5602873, metrics.SizeMemoryCache, 0.18)
 }
+
+const QUEUE_TEST_CONTRACT = "./testdata/queue.wasm"
+
+func instantiateQueue(t testing.TB, vm *VM, checksum Checksum) (*api.Lookup, api.MockGasMeter) {
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+
+	env := api.MockEnv()
+	info := api.MockInfo("creator", nil)
+	_, _, err := vm.Instantiate(checksum, env, info, []byte(`{}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, types.UFraction{Numerator: 1, Denominator: 1})
+	require.NoError(t, err)
+	return store, gasMeter
+}
+
+func enqueueItems(n int) []BatchItem {
+	items := make([]BatchItem, n)
+	for i := range items {
+		items[i] = BatchItem{
+			Env:      api.MockEnv(),
+			Info:     api.MockInfo("creator", nil),
+			Msg:      []byte(fmt.Sprintf(`{"enqueue":{"value":%d}}`, i)),
+			GasLimit: TESTING_GAS_LIMIT,
+		}
+	}
+	return items
+}
+
+func TestExecuteBatch(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, QUEUE_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+
+	items := enqueueItems(5)
+	// an invalid message in the middle must not affect the other items
+	items[2].Msg = []byte(`{"unknown":{}}`)
+
+	// run the items individually as the reference
+	store1, gasMeter1 := instantiateQueue(t, vm, checksum)
+	expected := make([]BatchResult, len(items))
+	for i, item := range items {
+		res, gasUsed, err := vm.Execute(checksum, item.Env, item.Info, item.Msg, store1, *goapi, querier, gasMeter1, item.GasLimit, deserCost)
+		expected[i] = BatchResult{Response: res, GasUsed: gasUsed, Err: err}
+	}
+
+	store2, gasMeter2 := instantiateQueue(t, vm, checksum)
+	results := vm.ExecuteBatch(checksum, items, store2, *goapi, querier, gasMeter2, deserCost)
+	require.Len(t, results, len(items))
+	for i := range items {
+		assert.Equal(t, expected[i].Response, results[i].Response, "item %d", i)
+		assert.Equal(t, expected[i].GasUsed, results[i].GasUsed, "item %d", i)
+		assert.Equal(t, expected[i].Err, results[i].Err, "item %d", i)
+	}
+	require.Error(t, results[2].Err)
+	assert.Equal(t, gasMeter1.GasConsumed(), gasMeter2.GasConsumed())
+
+	// both stores see the same queue
+	env := api.MockEnv()
+	query := []byte(`{"sum":{}}`)
+	data1, _, err := vm.Query(checksum, env, query, store1, *goapi, querier, gasMeter1, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	data2, _, err := vm.Query(checksum, env, query, store2, *goapi, querier, gasMeter2, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	assert.Equal(t, `{"sum":8}`, string(data2))
+	assert.Equal(t, data1, data2)
+}
+
+func TestExecuteBatchPricesItemsAtTheirHeight(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := vm.Instantiate(checksum, api.MockEnv(), api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+
+	// the address API gets more expensive at height 100
+	v1 := types.DefaultGasConfig(deserCost)
+	v2 := v1
+	v2.CanonicalizeCostPerByte = types.UFraction{Numerator: 1000, Denominator: 1}
+	v2.HumanizeCostPerByte = types.UFraction{Numerator: 1000, Denominator: 1}
+	schedule, err := types.NewGasSchedule(types.GasScheduleVersion{Height: 0, Config: v1}, types.GasScheduleVersion{Height: 100, Config: v2})
+	require.NoError(t, err)
+	vm.SetGasSchedule(schedule)
+	defer vm.SetGasSchedule(nil)
+
+	items := make([]BatchItem, 3)
+	for i, height := range []uint64{150, 50, 150} {
+		env := api.MockEnv()
+		env.Block.Height = height
+		items[i] = BatchItem{
+			Env:      env,
+			Info:     api.MockInfo("fred", nil),
+			Msg:      []byte(`{"user_errors_in_api_calls":{}}`),
+			GasLimit: TESTING_GAS_LIMIT,
+		}
+	}
+	results := vm.ExecuteBatch(checksum, items, store, *goapi, querier, gasMeter, deserCost)
+	require.Len(t, results, len(items))
+	for i, item := range items {
+		require.NoError(t, results[i].Err, "item %d", i)
+		_, gasUsed, err := vm.Execute(checksum, item.Env, item.Info, item.Msg, store, *goapi, querier, gasMeter, item.GasLimit, deserCost)
+		require.NoError(t, err)
+		assert.Equal(t, gasUsed, results[i].GasUsed, "item %d", i)
+	}
+	assert.Greater(t, results[0].GasUsed, results[1].GasUsed)
+	lowGas := results[1].GasUsed
+
+	// an encoded Env is priced at the height passed along with it
+	blockEnv, err := types.NewBlockEnv(items[0].Env.Block)
+	require.NoError(t, err)
+	envBin, err := blockEnv.EncodeEnv(items[0].Env)
+	require.NoError(t, err)
+	encoded := items[0]
+	encoded.Env = types.Env{}
+	encoded.EncodedEnv, encoded.Height = envBin, blockEnv.Height()
+	results = vm.ExecuteBatch(checksum, []BatchItem{encoded}, store, *goapi, querier, gasMeter, deserCost)
+	require.NoError(t, results[0].Err)
+	_, gasUsed, err := vm.ExecuteEncodedEnv(checksum, envBin, blockEnv.Height(), encoded.Info, encoded.Msg, store, *goapi, querier, gasMeter, encoded.GasLimit, deserCost)
+	require.NoError(t, err)
+	assert.Equal(t, gasUsed, results[0].GasUsed)
+	assert.Greater(t, gasUsed, lowGas)
+}
+
+func TestExecuteBatchDiscardsFailedItems(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, types.Coins{types.NewCoin(250, "ATOM")})
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := vm.Instantiate(checksum, api.MockEnv(), api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+
+	// the storage loop writes test.key until it runs out of gas
+	items := []BatchItem{
+		{Env: api.MockEnv(), Info: api.MockInfo("fred", nil), Msg: []byte(`{"storage_loop":{}}`), GasLimit: 10_000_000_000},
+		{Env: api.MockEnv(), Info: api.MockInfo("fred", nil), Msg: []byte(`{"release":{}}`), GasLimit: TESTING_GAS_LIMIT},
+	}
+	results := vm.ExecuteBatch(checksum, items, store, *goapi, querier, gasMeter, deserCost)
+	require.Len(t, results, len(items))
+	var outOfGas types.OutOfGasError
+	require.ErrorAs(t, results[0].Err, &outOfGas)
+	assert.Nil(t, store.Get([]byte("test.key")))
+	require.NoError(t, results[1].Err)
+}
+
+func TestCallOutputAccess(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, QUEUE_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	lookup, _ := instantiateQueue(t, vm, checksum)
+
+	// the set is recorded no matter how the store is wrapped
+	gasMeter := NewGasMeter(TESTING_GAS_LIMIT)
+	store := NewOverlayStore(NewGasKVStore(AdaptKVStoreWithErrors(AdaptKVStore(lookup)), gasMeter, DefaultKVGasConfig()))
+	out := CallOutput{RecordAccess: true}
+	_, _, err := vm.Query(checksum, api.MockEnv(), []byte(`{"sum":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+	require.NoError(t, err)
+	require.NotNil(t, out.Access)
+	require.Len(t, out.Access.Ranges, 1)
+	sum := out.Access
+
+	items := enqueueItems(2)
+	items[1].Output = &CallOutput{RecordAccess: true}
+	results := vm.ExecuteBatch(checksum, items, store, *goapi, querier, gasMeter, deserCost)
+	require.NoError(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	require.NotNil(t, items[1].Output.Access)
+	require.Len(t, items[1].Output.Access.Writes, 1)
+	assert.True(t, items[1].Output.Access.ConflictsWith(sum))
+
+	// a reused CallOutput is reset
+	out.RecordAccess = false
+	_, _, err = vm.Query(checksum, api.MockEnv(), []byte(`{"sum":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+	require.NoError(t, err)
+	assert.Nil(t, out.Access)
+}
+
+func TestCallOutputCommitment(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, types.Coins{types.NewCoin(250, "ATOM")})
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+
+	instantiate := func() (*api.Lookup, []byte) {
+		gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+		store := api.NewLookup(gasMeter)
+		out := CallOutput{CommitWrites: true}
+		_, _, err := vm.Instantiate(checksum, api.MockEnv(), api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+		require.NoError(t, err)
+		assert.Nil(t, out.Access)
+		require.Len(t, out.Commitment, 32)
+		return store, out.Commitment
+	}
+	store, first := instantiate()
+	_, second := instantiate()
+	assert.Equal(t, first, second)
+
+	// the contract rejects the sender, so the call fails and has no commitment
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store.SetGasMeter(gasMeter)
+	out := CallOutput{CommitWrites: true}
+	_, _, err := vm.Execute(checksum, api.MockEnv(), api.MockInfo("bob", nil), []byte(`{"release":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+	require.Error(t, err)
+	assert.Nil(t, out.Commitment)
+
+	_, _, err = vm.Execute(checksum, api.MockEnv(), api.MockInfo("fred", nil), []byte(`{"release":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+	require.NoError(t, err)
+	assert.Len(t, out.Commitment, 32)
+	assert.NotEqual(t, first, out.Commitment)
+}
+
+func TestCallOutputResult(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, QUEUE_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	store, gasMeter := instantiateQueue(t, vm, checksum)
+
+	out := CallOutput{KeepResult: true}
+	data, _, err := vm.Query(checksum, api.MockEnv(), []byte(`{"sum":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ok":"`+base64.StdEncoding.EncodeToString(data)+`"}`, string(out.Result))
+
+	// a contract error is part of the result
+	_, _, err = vm.Query(checksum, api.MockEnv(), []byte(`{"unknown":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost, &out)
+	require.Error(t, err)
+	assert.Contains(t, string(out.Result), `{"error":`)
+}
+
+const benchmarkBatchSize = 100
+
+// BenchmarkExecuteBatch compares a batch of benchmarkBatchSize executions
+// against the same number of single Execute calls.
+func BenchmarkExecuteBatch(b *testing.B) {
+	vm := withVM(b)
+	checksum := createTestContract(b, vm, QUEUE_TEST_CONTRACT)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	items := enqueueItems(benchmarkBatchSize)
+
+	b.Run("individual", func(b *testing.B) {
+		store, gasMeter := instantiateQueue(b, vm, checksum)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for _, item := range items {
+				_, _, err := vm.Execute(checksum, item.Env, item.Info, item.Msg, store, *goapi, querier, gasMeter, item.GasLimit, deserCost)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		store, gasMeter := instantiateQueue(b, vm, checksum)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			results := vm.ExecuteBatch(checksum, items, store, *goapi, querier, gasMeter, deserCost)
+			for _, res := range results {
+				require.NoError(b, res.Err)
+			}
+		}
+	})
+}
+
+func TestExecuteEncodedEnv(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, CYBERPUNK_TEST_CONTRACT)
+
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+
+	block := types.BlockInfo{
+		Height:  444,
+		Time:    1955939743_123456789,
+		ChainID: "nice-chain",
+	}
+	blockEnv, err := types.NewBlockEnv(block)
+	require.NoError(t, err)
+	contract := types.ContractInfo{Address: "wasm10dyr9899g6t0pelew4nvf4j5c3jcgv0r5d3a5l"}
+
+	envBin, err := blockEnv.Encode(nil, contract)
+	require.NoError(t, err)
+	info := api.MockInfo("creator", nil)
+	_, _, err = vm.InstantiateEncodedEnv(checksum, envBin, blockEnv.Height(), info, []byte(`{}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+
+	for _, index := range []uint32{0, 18} {
+		envBin, err = blockEnv.Encode(&types.TransactionInfo{Index: index}, contract)
+		require.NoError(t, err)
+		res, _, err := vm.ExecuteEncodedEnv(checksum, envBin, blockEnv.Height(), info, []byte(`{"mirror_env": {}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+		require.NoError(t, err)
+		expected, err := json.Marshal(types.Env{Block: block, Transaction: &types.TransactionInfo{Index: index}, Contract: contract})
+		require.NoError(t, err)
+		require.Equal(t, expected, res.Data)
+	}
+}
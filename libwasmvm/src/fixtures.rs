@@ -0,0 +1,558 @@
+#![cfg(test)]
+
+//! Canonical JSON of the cosmwasm-std types that are mirrored in the Go `types` package.
+//! The Go tests decode the fixtures in `types/testdata/fixtures` and compare their own encoding.
+//! Run `WASMVM_UPDATE_FIXTURES=1 cargo test fixtures` to write the fixtures after upgrading cosmwasm-std.
+//! Every fixture is written here, the Go tests refuse fixtures missing in this file.
+
+use std::fs;
+use std::path::PathBuf;
+
+use serde::de::DeserializeOwned;
+use serde::Serialize;
+
+use cosmwasm_std::{
+    coin, coins, Addr, AllBalanceResponse, AllDelegationsResponse, AllValidatorsResponse,
+    BalanceResponse, BankMsg, BankQuery, Binary, BlockInfo, BondedDenomResponse, ChannelResponse,
+    ContractInfo, ContractInfoResponse, ContractResult, CosmosMsg, DelegationResponse,
+    DistributionMsg, Empty, Env, Event, GovMsg, Ibc3ChannelOpenResponse, IbcBasicResponse,
+    IbcChannelCloseMsg, IbcChannelConnectMsg, IbcChannelOpenMsg, IbcMsg, IbcPacketAckMsg,
+    IbcPacketReceiveMsg, IbcPacketTimeoutMsg, IbcQuery, IbcReceiveResponse, IbcTimeout,
+    IbcTimeoutBlock, ListChannelsResponse, MessageInfo, QueryRequest, Reply, ReplyOn, Response,
+    StakingMsg, StakingQuery, SubMsg, SubMsgResponse, SubMsgResult, SystemError, SystemResult,
+    Timestamp, TransactionInfo, ValidatorResponse, VoteOption, WasmMsg, WasmQuery,
+};
+
+const FIXTURE_DIR: &str = "../types/testdata/fixtures";
+const UPDATE_ENV: &str = "WASMVM_UPDATE_FIXTURES";
+
+/// Writes the fixture if requested, otherwise checks that it is up to date
+fn check_fixture<T: Serialize>(name: &str, value: &T) {
+    let path: PathBuf = [FIXTURE_DIR, &format!("{}.json", name)].iter().collect();
+    let json = serde_json::to_string(value).unwrap() + "\n";
+    if std::env::var_os(UPDATE_ENV).is_some() {
+        fs::write(&path, json).unwrap();
+        return;
+    }
+    let existing = fs::read_to_string(&path).unwrap_or_else(|e| {
+        panic!(
+            "cannot read {}: {}, run with {} set",
+            path.display(),
+            e,
+            UPDATE_ENV
+        )
+    });
+    assert_eq!(
+        existing, json,
+        "fixture {} is outdated, run with {} set",
+        name, UPDATE_ENV
+    );
+}
+
+/// Like check_fixture for types that cannot be built outside of cosmwasm-std, like the non-exhaustive
+/// query responses. The JSON is parsed into T and serialized again, so the fixture is still the
+/// encoding of cosmwasm-std and fields it does not know are dropped.
+fn check_parsed_fixture<T: Serialize + DeserializeOwned>(name: &str, json: &str) {
+    let value: T = serde_json::from_str(json)
+        .unwrap_or_else(|e| panic!("fixture {} does not parse: {}", name, e));
+    check_fixture(name, &value);
+}
+
+/// A custom message or query of a chain, which cosmwasm-std passes through
+#[derive(Serialize)]
+#[serde(rename_all = "snake_case")]
+enum TestMsg {
+    Debug(String),
+}
+
+#[derive(Serialize)]
+#[serde(rename_all = "snake_case")]
+enum TestQuery {
+    Ping {},
+}
+
+fn block() -> BlockInfo {
+    BlockInfo {
+        height: 12345,
+        time: Timestamp::from_nanos(1571797419879305533),
+        chain_id: "cosmos-testnet-14002".to_string(),
+    }
+}
+
+#[test]
+fn fixtures_env() {
+    check_fixture(
+        "env",
+        &Env {
+            block: block(),
+            transaction: Some(TransactionInfo { index: 3 }),
+            contract: ContractInfo {
+                address: Addr::unchecked("contract1"),
+            },
+        },
+    );
+    check_fixture(
+        "env_no_transaction",
+        &Env {
+            block: block(),
+            transaction: None,
+            contract: ContractInfo {
+                address: Addr::unchecked("contract1"),
+            },
+        },
+    );
+}
+
+#[test]
+fn fixtures_message_info() {
+    let mut funds = coins(1000, "ucosm");
+    funds.extend(coins(5, "uatom"));
+    check_fixture(
+        "message_info",
+        &MessageInfo {
+            sender: Addr::unchecked("creator"),
+            funds,
+        },
+    );
+    check_fixture(
+        "message_info_no_funds",
+        &MessageInfo {
+            sender: Addr::unchecked("creator"),
+            funds: vec![],
+        },
+    );
+}
+
+#[test]
+fn fixtures_reply() {
+    check_fixture(
+        "reply_ok_no_events",
+        &Reply {
+            id: 7,
+            result: SubMsgResult::Ok(SubMsgResponse {
+                events: vec![],
+                data: Some(Binary::from(vec![1, 2])),
+            }),
+        },
+    );
+    check_fixture(
+        "reply_ok",
+        &Reply {
+            id: 7,
+            result: SubMsgResult::Ok(SubMsgResponse {
+                events: vec![
+                    Event::new("instantiate").add_attribute("_contract_address", "contract2")
+                ],
+                data: None,
+            }),
+        },
+    );
+    check_fixture(
+        "reply_err",
+        &Reply {
+            id: 7,
+            result: SubMsgResult::Err("out of funds".to_string()),
+        },
+    );
+}
+
+#[test]
+fn fixtures_messages() {
+    check_fixture(
+        "cosmos_msg_bank_send",
+        &CosmosMsg::<Empty>::Bank(BankMsg::Send {
+            to_address: "friend".to_string(),
+            amount: coins(1234, "ucosm"),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_bank_burn_empty",
+        &CosmosMsg::<Empty>::Bank(BankMsg::Burn { amount: vec![] }),
+    );
+    check_fixture(
+        "sub_msg",
+        &SubMsg::<Empty> {
+            id: 12,
+            msg: BankMsg::Burn {
+                amount: coins(1, "ucosm"),
+            }
+            .into(),
+            gas_limit: Some(500000),
+            reply_on: ReplyOn::Success,
+        },
+    );
+    check_fixture(
+        "cosmos_msg_custom",
+        &CosmosMsg::Custom(TestMsg::Debug("hi".to_string())),
+    );
+    check_fixture(
+        "cosmos_msg_distribution_withdraw",
+        &CosmosMsg::<Empty>::Distribution(DistributionMsg::WithdrawDelegatorReward {
+            validator: "val1".to_string(),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_gov_vote",
+        &CosmosMsg::<Empty>::Gov(GovMsg::Vote {
+            proposal_id: 4,
+            vote: VoteOption::NoWithVeto,
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_ibc_send_packet",
+        &CosmosMsg::<Empty>::Ibc(IbcMsg::SendPacket {
+            channel_id: "channel-3".to_string(),
+            data: Binary::from(vec![1, 2, 3]),
+            timeout: IbcTimeout::with_timestamp(Timestamp::from_nanos(1600000000000000000)),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_ibc_transfer",
+        &CosmosMsg::<Empty>::Ibc(IbcMsg::Transfer {
+            channel_id: "channel-3".to_string(),
+            to_address: "remote".to_string(),
+            amount: coin(5, "ucosm"),
+            timeout: IbcTimeout::with_block(IbcTimeoutBlock {
+                revision: 1,
+                height: 12345,
+            }),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_staking_redelegate",
+        &CosmosMsg::<Empty>::Staking(StakingMsg::Redelegate {
+            src_validator: "val1".to_string(),
+            dst_validator: "val2".to_string(),
+            amount: coin(100, "stake"),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_stargate",
+        &CosmosMsg::<Empty>::Stargate {
+            type_url: "/cosmos.bank.v1beta1.MsgSend".to_string(),
+            value: Binary::from(b"\n\x04from".to_vec()),
+        },
+    );
+    check_fixture(
+        "cosmos_msg_wasm_execute",
+        &CosmosMsg::<Empty>::Wasm(WasmMsg::Execute {
+            contract_addr: "contract1".to_string(),
+            msg: Binary::from(br#"{"release":{}}"#.to_vec()),
+            funds: vec![],
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_wasm_instantiate",
+        &CosmosMsg::<Empty>::Wasm(WasmMsg::Instantiate {
+            admin: None,
+            code_id: 7,
+            msg: Binary::from(b"{}".to_vec()),
+            funds: coins(1, "ucosm"),
+            label: "child".to_string(),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_wasm_migrate",
+        &CosmosMsg::<Empty>::Wasm(WasmMsg::Migrate {
+            contract_addr: "contract1".to_string(),
+            new_code_id: 8,
+            msg: Binary::from(b"{}".to_vec()),
+        }),
+    );
+    check_fixture(
+        "cosmos_msg_wasm_update_admin",
+        &CosmosMsg::<Empty>::Wasm(WasmMsg::UpdateAdmin {
+            contract_addr: "contract1".to_string(),
+            admin: "admin2".to_string(),
+        }),
+    );
+    check_fixture(
+        "sub_msg_reply_never",
+        &SubMsg::<Empty>::new(WasmMsg::ClearAdmin {
+            contract_addr: "contract1".to_string(),
+        }),
+    );
+    check_fixture(
+        "contract_result_ok",
+        &ContractResult::Ok(
+            Response::<Empty>::new()
+                .add_message(BankMsg::Send {
+                    to_address: "bob".to_string(),
+                    amount: coins(250, "ATOM"),
+                })
+                .add_attribute("action", "release")
+                .add_event(Event::new("hackatom"))
+                .set_data(Binary::from(vec![0xf0, 0x0b, 0xaa])),
+        ),
+    );
+    check_fixture(
+        "contract_result_ok_empty",
+        &ContractResult::Ok(Response::<Empty>::new()),
+    );
+    check_fixture(
+        "contract_result_err",
+        &ContractResult::<Binary>::Err("Unauthorized".to_string()),
+    );
+}
+
+#[test]
+fn fixtures_queries() {
+    check_fixture(
+        "query_request_bank_balance",
+        &QueryRequest::<Empty>::Bank(BankQuery::Balance {
+            address: "addr1".to_string(),
+            denom: "ucosm".to_string(),
+        }),
+    );
+    check_fixture(
+        "query_request_bank_supply",
+        &QueryRequest::<Empty>::Bank(BankQuery::Supply {
+            denom: "ucosm".to_string(),
+        }),
+    );
+    check_fixture(
+        "query_request_custom",
+        &QueryRequest::Custom(TestQuery::Ping {}),
+    );
+    check_fixture(
+        "query_request_ibc_channel",
+        &QueryRequest::<Empty>::Ibc(IbcQuery::Channel {
+            channel_id: "channel-1".to_string(),
+            port_id: Some("transfer".to_string()),
+        }),
+    );
+    check_fixture(
+        "query_request_ibc_list_channels",
+        &QueryRequest::<Empty>::Ibc(IbcQuery::ListChannels { port_id: None }),
+    );
+    check_fixture(
+        "query_request_staking_all_delegations",
+        &QueryRequest::<Empty>::Staking(StakingQuery::AllDelegations {
+            delegator: "addr1".to_string(),
+        }),
+    );
+    check_fixture(
+        "query_request_staking_bonded_denom",
+        &QueryRequest::<Empty>::Staking(StakingQuery::BondedDenom {}),
+    );
+    check_fixture(
+        "query_request_staking_validator",
+        &QueryRequest::<Empty>::Staking(StakingQuery::Validator {
+            address: "val1".to_string(),
+        }),
+    );
+    check_fixture(
+        "query_request_stargate",
+        &QueryRequest::<Empty>::Stargate {
+            path: "/cosmos.bank.v1beta1.Query/Balance".to_string(),
+            data: Binary::from(b"\n\x04from".to_vec()),
+        },
+    );
+    check_fixture(
+        "query_request_wasm_contract_info",
+        &QueryRequest::<Empty>::Wasm(WasmQuery::ContractInfo {
+            contract_addr: "contract1".to_string(),
+        }),
+    );
+    check_fixture(
+        "query_request_wasm_raw",
+        &QueryRequest::<Empty>::Wasm(WasmQuery::Raw {
+            contract_addr: "contract1".to_string(),
+            key: Binary::from(b"config".to_vec()),
+        }),
+    );
+    check_fixture(
+        "query_request_wasm_smart",
+        &QueryRequest::<Empty>::Wasm(WasmQuery::Smart {
+            contract_addr: "contract1".to_string(),
+            msg: Binary::from(br#"{"verifier":{}}"#.to_vec()),
+        }),
+    );
+    check_fixture(
+        "querier_result_ok",
+        &SystemResult::Ok(ContractResult::Ok(Binary::from(
+            br#"{"verifier":"fred"}"#.to_vec(),
+        ))),
+    );
+    check_fixture(
+        "querier_result_system_error",
+        &SystemResult::<ContractResult<Binary>>::Err(SystemError::NoSuchContract {
+            addr: "contract9".to_string(),
+        }),
+    );
+    check_fixture(
+        "querier_result_contract_error",
+        &SystemResult::Ok(ContractResult::<Binary>::Err("not found".to_string())),
+    );
+    check_fixture(
+        "system_error_invalid_request",
+        &SystemError::InvalidRequest {
+            error: "EOF while parsing".to_string(),
+            request: Binary::from(br#"{"bank"#.to_vec()),
+        },
+    );
+    check_fixture(
+        "system_error_invalid_response",
+        &SystemError::InvalidResponse {
+            error: "invalid type".to_string(),
+            response: Binary::from(b"[]".to_vec()),
+        },
+    );
+    check_fixture("system_error_unknown", &SystemError::Unknown {});
+    check_fixture(
+        "system_error_unsupported_request",
+        &SystemError::UnsupportedRequest {
+            kind: "custom".to_string(),
+        },
+    );
+}
+
+#[test]
+fn fixtures_query_responses() {
+    check_parsed_fixture::<AllBalanceResponse>(
+        "all_balances_response",
+        r#"{"amount":[{"denom":"a","amount":"1"},{"denom":"b","amount":"2"}]}"#,
+    );
+    check_parsed_fixture::<AllBalanceResponse>("all_balances_response_empty", r#"{"amount":[]}"#);
+    check_parsed_fixture::<BalanceResponse>(
+        "balance_response",
+        r#"{"amount":{"denom":"ucosm","amount":"0"}}"#,
+    );
+    check_parsed_fixture::<AllDelegationsResponse>(
+        "all_delegations_response",
+        r#"{"delegations":[{"delegator":"addr1","validator":"val1","amount":{"denom":"stake","amount":"100"}}]}"#,
+    );
+    check_parsed_fixture::<AllDelegationsResponse>(
+        "all_delegations_response_empty",
+        r#"{"delegations":[]}"#,
+    );
+    check_parsed_fixture::<DelegationResponse>(
+        "delegation_response",
+        r#"{"delegation":{"delegator":"addr1","validator":"val1","amount":{"denom":"stake","amount":"100"},"can_redelegate":{"denom":"stake","amount":"0"},"accumulated_rewards":[]}}"#,
+    );
+    check_parsed_fixture::<AllValidatorsResponse>(
+        "all_validators_response",
+        r#"{"validators":[{"address":"val1","commission":"0.05","max_commission":"0.1","max_change_rate":"0.01"}]}"#,
+    );
+    check_parsed_fixture::<AllValidatorsResponse>(
+        "all_validators_response_empty",
+        r#"{"validators":[]}"#,
+    );
+    check_parsed_fixture::<ValidatorResponse>("validator_response_none", r#"{"validator":null}"#);
+    check_parsed_fixture::<BondedDenomResponse>("bonded_denom_response", r#"{"denom":"stake"}"#);
+    check_parsed_fixture::<ContractInfoResponse>(
+        "contract_info_response",
+        r#"{"code_id":7,"creator":"creator","admin":null,"pinned":false,"ibc_port":null}"#,
+    );
+    check_parsed_fixture::<ChannelResponse>(
+        "channel_response",
+        &format!(r#"{{"channel":{}}}"#, channel("ORDER_ORDERED")),
+    );
+    check_parsed_fixture::<ListChannelsResponse>(
+        "list_channels_response_empty",
+        r#"{"channels":[]}"#,
+    );
+}
+
+/// The JSON of an IbcChannel, which cannot be built outside of cosmwasm-std
+fn channel(order: &str) -> String {
+    format!(
+        r#"{{"endpoint":{{"port_id":"wasm.contract1","channel_id":"channel-1"}},"counterparty_endpoint":{{"port_id":"transfer","channel_id":"channel-9"}},"order":"{}","version":"ibc-reflect-v1","connection_id":"connection-2"}}"#,
+        order
+    )
+}
+
+/// The JSON of an IbcPacket with the given timeout
+fn packet(timeout: &str) -> String {
+    format!(
+        r#"{{"data":"eyJ3aG9fYW1faSI6e319","src":{{"port_id":"transfer","channel_id":"channel-9"}},"dest":{{"port_id":"wasm.contract1","channel_id":"channel-1"}},"sequence":27,"timeout":{}}}"#,
+        timeout
+    )
+}
+
+#[test]
+fn fixtures_ibc() {
+    let ordered = channel("ORDER_ORDERED");
+    check_parsed_fixture::<IbcChannelOpenMsg>(
+        "ibc_channel_open_msg_init",
+        &format!(r#"{{"open_init":{{"channel":{}}}}}"#, ordered),
+    );
+    check_parsed_fixture::<IbcChannelOpenMsg>(
+        "ibc_channel_open_msg_try",
+        &format!(
+            r#"{{"open_try":{{"channel":{},"counterparty_version":"ibc-reflect-v1"}}}}"#,
+            channel("ORDER_UNORDERED")
+        ),
+    );
+    check_parsed_fixture::<IbcChannelConnectMsg>(
+        "ibc_channel_connect_msg_ack",
+        &format!(
+            r#"{{"open_ack":{{"channel":{},"counterparty_version":"ibc-reflect-v1"}}}}"#,
+            ordered
+        ),
+    );
+    check_parsed_fixture::<IbcChannelConnectMsg>(
+        "ibc_channel_connect_msg_confirm",
+        &format!(r#"{{"open_confirm":{{"channel":{}}}}}"#, ordered),
+    );
+    check_parsed_fixture::<IbcChannelCloseMsg>(
+        "ibc_channel_close_msg_init",
+        &format!(r#"{{"close_init":{{"channel":{}}}}}"#, ordered),
+    );
+    check_parsed_fixture::<IbcChannelCloseMsg>(
+        "ibc_channel_close_msg_confirm",
+        &format!(r#"{{"close_confirm":{{"channel":{}}}}}"#, ordered),
+    );
+
+    let block_timeout = r#"{"block":{"revision":1,"height":12345},"timestamp":null}"#;
+    let timestamp_timeout = r#"{"block":null,"timestamp":"1600000000000000000"}"#;
+    check_parsed_fixture::<IbcPacketReceiveMsg>(
+        "ibc_packet_receive_msg",
+        &format!(
+            r#"{{"packet":{},"relayer":"relayer1"}}"#,
+            packet(block_timeout)
+        ),
+    );
+    check_parsed_fixture::<IbcPacketAckMsg>(
+        "ibc_packet_ack_msg",
+        &format!(
+            r#"{{"acknowledgement":{{"data":"eyJyZXN1bHQiOiJBUT09In0="}},"original_packet":{},"relayer":"relayer1"}}"#,
+            packet(timestamp_timeout)
+        ),
+    );
+    check_parsed_fixture::<IbcPacketTimeoutMsg>(
+        "ibc_packet_timeout_msg",
+        &format!(
+            r#"{{"packet":{},"relayer":"relayer1"}}"#,
+            packet(timestamp_timeout)
+        ),
+    );
+
+    check_fixture(
+        "ibc_channel_open_result_none",
+        &ContractResult::<Option<Ibc3ChannelOpenResponse>>::Ok(None),
+    );
+    check_parsed_fixture::<ContractResult<Option<Ibc3ChannelOpenResponse>>>(
+        "ibc_channel_open_result_version",
+        r#"{"ok":{"version":"ibc-reflect-v1"}}"#,
+    );
+    check_fixture(
+        "ibc_basic_result",
+        &ContractResult::Ok(
+            IbcBasicResponse::<Empty>::new()
+                .add_attribute("action", "connect")
+                .add_event(Event::new("ibc").add_attribute("channel", "connect")),
+        ),
+    );
+    check_fixture(
+        "ibc_basic_result_err",
+        &ContractResult::<IbcBasicResponse>::Err("channel closed".to_string()),
+    );
+    check_fixture(
+        "ibc_receive_result",
+        &ContractResult::Ok(
+            IbcReceiveResponse::<Empty>::new()
+                .set_ack(Binary::from(br#"{"result":"AQ=="}"#.to_vec())),
+        ),
+    );
+}
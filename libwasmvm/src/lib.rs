@@ -7,6 +7,7 @@ mod cache;
 mod calls;
 mod db;
 mod error;
+mod fixtures;
 mod gas_meter;
 mod iterator;
 mod memory;
@@ -0,0 +1,44 @@
+package cosmwasm
+
+import (
+	"github.com/line/wasmvm/internal/api"
+)
+
+// CallOutput requests optional outputs of a contract call and receives them when the call returns.
+// Pass it as the last argument of a call, e.g.
+//
+//	out := CallOutput{RecordAccess: true, CommitWrites: true}
+//	res, gasUsed, err := vm.Execute(checksum, env, info, msg, store, goapi, querier, gasMeter, gasLimit, deserCost, &out)
+//	// out.Access holds the read and write set of the call, out.Commitment its write commitment
+//
+// The outputs are recorded where the VM calls into the store, so they do not depend on how the
+// store is wrapped. KeepResult returns the raw result bytes of the VM in Result, e.g. to compare
+// them byte for byte like CheckDeterminism does.
+//
+// The write commitment is a SHA-256 hash over the writes and deletes of the call and its response
+// bytes, in the order they happened. Validators can log it and compare it across nodes to detect
+// divergence before it shows up in the app hash. It is only set if the call succeeds, including
+// the contract result, so the writes of failed calls never end up in a commitment.
+type CallOutput = api.CallOutput
+
+// callOutput returns the CallOutput passed to a call, if any, with the outputs of a previous call
+// cleared. At most one may be passed.
+func callOutput(outputs []*CallOutput) *CallOutput {
+	switch len(outputs) {
+	case 0:
+		return nil
+	case 1:
+		outputs[0].Reset()
+		return outputs[0]
+	default:
+		panic("at most one CallOutput can be passed to a contract call")
+	}
+}
+
+// failed drops the outputs of out that only exist for successful calls and returns err
+func failed(out *CallOutput, err error) error {
+	if out != nil {
+		out.Commitment = nil
+	}
+	return err
+}
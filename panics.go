@@ -0,0 +1,45 @@
+package cosmwasm
+
+import (
+	"github.com/line/wasmvm/internal/api"
+)
+
+// PanicCategory determines the error a panic in a host callback (KVStore, GoAPI, Querier) is turned into
+type PanicCategory = api.PanicCategory
+
+const (
+	PanicCategoryUnexpected = api.PanicCategoryUnexpected
+	PanicCategoryOutOfGas   = api.PanicCategoryOutOfGas
+	PanicCategoryPanic      = api.PanicCategoryPanic
+	PanicCategoryUser       = api.PanicCategoryUser
+)
+
+// PanicClassifier assigns a category to panics in host callbacks
+type PanicClassifier = api.PanicClassifier
+
+// UnexpectedPanicHook receives unclassified panics in host callbacks together with their stack trace
+type UnexpectedPanicHook = api.UnexpectedPanicHook
+
+// NewTypePanicClassifier creates a classifier matching panics with values of the same type as example
+// or a pointer to it, e.g. NewTypePanicClassifier(storetypes.ErrorOutOfGas{}, PanicCategoryOutOfGas)
+func NewTypePanicClassifier(example interface{}, category PanicCategory) PanicClassifier {
+	return api.NewTypePanicClassifier(example, category)
+}
+
+// RegisterPanicClassifier adds a classifier for panics in host callbacks. This is global for all VMs.
+// Classifiers are checked in registration order and the first match wins. Panics with a value whose type
+// is named `ErrorOutOfGas` are classified as out of gas if no registered classifier matches.
+func RegisterPanicClassifier(classifier PanicClassifier) {
+	api.RegisterPanicClassifier(classifier)
+}
+
+// ResetPanicClassifiers removes all classifiers added with RegisterPanicClassifier
+func ResetPanicClassifiers() {
+	api.ResetPanicClassifiers()
+}
+
+// SetUnexpectedPanicHook sets the function receiving unexpected panics in host callbacks. This is global
+// for all VMs. The default logs the panic and its stack trace. Pass nil to ignore unexpected panics.
+func SetUnexpectedPanicHook(hook UnexpectedPanicHook) {
+	api.SetUnexpectedPanicHook(hook)
+}
@@ -0,0 +1,43 @@
+package cosmwasm
+
+import (
+	"github.com/line/wasmvm/internal/api"
+)
+
+// PayloadDirection tells on which side of the FFI boundary a payload was created
+type PayloadDirection = api.PayloadDirection
+
+const (
+	PayloadToVM   = api.PayloadToVM
+	PayloadFromVM = api.PayloadFromVM
+)
+
+// DefaultPayloadMaxSize is the number of bytes kept of each payload if PayloadDumpOptions.MaxSize is 0
+const DefaultPayloadMaxSize = api.DefaultPayloadMaxSize
+
+// Payload is a byte slice that crossed the FFI boundary, e.g. the message of a call or the key of a storage read
+type Payload = api.Payload
+
+// RedactionRule redacts the data of payloads with a name that are larger than a minimum size
+type RedactionRule = api.RedactionRule
+
+// PayloadDumpOptions configure the payload dump
+type PayloadDumpOptions = api.PayloadDumpOptions
+
+// PayloadRecorder keeps all payloads it receives, grouped by call ID
+type PayloadRecorder = api.PayloadRecorder
+
+func NewPayloadRecorder() *PayloadRecorder {
+	return api.NewPayloadRecorder()
+}
+
+// SetPayloadDump passes every payload crossing the FFI boundary to options.Sink. This is global for all VMs
+// and slows down every call, so it is meant for diagnosing failing calls only. Pass nil to disable the dump.
+func SetPayloadDump(options *PayloadDumpOptions) {
+	api.SetPayloadDump(options)
+}
+
+// LogPayload writes the payload to the standard logger. It can be used as PayloadDumpOptions.Sink.
+func LogPayload(p Payload) {
+	api.LogPayload(p)
+}
@@ -0,0 +1,358 @@
+package cosmwasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+// Entry points of a CallRecord
+const (
+	EntryInstantiate       = "instantiate"
+	EntryExecute           = "execute"
+	EntryMigrate           = "migrate"
+	EntrySudo              = "sudo"
+	EntryReply             = "reply"
+	EntryQuery             = "query"
+	EntryIBCChannelOpen    = "ibc_channel_open"
+	EntryIBCChannelConnect = "ibc_channel_connect"
+	EntryIBCChannelClose   = "ibc_channel_close"
+	EntryIBCPacketReceive  = "ibc_packet_receive"
+	EntryIBCPacketAck      = "ibc_packet_ack"
+	EntryIBCPacketTimeout  = "ibc_packet_timeout"
+)
+
+// Operations of a RecordedEvent
+const (
+	RecordedGet          = "get"
+	RecordedSet          = "set"
+	RecordedDelete       = "delete"
+	RecordedScan         = "scan"
+	RecordedNext         = "next"
+	RecordedQuery        = "query"
+	RecordedHumanize     = "humanize"
+	RecordedCanonicalize = "canonicalize"
+)
+
+// RecordedEvent is one interaction of a contract with its host. Only the fields relevant for Op are set.
+type RecordedEvent struct {
+	Op string `json:"op"`
+	// Key and Value of get, set, delete and next
+	Key   []byte `json:"key,omitempty"`
+	Value []byte `json:"value,omitempty"`
+	// Missing is true for a get of a key that does not exist
+	Missing bool `json:"missing,omitempty"`
+	// Start, End and Descending are the bounds and order of a scan
+	Start      []byte `json:"start,omitempty"`
+	End        []byte `json:"end,omitempty"`
+	Descending bool   `json:"descending,omitempty"`
+	// Iterator numbers the iterators of a call in the order of their creation. It is set for scan and next.
+	Iterator int `json:"iterator,omitempty"`
+	// Request, Response and SystemError of a query
+	Request     json.RawMessage    `json:"request,omitempty"`
+	Response    []byte             `json:"response,omitempty"`
+	SystemError *types.SystemError `json:"system_error,omitempty"`
+	// Human and Canonical are the addresses of humanize and canonicalize
+	Human     string `json:"human,omitempty"`
+	Canonical []byte `json:"canonical,omitempty"`
+	// Gas is the gas consumed on the gas meter by storage operations, on the querier by queries
+	// and the cost returned by the address API
+	Gas uint64 `json:"gas,omitempty"`
+	// Err is the error message returned by the store, a query or the address API. For next it is
+	// the error of the iterator after moving on.
+	Err string `json:"error,omitempty"`
+	// Panic is set if a storage operation panicked, e.g. because the gas meter ran out of gas.
+	// Gas is then the gas consumed until the panic.
+	Panic *RecordedPanic `json:"panic,omitempty"`
+}
+
+// RecordedPanic is a panic of a storage operation. It is raised again on replay with the category
+// it had when it was recorded, so the call fails in the same way.
+type RecordedPanic struct {
+	Category   PanicCategory `json:"category"`
+	Message    string        `json:"message"`
+	Descriptor string        `json:"descriptor,omitempty"`
+}
+
+// CallRecord is everything a contract call saw. Together with the contract code it is sufficient
+// to replay the call without the original store, querier and API, see VM.Replay.
+type CallRecord struct {
+	Entry string `json:"entry"`
+	// Checksum identifies the contract code, which is not part of the record
+	Checksum Checksum `json:"checksum"`
+	// Env, Info and Msg are the encoded inputs passed to the VM. Info is only set for instantiate and execute.
+	Env       []byte          `json:"env"`
+	Info      []byte          `json:"info,omitempty"`
+	Msg       []byte          `json:"msg"`
+	GasLimit  uint64          `json:"gas_limit"`
+	GasConfig types.GasConfig `json:"gas_config"`
+	// Events are all host interactions in the order they happened
+	Events []RecordedEvent `json:"events"`
+	// Result is the raw result returned by the VM, GasUsed and Err are the outcome of the call
+	Result  []byte `json:"result,omitempty"`
+	GasUsed uint64 `json:"gas_used"`
+	Err     string `json:"error,omitempty"`
+}
+
+// WriteFile stores the record as JSON
+func (r *CallRecord) WriteFile(path string) error {
+	bz, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bz, 0o644)
+}
+
+// ReadCallRecord loads a record stored with WriteFile
+func ReadCallRecord(path string) (*CallRecord, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record CallRecord
+	if err := json.Unmarshal(bz, &record); err != nil {
+		return nil, fmt.Errorf("cannot decode call record %s: %w", path, err)
+	}
+	return &record, nil
+}
+
+// CallRecorder receives a record of every finished contract call. It is called synchronously
+// at the end of the call.
+type CallRecorder func(record *CallRecord)
+
+// recordFileCounter makes the file names of RecordToDir unique within a process
+var recordFileCounter uint64
+
+// RecordToDir returns a CallRecorder that writes each record into a new file in dir.
+// Errors are logged and otherwise ignored, such that recording never fails a call.
+func RecordToDir(dir string) CallRecorder {
+	return func(record *CallRecord) {
+		n := atomic.AddUint64(&recordFileCounter, 1)
+		name := fmt.Sprintf("%d-%06d-%s.json", time.Now().UnixNano(), n, record.Entry)
+		if err := record.WriteFile(filepath.Join(dir, name)); err != nil {
+			log.Printf("cannot write call record: %v", err)
+		}
+	}
+}
+
+// SetCallRecorder enables recording of all contract calls. Each finished call is passed to recorder,
+// calls rejected before reaching the VM (e.g. because the gas limit is below the base cost) are not
+// recorded. Every item of ExecuteBatch is recorded as an execute call. Pass nil to disable recording.
+//
+// Recording copies all data exchanged with the host, so it is meant for debugging only.
+func (vm *VM) SetCallRecorder(recorder CallRecorder) {
+	vm.recorder = recorder
+}
+
+// callRecording collects the record of one call
+type callRecording struct {
+	vm        *VM
+	record    *CallRecord
+	gasMeter  GasMeter
+	iterators int
+}
+
+// startRecording wraps store, goapi and querier such that they record the host interactions.
+// It returns nil if recording is disabled.
+func (vm *VM) startRecording(entry string, checksum Checksum, env, info, msg []byte, gasLimit uint64, store *KVStore, goapi *GoAPI, querier *Querier, gasMeter GasMeter) *callRecording {
+	if vm.recorder == nil {
+		return nil
+	}
+	rec := &callRecording{
+		vm: vm,
+		record: &CallRecord{
+			Entry:    entry,
+			Checksum: append(Checksum(nil), checksum...),
+			Env:      append([]byte(nil), env...),
+			Info:     copyOrNil(info),
+			Msg:      append([]byte(nil), msg...),
+			GasLimit: gasLimit,
+		},
+		gasMeter: gasMeter,
+	}
+	// recording works on the errors of the store, so they reach the VM unchanged and are recorded
+	*store = AdaptKVStoreWithErrors(&recordingStore{store: AdaptKVStore(*store), rec: rec})
+	*goapi = rec.api(*goapi)
+	*querier = &recordingCallQuerier{querier: *querier, rec: rec}
+	return rec
+}
+
+// finish completes the record and passes it to the recorder
+func (rec *callRecording) finish(config types.GasConfig, data []byte, gasUsed uint64, err error) {
+	if rec == nil {
+		return
+	}
+	rec.record.GasConfig = config
+	rec.record.Result = append([]byte(nil), data...)
+	rec.record.GasUsed = gasUsed
+	rec.record.Err = errString(err)
+	rec.vm.recorder(rec.record)
+}
+
+func (rec *callRecording) add(event RecordedEvent) {
+	rec.record.Events = append(rec.record.Events, event)
+}
+
+// recordPanic must be deferred by storage operations. If the operation panics, e.g. because the gas
+// meter ran out of gas, it records event with the panic and the gas consumed since before and panics again.
+func (rec *callRecording) recordPanic(event RecordedEvent, before uint64) {
+	if r := recover(); r != nil {
+		classified := api.ClassifyPanic(r)
+		event.Gas = rec.gasMeter.GasConsumed() - before
+		event.Panic = &RecordedPanic{Category: classified.Category, Message: classified.Message, Descriptor: classified.Descriptor}
+		rec.add(event)
+		panic(r)
+	}
+}
+
+func copyOrNil(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (rec *callRecording) api(goapi GoAPI) GoAPI {
+	return GoAPI{
+		HumanAddress: func(canon []byte) (string, uint64, error) {
+			human, cost, err := goapi.HumanAddress(canon)
+			rec.add(RecordedEvent{Op: RecordedHumanize, Canonical: copyOrNil(canon), Human: human, Gas: cost, Err: errString(err)})
+			return human, cost, err
+		},
+		CanonicalAddress: func(human string) ([]byte, uint64, error) {
+			canon, cost, err := goapi.CanonicalAddress(human)
+			rec.add(RecordedEvent{Op: RecordedCanonicalize, Human: human, Canonical: copyOrNil(canon), Gas: cost, Err: errString(err)})
+			return canon, cost, err
+		},
+	}
+}
+
+// recordingCallQuerier records all queries and their results
+type recordingCallQuerier struct {
+	querier Querier
+	rec     *callRecording
+}
+
+var _ Querier = (*recordingCallQuerier)(nil)
+
+func (q *recordingCallQuerier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	before := q.querier.GasConsumed()
+	res, err := q.querier.Query(request, gasLimit)
+	reqBin, _ := json.Marshal(request)
+	q.rec.add(RecordedEvent{
+		Op:          RecordedQuery,
+		Request:     reqBin,
+		Response:    copyOrNil(res),
+		SystemError: types.ToSystemError(err),
+		Gas:         q.querier.GasConsumed() - before,
+		Err:         errString(err),
+	})
+	return res, err
+}
+
+func (q *recordingCallQuerier) GasConsumed() uint64 {
+	return q.querier.GasConsumed()
+}
+
+// recordingStore records all storage operations, their errors and the gas they consumed
+type recordingStore struct {
+	store KVStoreWithErrors
+	rec   *callRecording
+}
+
+var _ KVStoreWithErrors = (*recordingStore)(nil)
+
+func (s *recordingStore) Get(key []byte) ([]byte, error) {
+	before := s.rec.gasMeter.GasConsumed()
+	defer s.rec.recordPanic(RecordedEvent{Op: RecordedGet, Key: copyOrNil(key)}, before)
+	value, err := s.store.Get(key)
+	s.rec.add(RecordedEvent{Op: RecordedGet, Key: copyOrNil(key), Value: copyOrNil(value), Missing: value == nil && err == nil, Gas: s.rec.gasMeter.GasConsumed() - before, Err: errString(err)})
+	return value, err
+}
+
+func (s *recordingStore) Set(key, value []byte) error {
+	before := s.rec.gasMeter.GasConsumed()
+	defer s.rec.recordPanic(RecordedEvent{Op: RecordedSet, Key: copyOrNil(key), Value: copyOrNil(value)}, before)
+	err := s.store.Set(key, value)
+	s.rec.add(RecordedEvent{Op: RecordedSet, Key: copyOrNil(key), Value: copyOrNil(value), Gas: s.rec.gasMeter.GasConsumed() - before, Err: errString(err)})
+	return err
+}
+
+func (s *recordingStore) Delete(key []byte) error {
+	before := s.rec.gasMeter.GasConsumed()
+	defer s.rec.recordPanic(RecordedEvent{Op: RecordedDelete, Key: copyOrNil(key)}, before)
+	err := s.store.Delete(key)
+	s.rec.add(RecordedEvent{Op: RecordedDelete, Key: copyOrNil(key), Gas: s.rec.gasMeter.GasConsumed() - before, Err: errString(err)})
+	return err
+}
+
+func (s *recordingStore) Iterator(start, end []byte) (Iterator, error) {
+	return s.scan(start, end, false)
+}
+
+func (s *recordingStore) ReverseIterator(start, end []byte) (Iterator, error) {
+	return s.scan(start, end, true)
+}
+
+func (s *recordingStore) scan(start, end []byte, descending bool) (Iterator, error) {
+	id := s.rec.iterators
+	s.rec.iterators++
+	before := s.rec.gasMeter.GasConsumed()
+	defer s.rec.recordPanic(RecordedEvent{Op: RecordedScan, Start: copyOrNil(start), End: copyOrNil(end), Descending: descending, Iterator: id}, before)
+	var iter Iterator
+	var err error
+	if descending {
+		iter, err = s.store.ReverseIterator(start, end)
+	} else {
+		iter, err = s.store.Iterator(start, end)
+	}
+	s.rec.add(RecordedEvent{Op: RecordedScan, Start: copyOrNil(start), End: copyOrNil(end), Descending: descending, Iterator: id, Gas: s.rec.gasMeter.GasConsumed() - before, Err: errString(err)})
+	if err != nil {
+		return nil, err
+	}
+	return &recordingIterator{Iterator: iter, rec: s.rec, id: id}, nil
+}
+
+// recordingIterator records every entry the contract reads. The gas is measured from the
+// first access to the entry until the iterator moved on.
+type recordingIterator struct {
+	Iterator
+	rec     *callRecording
+	id      int
+	started bool
+	before  uint64
+}
+
+func (it *recordingIterator) start() {
+	if !it.started {
+		it.started = true
+		it.before = it.rec.gasMeter.GasConsumed()
+	}
+}
+
+func (it *recordingIterator) Key() []byte {
+	it.start()
+	return it.Iterator.Key()
+}
+
+func (it *recordingIterator) Value() []byte {
+	it.start()
+	return it.Iterator.Value()
+}
+
+func (it *recordingIterator) Next() {
+	it.start()
+	key := copyOrNil(it.Iterator.Key())
+	value := copyOrNil(it.Iterator.Value())
+	defer it.rec.recordPanic(RecordedEvent{Op: RecordedNext, Iterator: it.id, Key: key, Value: value}, it.before)
+	it.Iterator.Next()
+	it.rec.add(RecordedEvent{Op: RecordedNext, Iterator: it.id, Key: key, Value: value, Gas: it.rec.gasMeter.GasConsumed() - it.before, Err: errString(it.Iterator.Error())})
+	it.started = false
+}
@@ -0,0 +1,231 @@
+package cosmwasm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+// storeOps runs the same storage operations a contract could do and returns everything it observed
+func storeOps(t *testing.T, store KVStore) []string {
+	var seen []string
+	seen = append(seen, string(store.Get([]byte("foo"))))
+	assert.Nil(t, store.Get([]byte("missing")))
+	store.Set([]byte("new"), []byte("value"))
+	store.Delete([]byte("foo"))
+	outer := store.Iterator(nil, nil)
+	inner := store.ReverseIterator([]byte("b"), nil)
+	for ; outer.Valid(); outer.Next() {
+		seen = append(seen, string(outer.Key())+"="+string(outer.Value()))
+		if inner.Valid() {
+			seen = append(seen, "reverse "+string(inner.Key()))
+			inner.Next()
+		}
+	}
+	return seen
+}
+
+func TestRecordAndReplayStore(t *testing.T) {
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	lookup := api.NewLookup(gasMeter)
+	lookup.Set([]byte("foo"), []byte("bar"))
+	lookup.Set([]byte("abc"), []byte("1"))
+	lookup.Set([]byte("xyz"), []byte("2"))
+
+	rec := &callRecording{record: &CallRecord{}, gasMeter: gasMeter}
+	before := gasMeter.GasConsumed()
+	recorded := storeOps(t, AdaptKVStoreWithErrors(&recordingStore{store: AdaptKVStore(lookup), rec: rec}))
+	recordedGas := gasMeter.GasConsumed() - before
+	require.NotEmpty(t, rec.record.Events)
+	assert.Equal(t, RecordedEvent{Op: RecordedGet, Key: []byte("missing"), Missing: true, Gas: api.GetPrice}, rec.record.Events[1])
+
+	p := &player{events: rec.record.Events, gasMeter: NewInfiniteGasMeter()}
+	replayed := storeOps(t, AdaptKVStoreWithErrors(&replayStore{p: p}))
+	require.NoError(t, p.err)
+	assert.Empty(t, p.events)
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, recordedGas, p.gasMeter.GasConsumed())
+
+	// a different operation is reported
+	p = &player{events: rec.record.Events, gasMeter: NewInfiniteGasMeter()}
+	_, err := (&replayStore{p: p}).Get([]byte("other"))
+	require.EqualError(t, err, `replay diverged in get: key 6F74686572 instead of 666F6F`)
+	err = (&replayStore{p: p}).Set([]byte("new"), []byte("value"))
+	require.Equal(t, p.err, err)
+}
+
+func TestRecordAndReplayStoreErrors(t *testing.T) {
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	lookup := api.NewLookup(gasMeter)
+	lookup.Set([]byte("foo"), []byte("bar"))
+	rec := &callRecording{record: &CallRecord{}, gasMeter: gasMeter}
+	// the failing store is wrapped like the stores of a host, its errors still reach the recorder
+	failing := AdaptKVStoreWithErrors(api.NewMockFailureKVStore(lookup))
+	store := &recordingStore{store: AdaptKVStore(NewGasKVStore(failing, NewInfiniteGasMeter(), DefaultKVGasConfig())), rec: rec}
+
+	setErr := store.Set([]byte("foo"), []byte("baz"))
+	require.EqualError(t, setErr, `mock failure - set "foo"`)
+	deleteErr := store.Delete([]byte("foo"))
+	require.EqualError(t, deleteErr, `mock failure - delete "foo"`)
+	value, err := store.Get([]byte("foo"))
+	require.NoError(t, err)
+	require.Len(t, rec.record.Events, 3)
+	assert.Equal(t, setErr.Error(), rec.record.Events[0].Err)
+	assert.Equal(t, deleteErr.Error(), rec.record.Events[1].Err)
+
+	p := &player{events: rec.record.Events, gasMeter: NewInfiniteGasMeter()}
+	replay := &replayStore{p: p}
+	assert.Equal(t, setErr.Error(), errString(replay.Set([]byte("foo"), []byte("baz"))))
+	assert.Equal(t, deleteErr.Error(), errString(replay.Delete([]byte("foo"))))
+	replayedValue, err := replay.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, value, replayedValue)
+	require.NoError(t, p.err)
+	assert.Empty(t, p.events)
+}
+
+func TestRecordAndReplayQuerierAndAPI(t *testing.T) {
+	balance := types.Coins{types.NewCoin(250, "ATOM")}
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, balance)
+	goapi := api.NewMockAPI()
+	rec := &callRecording{record: &CallRecord{}}
+	recQuerier := &recordingCallQuerier{querier: querier, rec: rec}
+	recAPI := rec.api(*goapi)
+
+	request := types.QueryRequest{Bank: &types.BankQuery{AllBalances: &types.AllBalancesQuery{Address: api.MOCK_CONTRACT_ADDR}}}
+	unsupported := types.QueryRequest{Custom: []byte(`{}`)}
+	res, err := recQuerier.Query(request, TESTING_GAS_LIMIT)
+	require.NoError(t, err)
+	_, unsupportedErr := recQuerier.Query(unsupported, TESTING_GAS_LIMIT)
+	require.Error(t, unsupportedErr)
+	canon, cost, err := recAPI.CanonicalAddress("bob")
+	require.NoError(t, err)
+	require.Len(t, rec.record.Events, 3)
+
+	p := &player{events: rec.record.Events, gasMeter: NewInfiniteGasMeter()}
+	replayQuerier := &replayQuerier{p: p}
+	replayedRes, err := replayQuerier.Query(request, TESTING_GAS_LIMIT)
+	require.NoError(t, err)
+	assert.Equal(t, res, replayedRes)
+	_, err = replayQuerier.Query(unsupported, TESTING_GAS_LIMIT)
+	assert.Equal(t, types.ToQuerierResult(nil, unsupportedErr), types.ToQuerierResult(nil, err))
+	replayedCanon, replayedCost, err := p.api().CanonicalAddress("bob")
+	require.NoError(t, err)
+	assert.Equal(t, canon, replayedCanon)
+	assert.Equal(t, cost, replayedCost)
+	assert.Empty(t, p.events)
+
+	// replaying more calls than recorded fails
+	_, _, err = p.api().HumanAddress(canon)
+	require.EqualError(t, err, "replay diverged: unexpected humanize after the last recorded event")
+}
+
+func TestRecordAndReplayCall(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	balance := types.Coins{types.NewCoin(250, "ATOM")}
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, balance)
+	env := api.MockEnv()
+
+	dir, err := ioutil.TempDir("", "wasmvm-records")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	vm.SetCallRecorder(RecordToDir(dir))
+
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err = vm.Instantiate(checksum, env, api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	_, _, err = vm.Execute(checksum, env, api.MockInfo("fred", nil), []byte(`{"release":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	vm.SetCallRecorder(nil)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// replay on a fresh VM from the files and the code alone
+	other := withVM(t)
+	createTestContract(t, other, HACKATOM_TEST_CONTRACT)
+	for _, file := range files {
+		record, err := ReadCallRecord(file)
+		require.NoError(t, err)
+		res, err := other.Replay(record)
+		require.NoError(t, err)
+		assert.True(t, res.Identical, file)
+		assert.Equal(t, record.Result, res.Result)
+		assert.Equal(t, record.GasUsed, res.GasUsed)
+	}
+}
+
+func TestRecordExecuteBatch(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, QUEUE_TEST_CONTRACT)
+	store, gasMeter := instantiateQueue(t, vm, checksum)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+
+	var records []*CallRecord
+	vm.SetCallRecorder(func(record *CallRecord) { records = append(records, record) })
+	items := enqueueItems(3)
+	results := vm.ExecuteBatch(checksum, items, store, *goapi, querier, gasMeter, deserCost)
+	vm.SetCallRecorder(nil)
+
+	// every item is recorded on its own and can be replayed
+	require.Len(t, records, len(items))
+	for i, record := range records {
+		require.NoError(t, results[i].Err)
+		assert.Equal(t, EntryExecute, record.Entry)
+		assert.Equal(t, items[i].Msg, record.Msg)
+		assert.Equal(t, results[i].GasUsed, record.GasUsed)
+		res, err := vm.Replay(record)
+		require.NoError(t, err)
+		assert.True(t, res.Identical, "item %d", i)
+	}
+}
+
+func TestReplayOutOfGasInStore(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := vm.Instantiate(checksum, api.MockEnv(), api.MockInfo("creator", nil), msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+
+	// the storage loop runs out of gas on the SDK gas meter of the store
+	var records []*CallRecord
+	vm.SetCallRecorder(func(record *CallRecord) { records = append(records, record) })
+	gasMeter = api.NewMockGasMeter(100_000)
+	store.SetGasMeter(gasMeter)
+	_, _, err = vm.Execute(checksum, api.MockEnv(), api.MockInfo("fred", nil), []byte(`{"storage_loop":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	vm.SetCallRecorder(nil)
+	require.Error(t, err)
+	require.Len(t, records, 1)
+	last := records[0].Events[len(records[0].Events)-1]
+	require.NotNil(t, last.Panic)
+	assert.Equal(t, PanicCategoryOutOfGas, last.Panic.Category)
+
+	other := withVM(t)
+	_, err = other.Replay(records[0])
+	require.ErrorContains(t, err, "is not stored in the VM")
+	createTestContract(t, other, HACKATOM_TEST_CONTRACT)
+	res, err := other.Replay(records[0])
+	require.NoError(t, err)
+	assert.True(t, res.Identical)
+	assert.Equal(t, records[0].Err, res.Err)
+}
@@ -0,0 +1,327 @@
+package cosmwasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+// ReplayResult is the outcome of replaying a CallRecord
+type ReplayResult struct {
+	Result  []byte
+	GasUsed uint64
+	Err     string
+	// Identical is true if result, gas used and error are byte-for-byte identical to the record
+	Identical bool
+}
+
+// Replay runs a recorded call again, answering all host interactions from the record.
+// The contract code is not part of the record and must be stored in vm before, e.g. with Create.
+// The gas config of the record is used, independent of the VM's gas schedule. Storage operations
+// that panicked when recorded, e.g. because the gas meter ran out of gas, panic again.
+//
+// An error is returned if the replayed call interacts with the host differently than recorded,
+// e.g. because the record was created with another contract or VM version.
+func (vm *VM) Replay(record *CallRecord) (*ReplayResult, error) {
+	checksum := record.Checksum
+	if _, err := vm.GetCode(checksum); err != nil {
+		return nil, fmt.Errorf("code %X of the record is not stored in the VM: %w", checksum, err)
+	}
+
+	p := &player{events: record.Events, gasMeter: NewInfiniteGasMeter()}
+	store := AdaptKVStoreWithErrors(&replayStore{p: p})
+	goapi := p.api()
+	var querier Querier = &replayQuerier{p: p}
+	var gasMeter GasMeter = p.gasMeter
+	gas, err := startGas(record.GasConfig, record.GasLimit, &goapi, &querier)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	var gasUsed uint64
+	switch record.Entry {
+	case EntryInstantiate:
+		data, gasUsed, err = api.Instantiate(vm.cache, checksum, record.Env, record.Info, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryExecute:
+		data, gasUsed, err = api.Execute(vm.cache, checksum, record.Env, record.Info, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryMigrate:
+		data, gasUsed, err = api.Migrate(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntrySudo:
+		data, gasUsed, err = api.Sudo(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryReply:
+		data, gasUsed, err = api.Reply(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryQuery:
+		data, gasUsed, err = api.Query(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryIBCChannelOpen:
+		data, gasUsed, err = api.IBCChannelOpen(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryIBCChannelConnect:
+		data, gasUsed, err = api.IBCChannelConnect(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryIBCChannelClose:
+		data, gasUsed, err = api.IBCChannelClose(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryIBCPacketReceive:
+		data, gasUsed, err = api.IBCPacketReceive(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryIBCPacketAck:
+		data, gasUsed, err = api.IBCPacketAck(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	case EntryIBCPacketTimeout:
+		data, gasUsed, err = api.IBCPacketTimeout(vm.cache, checksum, record.Env, record.Msg, &gasMeter, store, &goapi, &querier, gas.vmLimit, vm.printDebug, nil)
+	default:
+		return nil, fmt.Errorf("unknown entry point %q", record.Entry)
+	}
+	gasUsed, err = gas.finish(gasUsed, data, err)
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	if len(p.events) != 0 {
+		return nil, fmt.Errorf("replay diverged: %d recorded events were not replayed, next is %s", len(p.events), p.events[0].Op)
+	}
+	res := &ReplayResult{Result: data, GasUsed: gasUsed, Err: errString(err)}
+	res.Identical = bytes.Equal(res.Result, record.Result) && res.GasUsed == record.GasUsed && res.Err == record.Err
+	return res, nil
+}
+
+// player hands out the recorded events in order
+type player struct {
+	events   []RecordedEvent
+	gasMeter FullGasMeter
+	// err is the first divergence
+	err error
+}
+
+// next returns the next event, which must have the given operation
+func (p *player) next(op string) (RecordedEvent, error) {
+	if p.err != nil {
+		return RecordedEvent{}, p.err
+	}
+	if len(p.events) == 0 {
+		p.err = fmt.Errorf("replay diverged: unexpected %s after the last recorded event", op)
+		return RecordedEvent{}, p.err
+	}
+	event := p.events[0]
+	if event.Op != op {
+		p.err = fmt.Errorf("replay diverged: expected %s, got %s", event.Op, op)
+		return RecordedEvent{}, p.err
+	}
+	p.events = p.events[1:]
+	return event, nil
+}
+
+// diverged records a mismatch of the arguments
+func (p *player) diverged(op string, format string, args ...interface{}) error {
+	p.err = fmt.Errorf("replay diverged in %s: %s", op, fmt.Sprintf(format, args...))
+	return p.err
+}
+
+// consume charges the gas of a storage operation and raises its recorded panic
+func (p *player) consume(event RecordedEvent) {
+	p.gasMeter.ConsumeGas(event.Gas, "replay "+event.Op)
+	if event.Panic != nil {
+		panic(api.ClassifiedPanic{Category: event.Panic.Category, Message: event.Panic.Message, Descriptor: event.Panic.Descriptor})
+	}
+}
+
+func (p *player) api() GoAPI {
+	return GoAPI{
+		HumanAddress: func(canon []byte) (string, uint64, error) {
+			event, err := p.next(RecordedHumanize)
+			if err != nil {
+				return "", 0, err
+			}
+			if !bytes.Equal(canon, event.Canonical) {
+				return "", 0, p.diverged(event.Op, "address %X instead of %X", canon, event.Canonical)
+			}
+			return event.Human, event.Gas, replayedError(event.Err)
+		},
+		CanonicalAddress: func(human string) ([]byte, uint64, error) {
+			event, err := p.next(RecordedCanonicalize)
+			if err != nil {
+				return nil, 0, err
+			}
+			if human != event.Human {
+				return nil, 0, p.diverged(event.Op, "address %q instead of %q", human, event.Human)
+			}
+			return event.Canonical, event.Gas, replayedError(event.Err)
+		},
+	}
+}
+
+func replayedError(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return errors.New(msg)
+}
+
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
+
+type replayQuerier struct {
+	p        *player
+	consumed uint64
+}
+
+var _ Querier = (*replayQuerier)(nil)
+
+func (q *replayQuerier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	event, err := q.p.next(RecordedQuery)
+	if err != nil {
+		return nil, err
+	}
+	reqBin, _ := json.Marshal(request)
+	if !bytes.Equal(reqBin, event.Request) {
+		return nil, q.p.diverged(event.Op, "request %s instead of %s", reqBin, event.Request)
+	}
+	q.consumed += event.Gas
+	if event.SystemError != nil {
+		return event.Response, event.SystemError
+	}
+	return event.Response, replayedError(event.Err)
+}
+
+func (q *replayQuerier) GasConsumed() uint64 {
+	return q.consumed
+}
+
+// replayStore answers storage operations from the record
+type replayStore struct {
+	p *player
+}
+
+var _ KVStoreWithErrors = (*replayStore)(nil)
+
+func (s *replayStore) Get(key []byte) ([]byte, error) {
+	event, err := s.p.next(RecordedGet)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(key, event.Key) {
+		return nil, s.p.diverged(event.Op, "key %X instead of %X", key, event.Key)
+	}
+	s.p.consume(event)
+	if event.Err != "" {
+		return nil, replayedError(event.Err)
+	}
+	if event.Missing {
+		return nil, nil
+	}
+	return nonNil(event.Value), nil
+}
+
+func (s *replayStore) Set(key, value []byte) error {
+	event, err := s.p.next(RecordedSet)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(key, event.Key) || !bytes.Equal(value, event.Value) {
+		return s.p.diverged(event.Op, "%X = %X instead of %X = %X", key, value, event.Key, event.Value)
+	}
+	s.p.consume(event)
+	return replayedError(event.Err)
+}
+
+func (s *replayStore) Delete(key []byte) error {
+	event, err := s.p.next(RecordedDelete)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(key, event.Key) {
+		return s.p.diverged(event.Op, "key %X instead of %X", key, event.Key)
+	}
+	s.p.consume(event)
+	return replayedError(event.Err)
+}
+
+func (s *replayStore) Iterator(start, end []byte) (Iterator, error) {
+	return s.scan(start, end, false)
+}
+
+func (s *replayStore) ReverseIterator(start, end []byte) (Iterator, error) {
+	return s.scan(start, end, true)
+}
+
+func (s *replayStore) scan(start, end []byte, descending bool) (Iterator, error) {
+	event, err := s.p.next(RecordedScan)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(start, event.Start) || !bytes.Equal(end, event.End) || descending != event.Descending {
+		return nil, s.p.diverged(event.Op, "range %X..%X (descending %t) instead of %X..%X (descending %t)",
+			start, end, descending, event.Start, event.End, event.Descending)
+	}
+	s.p.consume(event)
+	if event.Err != "" {
+		return nil, replayedError(event.Err)
+	}
+	return &replayIterator{p: s.p, id: event.Iterator, start: start, end: end}, nil
+}
+
+// replayIterator returns the entries of the recorded next events of its iterator. Since the call is
+// deterministic, the iterator is valid exactly if one of the remaining events is a next of this iterator.
+type replayIterator struct {
+	p          *player
+	id         int
+	start, end []byte
+	// err is the recorded error of the last next
+	err error
+}
+
+var _ Iterator = (*replayIterator)(nil)
+
+func (it *replayIterator) current() (RecordedEvent, bool) {
+	if it.p.err != nil {
+		return RecordedEvent{}, false
+	}
+	for _, event := range it.p.events {
+		if event.Op == RecordedNext && event.Iterator == it.id {
+			return event, true
+		}
+	}
+	return RecordedEvent{}, false
+}
+
+func (it *replayIterator) Domain() (start []byte, end []byte) {
+	return it.start, it.end
+}
+
+func (it *replayIterator) Valid() bool {
+	_, ok := it.current()
+	return ok
+}
+
+func (it *replayIterator) Next() {
+	event, err := it.p.next(RecordedNext)
+	if err == nil {
+		it.p.consume(event)
+		it.err = replayedError(event.Err)
+	}
+}
+
+func (it *replayIterator) Key() []byte {
+	event, _ := it.current()
+	return nonNil(event.Key)
+}
+
+func (it *replayIterator) Value() []byte {
+	event, _ := it.current()
+	return nonNil(event.Value)
+}
+
+func (it *replayIterator) Error() error {
+	if it.p.err != nil {
+		return it.p.err
+	}
+	return it.err
+}
+
+func (it *replayIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// matchJSON compares actual to the partial expectation expected and returns a diff per mismatch.
+// Objects match if every expected field matches, arrays if they have the same length and all
+// elements match, everything else must be equal.
+func matchJSON(path string, expected, actual []byte) []string {
+	var want, got interface{}
+	if err := unmarshalJSON(expected, &want); err != nil {
+		return []string{fmt.Sprintf("%s: invalid expectation: %s", path, err)}
+	}
+	if err := unmarshalJSON(actual, &got); err != nil {
+		return []string{fmt.Sprintf("%s: invalid JSON %q: %s", path, actual, err)}
+	}
+	return matchValue(path, want, got)
+}
+
+// unmarshalJSON keeps numbers as json.Number, so large integers are compared exactly
+func unmarshalJSON(bz []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(bz))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func matchValue(path string, want, got interface{}) []string {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		obj, ok := got.(map[string]interface{})
+		if !ok {
+			return []string{mismatch(path, want, got)}
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var diffs []string
+		for _, key := range keys {
+			value, ok := obj[key]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, key))
+				continue
+			}
+			diffs = append(diffs, matchValue(path+"."+key, want[key], value)...)
+		}
+		return diffs
+	case []interface{}:
+		arr, ok := got.([]interface{})
+		if !ok {
+			return []string{mismatch(path, want, got)}
+		}
+		if len(arr) != len(want) {
+			return []string{fmt.Sprintf("%s: expected %d elements, got %d", path, len(want), len(arr))}
+		}
+		var diffs []string
+		for i := range want {
+			diffs = append(diffs, matchValue(fmt.Sprintf("%s[%d]", path, i), want[i], arr[i])...)
+		}
+		return diffs
+	default:
+		if want != got {
+			return []string{mismatch(path, want, got)}
+		}
+		return nil
+	}
+}
+
+func mismatch(path string, want, got interface{}) string {
+	wantBz, _ := json.Marshal(want)
+	gotBz, _ := json.Marshal(got)
+	return fmt.Sprintf("%s: expected %s, got %s", path, wantBz, gotBz)
+}
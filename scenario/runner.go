@@ -0,0 +1,451 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	cosmwasm "github.com/line/wasmvm"
+	"github.com/line/wasmvm/types"
+)
+
+// Defaults of a Runner
+const (
+	DefaultGasLimit uint64 = 500_000_000_000
+	DefaultChainID         = "scenario"
+	// DefaultTime is 2022-01-01T00:00:00Z in nanoseconds
+	DefaultTime uint64 = 1_640_995_200_000_000_000
+)
+
+// Runner executes scenarios. Every scenario starts with an empty state at height 1.
+type Runner struct {
+	vm *cosmwasm.VM
+	// GasLimit is the gas limit of every call
+	GasLimit uint64
+	// DeserCost is passed to all calls
+	DeserCost types.UFraction
+}
+
+// NewRunner creates a runner using vm
+func NewRunner(vm *cosmwasm.VM) *Runner {
+	return &Runner{
+		vm:        vm,
+		GasLimit:  DefaultGasLimit,
+		DeserCost: types.UFraction{Numerator: 1, Denominator: 1},
+	}
+}
+
+// Result is the outcome of a scenario
+type Result struct {
+	Name  string
+	Steps []StepResult
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	// Index is the 1-based position of the step
+	Index int
+	Name  string
+	Kind  string
+	// GasUsed is the gas reported by the VM for calls
+	GasUsed uint64
+	// Diffs are the failed expectations
+	Diffs []string
+}
+
+// Passed returns true if all expectations were met
+func (r *Result) Passed() bool {
+	for _, step := range r.Steps {
+		if len(step.Diffs) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String reports the failed steps with their diffs
+func (r *Result) String() string {
+	var b strings.Builder
+	if r.Passed() {
+		fmt.Fprintf(&b, "PASS %s (%d steps)\n", r.Name, len(r.Steps))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "FAIL %s\n", r.Name)
+	for _, step := range r.Steps {
+		if len(step.Diffs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  step %d (%s", step.Index, step.Kind)
+		if step.Name != "" {
+			fmt.Fprintf(&b, " %q", step.Name)
+		}
+		b.WriteString("):\n")
+		for _, diff := range step.Diffs {
+			fmt.Fprintf(&b, "    %s\n", diff)
+		}
+	}
+	return b.String()
+}
+
+// chain is the state of one scenario run
+type chain struct {
+	// dbs holds the storage of every contract
+	dbs       map[string]*cosmwasm.MemDB
+	codes     map[string]cosmwasm.Checksum
+	contracts map[string]string
+	// checksums maps contract addresses to their code
+	checksums map[string]cosmwasm.Checksum
+	balances  map[string]types.Coins
+	block     types.BlockInfo
+	txIndex   uint32
+}
+
+// Run executes all steps of s. A step that fails to run (e.g. unknown contract) stops the scenario,
+// failed expectations do not.
+func (r *Runner) Run(s *Scenario) *Result {
+	c := &chain{
+		dbs:       make(map[string]*cosmwasm.MemDB),
+		codes:     make(map[string]cosmwasm.Checksum),
+		contracts: make(map[string]string),
+		checksums: make(map[string]cosmwasm.Checksum),
+		balances:  make(map[string]types.Coins),
+		block:     types.BlockInfo{Height: 1, Time: DefaultTime, ChainID: DefaultChainID},
+	}
+	result := &Result{Name: s.Name}
+	for i, step := range s.Steps {
+		res := StepResult{Index: i + 1, Name: step.Name, Kind: step.kind()}
+		err := r.runStep(c, s, step, &res)
+		if err != nil {
+			res.Diffs = append(res.Diffs, err.Error())
+		}
+		result.Steps = append(result.Steps, res)
+		if err != nil {
+			break
+		}
+	}
+	return result
+}
+
+func (r *Runner) runStep(c *chain, s *Scenario, step Step, res *StepResult) error {
+	switch {
+	case step.StoreCode != nil:
+		path := step.StoreCode.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.dir, path)
+		}
+		code, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		checksum, err := r.vm.Create(code)
+		if err != nil {
+			return err
+		}
+		c.codes[step.StoreCode.Code] = checksum
+		return nil
+	case step.AdvanceBlock != nil:
+		a := step.AdvanceBlock
+		c.block.Height += a.Blocks
+		c.block.Time += a.Seconds * 1_000_000_000
+		if a.Height != 0 {
+			c.block.Height = a.Height
+		}
+		if a.Time != 0 {
+			c.block.Time = a.Time
+		}
+		c.txIndex = 0
+		return nil
+	}
+
+	var outcome callOutcome
+	switch {
+	case step.Instantiate != nil:
+		outcome = r.instantiate(c, step.Instantiate)
+	case step.Execute != nil:
+		outcome = r.execute(c, step.Execute)
+	default:
+		outcome = r.query(c, step.Query)
+	}
+	if outcome.setupErr != nil {
+		return outcome.setupErr
+	}
+	res.GasUsed = outcome.gasUsed
+	res.Diffs = append(res.Diffs, check(step.Expect, outcome)...)
+	return nil
+}
+
+// callOutcome is the result of a contract call
+type callOutcome struct {
+	// setupErr means the call could not be made
+	setupErr error
+	// response is the JSON response or query result
+	response []byte
+	events   []types.Event
+	gasUsed  uint64
+	err      error
+}
+
+func (c *chain) env(contract string) types.Env {
+	env := types.Env{
+		Block:       c.block,
+		Transaction: &types.TransactionInfo{Index: c.txIndex},
+		Contract:    types.ContractInfo{Address: contract},
+	}
+	c.txIndex++
+	return env
+}
+
+// db returns the storage of a contract, creating it on first use
+func (c *chain) db(contract string) *cosmwasm.MemDB {
+	db, ok := c.dbs[contract]
+	if !ok {
+		db = cosmwasm.NewMemDB()
+		c.dbs[contract] = db
+	}
+	return db
+}
+
+func (c *chain) store(contract string) cosmwasm.KVStore {
+	return cosmwasm.AdaptKVStoreWithErrors(c.db(contract))
+}
+
+// commit applies the writes of a successful call. Failed calls are discarded, like on chain.
+func (c *chain) commit(address string, overlay *cosmwasm.OverlayStore) error {
+	db := c.db(address)
+	for _, write := range overlay.Writes() {
+		var err error
+		if write.Delete {
+			err = db.Delete(write.Key)
+		} else {
+			err = db.Set(write.Key, write.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("committing writes of %s: %w", address, err)
+		}
+	}
+	return nil
+}
+
+func (c *chain) contract(name string) (string, error) {
+	address, ok := c.contracts[name]
+	if !ok {
+		return "", fmt.Errorf("unknown contract %q", name)
+	}
+	return address, nil
+}
+
+// addBalance credits funds and returns the previous balance. There are no accounts, so funds are
+// not taken from the sender.
+func (c *chain) addBalance(address string, funds types.Coins) (types.Coins, error) {
+	previous := c.balances[address]
+	balance, err := previous.Add(funds)
+	if err != nil {
+		return nil, err
+	}
+	c.balances[address] = balance
+	return previous, nil
+}
+
+func responseOutcome(contract string, res *types.Response, gasUsed uint64, err error) callOutcome {
+	outcome := callOutcome{gasUsed: gasUsed, err: err}
+	if res != nil {
+		outcome.response, _ = json.Marshal(res)
+		attributes := append(types.EventAttributes{{Key: "_contract_address", Value: contract}}, res.Attributes...)
+		outcome.events = append([]types.Event{{Type: "wasm", Attributes: attributes}}, res.Events...)
+	}
+	return outcome
+}
+
+func (r *Runner) instantiate(c *chain, msg *Instantiate) callOutcome {
+	checksum, ok := c.codes[msg.Code]
+	if !ok {
+		return callOutcome{setupErr: fmt.Errorf("unknown code %q", msg.Code)}
+	}
+	if _, exists := c.contracts[msg.Contract]; exists {
+		return callOutcome{setupErr: fmt.Errorf("contract %q already exists", msg.Contract)}
+	}
+	address := fmt.Sprintf("contract%d", len(c.contracts)+1)
+	previous, err := c.addBalance(address, msg.Funds)
+	if err != nil {
+		return callOutcome{setupErr: err}
+	}
+	info := types.MessageInfo{Sender: msg.Sender, Funds: coinsOrEmpty(msg.Funds)}
+	gasMeter := cosmwasm.NewGasMeter(r.GasLimit)
+	overlay := cosmwasm.NewOverlayStore(c.store(address))
+	res, gasUsed, err := r.vm.Instantiate(checksum, c.env(address), info, msg.Msg, overlay, goAPI(), &querier{c: c, r: r}, gasMeter, r.GasLimit, r.DeserCost)
+	if err == nil {
+		if err := c.commit(address, overlay); err != nil {
+			return callOutcome{setupErr: err}
+		}
+		c.contracts[msg.Contract] = address
+		c.checksums[address] = checksum
+	} else {
+		c.balances[address] = previous
+	}
+	return responseOutcome(address, res, gasUsed, err)
+}
+
+func (r *Runner) execute(c *chain, msg *Execute) callOutcome {
+	address, err := c.contract(msg.Contract)
+	if err != nil {
+		return callOutcome{setupErr: err}
+	}
+	previous, err := c.addBalance(address, msg.Funds)
+	if err != nil {
+		return callOutcome{setupErr: err}
+	}
+	info := types.MessageInfo{Sender: msg.Sender, Funds: coinsOrEmpty(msg.Funds)}
+	gasMeter := cosmwasm.NewGasMeter(r.GasLimit)
+	overlay := cosmwasm.NewOverlayStore(c.store(address))
+	res, gasUsed, err := r.vm.Execute(c.checksum(address), c.env(address), info, msg.Msg, overlay, goAPI(), &querier{c: c, r: r}, gasMeter, r.GasLimit, r.DeserCost)
+	if err == nil {
+		if err := c.commit(address, overlay); err != nil {
+			return callOutcome{setupErr: err}
+		}
+	} else {
+		c.balances[address] = previous
+	}
+	return responseOutcome(address, res, gasUsed, err)
+}
+
+func (r *Runner) query(c *chain, msg *Query) callOutcome {
+	address, err := c.contract(msg.Contract)
+	if err != nil {
+		return callOutcome{setupErr: err}
+	}
+	gasMeter := cosmwasm.NewGasMeter(r.GasLimit)
+	env := types.Env{Block: c.block, Contract: types.ContractInfo{Address: address}}
+	res, gasUsed, err := r.vm.Query(c.checksum(address), env, msg.Msg, c.store(address), goAPI(), &querier{c: c, r: r}, gasMeter, r.GasLimit, r.DeserCost)
+	return callOutcome{response: res, gasUsed: gasUsed, err: err}
+}
+
+// checksum returns the code of a contract, nil for unknown addresses
+func (c *chain) checksum(address string) cosmwasm.Checksum {
+	return c.checksums[address]
+}
+
+func coinsOrEmpty(coins types.Coins) types.Coins {
+	if coins == nil {
+		return types.Coins{}
+	}
+	return coins
+}
+
+// check compares the outcome of a call with the expectations
+func check(expect *Expect, outcome callOutcome) []string {
+	if expect == nil {
+		expect = &Expect{}
+	}
+	var diffs []string
+	if expect.Error != "" {
+		switch {
+		case outcome.err == nil:
+			diffs = append(diffs, fmt.Sprintf("error: expected an error containing %q, the call succeeded", expect.Error))
+		case !strings.Contains(outcome.err.Error(), expect.Error):
+			diffs = append(diffs, fmt.Sprintf("error: expected an error containing %q, got %q", expect.Error, outcome.err.Error()))
+		}
+	} else if outcome.err != nil {
+		diffs = append(diffs, fmt.Sprintf("error: unexpected error %q", outcome.err.Error()))
+	}
+
+	if len(expect.Response) != 0 && outcome.err == nil {
+		diffs = append(diffs, matchJSON("response", expect.Response, outcome.response)...)
+	}
+	for _, event := range expect.Events {
+		if !containsEvent(outcome.events, event) {
+			bz, _ := json.Marshal(event)
+			diffs = append(diffs, fmt.Sprintf("events: no event matches %s", bz))
+		}
+	}
+	if gas := expect.Gas; gas != nil {
+		if outcome.gasUsed < gas.Min || (gas.Max != 0 && outcome.gasUsed > gas.Max) {
+			diffs = append(diffs, fmt.Sprintf("gas: used %d, expected %s", outcome.gasUsed, gas))
+		}
+	}
+	return diffs
+}
+
+func (g *GasRange) String() string {
+	if g.Max == 0 {
+		return fmt.Sprintf("at least %d", g.Min)
+	}
+	return fmt.Sprintf("between %d and %d", g.Min, g.Max)
+}
+
+// containsEvent returns true if one of events has the type and all attributes of expected
+func containsEvent(events []types.Event, expected types.Event) bool {
+	for _, event := range events {
+		if event.Type != expected.Type {
+			continue
+		}
+		matches := true
+		for _, want := range expected.Attributes {
+			found := false
+			for _, attr := range event.Attributes {
+				if attr == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// querier answers bank balance and wasm queries from the scenario state
+type querier struct {
+	c *chain
+	r *Runner
+}
+
+var _ cosmwasm.Querier = (*querier)(nil)
+
+func (q *querier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	switch {
+	case request.Bank != nil && request.Bank.AllBalances != nil:
+		return json.Marshal(types.AllBalancesResponse{Amount: coinsOrEmpty(q.c.balances[request.Bank.AllBalances.Address])})
+	case request.Bank != nil && request.Bank.Balance != nil:
+		res := types.BalanceResponse{Amount: types.NewCoin(0, request.Bank.Balance.Denom)}
+		for _, coin := range q.c.balances[request.Bank.Balance.Address] {
+			if coin.Denom == request.Bank.Balance.Denom {
+				res.Amount = coin
+			}
+		}
+		return json.Marshal(res)
+	case request.Wasm != nil && request.Wasm.Smart != nil:
+		address := request.Wasm.Smart.ContractAddr
+		checksum := q.c.checksum(address)
+		if checksum == nil {
+			return nil, types.NoSuchContract{Addr: address}
+		}
+		env := types.Env{Block: q.c.block, Contract: types.ContractInfo{Address: address}}
+		res, _, err := q.r.vm.Query(checksum, env, request.Wasm.Smart.Msg, q.c.store(address), goAPI(), q, cosmwasm.NewGasMeter(gasLimit), gasLimit, q.r.DeserCost)
+		return res, err
+	case request.Wasm != nil && request.Wasm.Raw != nil:
+		address := request.Wasm.Raw.ContractAddr
+		if q.c.checksum(address) == nil {
+			return nil, types.NoSuchContract{Addr: address}
+		}
+		return q.c.db(address).Get(request.Wasm.Raw.Key)
+	default:
+		return nil, types.UnsupportedRequest{Kind: "only bank balance and wasm smart/raw queries are supported"}
+	}
+}
+
+func (q *querier) GasConsumed() uint64 {
+	return 0
+}
+
+// goAPI uses the address bytes as canonical address
+func goAPI() cosmwasm.GoAPI {
+	return cosmwasm.NewPlainGoAPI(0, 0)
+}
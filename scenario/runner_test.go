@@ -0,0 +1,48 @@
+package scenario
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cosmwasm "github.com/line/wasmvm"
+)
+
+func withVM(t *testing.T) *cosmwasm.VM {
+	tmpdir, err := ioutil.TempDir("", "wasmvm-scenario")
+	require.NoError(t, err)
+	vm, err := cosmwasm.NewVM(tmpdir, "staking,stargate,iterator", 32, false, 100)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		vm.Cleanup()
+		os.RemoveAll(tmpdir)
+	})
+	return vm
+}
+
+func TestRunHackatom(t *testing.T) {
+	s, err := Load("testdata/hackatom.json")
+	require.NoError(t, err)
+
+	res := NewRunner(withVM(t)).Run(s)
+	require.True(t, res.Passed(), res.String())
+	require.Len(t, res.Steps, 6)
+	assert.NotZero(t, res.Steps[5].GasUsed)
+}
+
+func TestRunReportsDiffs(t *testing.T) {
+	s, err := Load("testdata/hackatom.json")
+	require.NoError(t, err)
+	s.Steps[2].Expect.Response = []byte(`{"verifier": "bob"}`)
+	s.Steps = append(s.Steps, Step{Execute: &Execute{Contract: "unknown", Sender: "fred", Msg: []byte(`{}`)}}, s.Steps[2])
+
+	res := NewRunner(withVM(t)).Run(s)
+	require.False(t, res.Passed())
+	// a step that cannot run stops the scenario
+	require.Len(t, res.Steps, 7, res.String())
+	assert.Equal(t, []string{`response.verifier: expected "bob", got "fred"`}, res.Steps[2].Diffs)
+	assert.Equal(t, []string{`unknown contract "unknown"`}, res.Steps[6].Diffs)
+}
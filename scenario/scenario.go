@@ -0,0 +1,175 @@
+// Package scenario runs declarative contract test scripts against the VM.
+//
+// A scenario is a JSON file with a list of steps. Every step performs one action (store code,
+// instantiate, execute, query, advance the block) and optionally checks its outcome:
+//
+//	{
+//	  "name": "hackatom release",
+//	  "steps": [
+//	    {"store_code": {"code": "hackatom", "file": "../testdata/hackatom.wasm"}},
+//	    {"instantiate": {"code": "hackatom", "contract": "h", "sender": "creator",
+//	      "funds": [{"denom": "ucosm", "amount": "1000"}],
+//	      "msg": {"verifier": "fred", "beneficiary": "bob"}}},
+//	    {"advance_block": {"blocks": 1, "seconds": 5}},
+//	    {"execute": {"contract": "h", "sender": "fred", "msg": {"release": {}}},
+//	     "expect": {"events": [{"type": "wasm", "attributes": [{"key": "action", "value": "release"}]}],
+//	                "gas": {"max": 200000000000}}},
+//	    {"query": {"contract": "h", "msg": {"verifier": {}}}, "expect": {"response": {"verifier": "fred"}}}
+//	  ]
+//	}
+//
+// Contracts and code are referred to by the names given in the scenario. Messages returned by
+// contracts are checked but not dispatched.
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/line/wasmvm/types"
+)
+
+// Scenario is a named list of steps
+type Scenario struct {
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+	// dir is the directory of the scenario file, code files are relative to it
+	dir string
+}
+
+// Step is a rust style enum, exactly one of the actions must be set
+type Step struct {
+	// Name optionally describes the step in reports
+	Name         string        `json:"name,omitempty"`
+	StoreCode    *StoreCode    `json:"store_code,omitempty"`
+	Instantiate  *Instantiate  `json:"instantiate,omitempty"`
+	Execute      *Execute      `json:"execute,omitempty"`
+	Query        *Query        `json:"query,omitempty"`
+	AdvanceBlock *AdvanceBlock `json:"advance_block,omitempty"`
+	// Expect checks the outcome of instantiate, execute and query. Without it, these must succeed.
+	Expect *Expect `json:"expect,omitempty"`
+}
+
+// StoreCode stores the Wasm file under the name Code
+type StoreCode struct {
+	Code string `json:"code"`
+	File string `json:"file"`
+}
+
+// Instantiate creates a contract from stored code and names it Contract
+type Instantiate struct {
+	Code     string          `json:"code"`
+	Contract string          `json:"contract"`
+	Sender   string          `json:"sender"`
+	Funds    types.Coins     `json:"funds,omitempty"`
+	Msg      json.RawMessage `json:"msg"`
+}
+
+// Execute sends a message to a contract
+type Execute struct {
+	Contract string          `json:"contract"`
+	Sender   string          `json:"sender"`
+	Funds    types.Coins     `json:"funds,omitempty"`
+	Msg      json.RawMessage `json:"msg"`
+}
+
+// Query queries a contract
+type Query struct {
+	Contract string          `json:"contract"`
+	Msg      json.RawMessage `json:"msg"`
+}
+
+// AdvanceBlock moves to a later block. Height and Time (nanoseconds) set the block directly,
+// Blocks and Seconds advance it relative to the current block.
+type AdvanceBlock struct {
+	Blocks  uint64 `json:"blocks,omitempty"`
+	Seconds uint64 `json:"seconds,omitempty"`
+	Height  uint64 `json:"height,omitempty"`
+	Time    uint64 `json:"time,string,omitempty"`
+}
+
+// Expect describes the expected outcome of a call
+type Expect struct {
+	// Error must be contained in the error message. If set, the call must fail.
+	Error string `json:"error,omitempty"`
+	// Response must match the contract response, the query result for queries. Fields that are
+	// not part of Response are ignored, arrays must match in length.
+	Response json.RawMessage `json:"response,omitempty"`
+	// Events must each match one event of the response. Attributes that are not listed are ignored.
+	// The attributes of the response are available as event of type "wasm", like on chain.
+	Events []types.Event `json:"events,omitempty"`
+	// Gas limits the gas used by the VM
+	Gas *GasRange `json:"gas,omitempty"`
+}
+
+// GasRange is an inclusive range of gas. A Max of 0 means no upper limit.
+type GasRange struct {
+	Min uint64 `json:"min,omitempty"`
+	Max uint64 `json:"max,omitempty"`
+}
+
+// Load reads a scenario file
+func Load(path string) (*Scenario, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := json.Unmarshal(bz, &s); err != nil {
+		return nil, fmt.Errorf("cannot parse scenario %s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = filepath.Base(path)
+	}
+	s.dir = filepath.Dir(path)
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate checks that every step has exactly one action and that funds can be sent to contracts
+func (s *Scenario) Validate() error {
+	for i, step := range s.Steps {
+		actions := 0
+		for _, set := range []bool{step.StoreCode != nil, step.Instantiate != nil, step.Execute != nil, step.Query != nil, step.AdvanceBlock != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("step %d has %d actions, expected exactly one", i+1, actions)
+		}
+		if step.Expect != nil && (step.StoreCode != nil || step.AdvanceBlock != nil) {
+			return fmt.Errorf("step %d: only instantiate, execute and query can have expectations", i+1)
+		}
+		var funds types.Coins
+		if step.Instantiate != nil {
+			funds = step.Instantiate.Funds
+		} else if step.Execute != nil {
+			funds = step.Execute.Funds
+		}
+		if err := funds.ValidateForContracts(); err != nil {
+			return fmt.Errorf("step %d: invalid funds: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// kind returns the name of the action of a step
+func (step Step) kind() string {
+	switch {
+	case step.StoreCode != nil:
+		return "store_code"
+	case step.Instantiate != nil:
+		return "instantiate"
+	case step.Execute != nil:
+		return "execute"
+	case step.Query != nil:
+		return "query"
+	default:
+		return "advance_block"
+	}
+}
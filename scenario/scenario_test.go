@@ -0,0 +1,117 @@
+package scenario
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/types"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := Load("testdata/hackatom.json")
+	require.NoError(t, err)
+	assert.Equal(t, "hackatom release", s.Name)
+	assert.Equal(t, "testdata", s.dir)
+	require.Len(t, s.Steps, 6)
+	assert.Equal(t, "store_code", s.Steps[0].kind())
+	assert.Equal(t, types.Coins{types.NewCoin(1000, "ucosm")}, s.Steps[1].Instantiate.Funds)
+	assert.Equal(t, "advance_block", s.Steps[3].kind())
+	assert.Equal(t, "only the verifier can release", s.Steps[4].Name)
+	assert.Equal(t, "Unauthorized", s.Steps[4].Expect.Error)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scenario")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := map[string]string{
+		"no action":            `{"steps": [{"name": "nothing"}]}`,
+		"two actions":          `{"steps": [{"query": {"contract": "a", "msg": {}}, "advance_block": {"blocks": 1}}]}`,
+		"expect on block":      `{"steps": [{"advance_block": {"blocks": 1}, "expect": {"error": "x"}}]}`,
+		"not a scenario":       `[]`,
+		"time not a string":    `{"steps": [{"advance_block": {"time": 5}}]}`,
+		"funds above 128 bits": `{"steps": [{"execute": {"contract": "a", "msg": {}, "funds": [{"denom": "ucosm", "amount": "340282366920938463463374607431768211456"}]}}]}`,
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "s.json")
+			require.NoError(t, ioutil.WriteFile(path, []byte(content), 0o644))
+			_, err := Load(path)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMatchJSON(t *testing.T) {
+	actual := []byte(`{"messages": [], "attributes": [{"key": "action", "value": "release"}], "data": null, "count": 12345678901234567890}`)
+
+	cases := map[string]struct {
+		expected string
+		diffs    []string
+	}{
+		"empty object":  {`{}`, nil},
+		"partial":       {`{"attributes": [{"key": "action"}]}`, nil},
+		"exact number":  {`{"count": 12345678901234567890}`, nil},
+		"null":          {`{"data": null}`, nil},
+		"wrong value":   {`{"attributes": [{"value": "x"}]}`, []string{`response.attributes[0].value: expected "x", got "release"`}},
+		"missing field": {`{"foo": 1, "bar": 2}`, []string{"response.bar: missing", "response.foo: missing"}},
+		"wrong length":  {`{"messages": [{}]}`, []string{"response.messages: expected 1 elements, got 0"}},
+		"wrong type":    {`{"attributes": {}}`, []string{`response.attributes: expected {}, got [{"key":"action","value":"release"}]`}},
+		"wrong number":  {`{"count": 12345678901234567891}`, []string{"response.count: expected 12345678901234567891, got 12345678901234567890"}},
+		"invalid":       {`{`, []string{"response: invalid expectation: unexpected EOF"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.diffs, matchJSON("response", []byte(tc.expected), actual))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	outcome := responseOutcome("contract1", &types.Response{
+		Attributes: types.EventAttributes{{Key: "action", Value: "release"}},
+		Events:     []types.Event{{Type: "transfer", Attributes: types.EventAttributes{{Key: "amount", Value: "5ucosm"}}}},
+	}, 1000, nil)
+
+	assert.Empty(t, check(nil, outcome))
+	assert.Empty(t, check(&Expect{
+		Events: []types.Event{
+			{Type: "wasm", Attributes: types.EventAttributes{{Key: "_contract_address", Value: "contract1"}, {Key: "action", Value: "release"}}},
+			{Type: "transfer"},
+		},
+		Gas: &GasRange{Min: 1000, Max: 1000},
+	}, outcome))
+
+	diffs := check(&Expect{
+		Error:  "unauthorized",
+		Events: []types.Event{{Type: "wasm", Attributes: types.EventAttributes{{Key: "action", Value: "burn"}}}},
+		Gas:    &GasRange{Max: 999},
+	}, outcome)
+	assert.Equal(t, []string{
+		`error: expected an error containing "unauthorized", the call succeeded`,
+		`events: no event matches {"type":"wasm","attributes":[{"key":"action","value":"burn"}]}`,
+		"gas: used 1000, expected between 0 and 999",
+	}, diffs)
+
+	failed := callOutcome{err: errors.New("Generic error: Unauthorized"), gasUsed: 10}
+	assert.Empty(t, check(&Expect{Error: "Unauthorized"}, failed))
+	assert.Equal(t, []string{`error: unexpected error "Generic error: Unauthorized"`}, check(nil, failed))
+	assert.Equal(t, []string{`error: expected an error containing "overflow", got "Generic error: Unauthorized"`}, check(&Expect{Error: "overflow"}, failed))
+}
+
+func TestResultString(t *testing.T) {
+	res := &Result{Name: "demo", Steps: []StepResult{{Index: 1, Kind: "store_code"}}}
+	assert.True(t, res.Passed())
+	assert.Equal(t, "PASS demo (1 steps)\n", res.String())
+
+	res.Steps = append(res.Steps, StepResult{Index: 2, Kind: "execute", Name: "release", Diffs: []string{"response.data: missing"}})
+	assert.False(t, res.Passed())
+	assert.Equal(t, "FAIL demo\n  step 2 (execute \"release\"):\n    response.data: missing\n", res.String())
+}
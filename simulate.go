@@ -0,0 +1,182 @@
+package cosmwasm
+
+import (
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+// StoreWrite is a single write or delete a contract performed during a simulation
+type StoreWrite = api.StoreWrite
+
+// OverlayStore is a KVStore that keeps all changes in memory on top of a read-only parent store
+type OverlayStore = api.OverlayStore
+
+// NewOverlayStore creates an empty overlay on top of parent. Changes are never written to parent.
+func NewOverlayStore(parent KVStore) *OverlayStore {
+	return api.NewOverlayStore(parent)
+}
+
+// MeteredStore is a KVStore that charges gas itself. The simulations run their overlay below the
+// metering of such stores, so they consume the same gas for writes as a real call. Hosts whose store
+// charges gas (e.g. the SDK's gaskv store) should implement it to get accurate simulations.
+type MeteredStore = api.MeteredStore
+
+// BranchStore creates an overlay on top of store and returns it together with the store to pass to
+// the contract calls. See MeteredStore.
+func BranchStore(store KVStore) (*OverlayStore, KVStore) {
+	return api.BranchStore(store)
+}
+
+// QueryRecord is a query a contract issued during a simulation together with the querier's answer
+type QueryRecord struct {
+	Request  types.QueryRequest
+	Response []byte
+	Err      error
+}
+
+// SimulationResult is the outcome of a dry run of a contract call
+type SimulationResult struct {
+	// Response is the contract response. It is nil if the call failed.
+	Response *types.Response
+	// GasUsed is the gas reported by the VM including the deserialization cost, like the gas
+	// returned from Execute and friends
+	GasUsed uint64
+	// ExternalGasUsed is the gas consumed on the GasMeter during the call, e.g. by storage access.
+	// This is not included in GasUsed.
+	ExternalGasUsed uint64
+	// Writes are all writes and deletes of the call in the order they happened
+	Writes []StoreWrite
+	// Queries are all queries issued by the contract in the order they happened
+	Queries []QueryRecord
+	// Access is the read and write set of the call, see AccessSet.ConflictsWith
+	Access *AccessSet
+	// Commitment is the write commitment of the call, nil if it failed. See CallOutput.
+	Commitment []byte
+}
+
+// recordingQuerier remembers all queries passed to the wrapped querier
+type recordingQuerier struct {
+	querier Querier
+	records []QueryRecord
+}
+
+var _ Querier = (*recordingQuerier)(nil)
+
+func (q *recordingQuerier) Query(request types.QueryRequest, gasLimit uint64) ([]byte, error) {
+	res, err := q.querier.Query(request, gasLimit)
+	q.records = append(q.records, QueryRecord{Request: request, Response: res, Err: err})
+	return res, err
+}
+
+func (q *recordingQuerier) GasConsumed() uint64 {
+	return q.querier.GasConsumed()
+}
+
+// simulation holds the wrapped inputs of one simulated call
+type simulation struct {
+	store       *OverlayStore
+	output      *CallOutput
+	kv          KVStore
+	querier     *recordingQuerier
+	gasMeter    GasMeter
+	gasConsumed uint64
+}
+
+func startSimulation(store KVStore, querier Querier, gasMeter GasMeter) *simulation {
+	overlay, branch := BranchStore(store)
+	return &simulation{
+		store:       overlay,
+		output:      &CallOutput{RecordAccess: true, CommitWrites: true},
+		kv:          branch,
+		querier:     &recordingQuerier{querier: querier},
+		gasMeter:    gasMeter,
+		gasConsumed: gasMeter.GasConsumed(),
+	}
+}
+
+func (s *simulation) result(res *types.Response, gasUsed uint64) *SimulationResult {
+	return &SimulationResult{
+		Response:        res,
+		GasUsed:         gasUsed,
+		ExternalGasUsed: s.gasMeter.GasConsumed() - s.gasConsumed,
+		Writes:          s.store.Writes(),
+		Queries:         s.querier.records,
+		Access:          s.output.Access,
+		Commitment:      s.output.Commitment,
+	}
+}
+
+// SimulateInstantiate runs Instantiate against a discardable overlay of store. The store itself is
+// never modified. The result is returned even if the call fails, such that the gas used and the
+// operations up to the failure can be inspected.
+//
+// If store is a MeteredStore, the overlay runs below its metering, so writes and deletes consume the
+// same gas as in a real call. Other stores cannot charge gas for writes, as the writes never reach them.
+func (vm *VM) SimulateInstantiate(
+	checksum Checksum,
+	env types.Env,
+	info types.MessageInfo,
+	initMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+) (*SimulationResult, error) {
+	sim := startSimulation(store, querier, gasMeter)
+	res, gasUsed, err := vm.Instantiate(checksum, env, info, initMsg, sim.kv, goapi, sim.querier, gasMeter, gasLimit, deserCost, sim.output)
+	return sim.result(res, gasUsed), err
+}
+
+// SimulateExecute runs Execute against a discardable overlay of store. See SimulateInstantiate.
+func (vm *VM) SimulateExecute(
+	checksum Checksum,
+	env types.Env,
+	info types.MessageInfo,
+	executeMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+) (*SimulationResult, error) {
+	sim := startSimulation(store, querier, gasMeter)
+	res, gasUsed, err := vm.Execute(checksum, env, info, executeMsg, sim.kv, goapi, sim.querier, gasMeter, gasLimit, deserCost, sim.output)
+	return sim.result(res, gasUsed), err
+}
+
+// SimulateMigrate runs Migrate against a discardable overlay of store. See SimulateInstantiate.
+func (vm *VM) SimulateMigrate(
+	checksum Checksum,
+	env types.Env,
+	migrateMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+) (*SimulationResult, error) {
+	sim := startSimulation(store, querier, gasMeter)
+	res, gasUsed, err := vm.Migrate(checksum, env, migrateMsg, sim.kv, goapi, sim.querier, gasMeter, gasLimit, deserCost, sim.output)
+	return sim.result(res, gasUsed), err
+}
+
+// SimulateSudo runs Sudo against a discardable overlay of store. See SimulateInstantiate.
+func (vm *VM) SimulateSudo(
+	checksum Checksum,
+	env types.Env,
+	sudoMsg []byte,
+	store KVStore,
+	goapi GoAPI,
+	querier Querier,
+	gasMeter GasMeter,
+	gasLimit uint64,
+	deserCost types.UFraction,
+) (*SimulationResult, error) {
+	sim := startSimulation(store, querier, gasMeter)
+	res, gasUsed, err := vm.Sudo(checksum, env, sudoMsg, sim.kv, goapi, sim.querier, gasMeter, gasLimit, deserCost, sim.output)
+	return sim.result(res, gasUsed), err
+}
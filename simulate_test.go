@@ -0,0 +1,81 @@
+package cosmwasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+func TestSimulateExecuteDoesNotModifyStore(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, QUEUE_TEST_CONTRACT)
+	store, gasMeter := instantiateQueue(t, vm, checksum)
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	env := api.MockEnv()
+	info := api.MockInfo("creator", nil)
+
+	sim, err := vm.SimulateExecute(checksum, env, info, []byte(`{"enqueue":{"value":17}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	require.NotNil(t, sim.Response)
+	assert.NotZero(t, sim.GasUsed)
+	require.Len(t, sim.Writes, 1)
+	assert.False(t, sim.Writes[0].Delete)
+	assert.Equal(t, []byte(`{"value":17}`), sim.Writes[0].Value)
+
+	// the queue in the real store is still empty
+	data, _, err := vm.Query(checksum, env, []byte(`{"count":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	assert.Equal(t, `{"count":0}`, string(data))
+
+	// the simulation used exactly as much gas as the real execution, including the gas the store
+	// charged for the write
+	assert.GreaterOrEqual(t, sim.ExternalGasUsed, uint64(api.SetPrice))
+	before := gasMeter.GasConsumed()
+	res, gasUsed, err := vm.Execute(checksum, env, info, []byte(`{"enqueue":{"value":17}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	assert.Equal(t, res, sim.Response)
+	assert.Equal(t, gasUsed, sim.GasUsed)
+	assert.Equal(t, gasMeter.GasConsumed()-before, sim.ExternalGasUsed)
+}
+
+func TestSimulateExecuteRecordsQueries(t *testing.T) {
+	vm := withVM(t)
+	checksum := createTestContract(t, vm, HACKATOM_TEST_CONTRACT)
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+	store := api.NewLookup(gasMeter)
+	goapi := api.NewMockAPI()
+	balance := types.Coins{types.NewCoin(250, "ATOM")}
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, balance)
+
+	env := api.MockEnv()
+	info := api.MockInfo("creator", nil)
+	msg := []byte(`{"verifier": "fred", "beneficiary": "bob"}`)
+	_, _, err := vm.Instantiate(checksum, env, info, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+
+	info = api.MockInfo("fred", nil)
+	sim, err := vm.SimulateExecute(checksum, env, info, []byte(`{"release":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.NoError(t, err)
+	require.Len(t, sim.Response.Messages, 1)
+	require.Len(t, sim.Queries, 1)
+	require.NotNil(t, sim.Queries[0].Request.Bank)
+	require.NotNil(t, sim.Queries[0].Request.Bank.AllBalances)
+	assert.Equal(t, api.MOCK_CONTRACT_ADDR, sim.Queries[0].Request.Bank.AllBalances.Address)
+	assert.NoError(t, sim.Queries[0].Err)
+	assert.NotZero(t, sim.ExternalGasUsed)
+
+	// a failing call still reports what happened up to the error
+	info = api.MockInfo("bob", nil)
+	sim, err = vm.SimulateExecute(checksum, env, info, []byte(`{"release":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+	require.Error(t, err)
+	require.NotNil(t, sim)
+	assert.Nil(t, sim.Response)
+	assert.NotZero(t, sim.GasUsed)
+}
@@ -0,0 +1,65 @@
+// Package tmdb adapts stores based on github.com/tendermint/tm-db to the store interfaces of wasmvm.
+// It is a separate Go module, so the wasmvm module itself does not depend on tm-db.
+package tmdb
+
+import (
+	dbm "github.com/tendermint/tm-db"
+
+	cosmwasm "github.com/line/wasmvm"
+)
+
+// KVStore has the methods of the SDK's KVStore, whose iterators are tm-db iterators
+type KVStore interface {
+	Get(key []byte) []byte
+	Set(key, value []byte)
+	Delete(key []byte)
+	Iterator(start, end []byte) dbm.Iterator
+	ReverseIterator(start, end []byte) dbm.Iterator
+}
+
+// NewKVStore adapts an SDK style store to cosmwasm.KVStore
+func NewKVStore(store KVStore) cosmwasm.KVStore {
+	return kvStore{store: store}
+}
+
+type kvStore struct {
+	store KVStore
+}
+
+var _ cosmwasm.KVStore = kvStore{}
+
+func (s kvStore) Get(key []byte) []byte {
+	return s.store.Get(key)
+}
+
+func (s kvStore) Set(key, value []byte) {
+	s.store.Set(key, value)
+}
+
+func (s kvStore) Delete(key []byte) {
+	s.store.Delete(key)
+}
+
+func (s kvStore) Iterator(start, end []byte) cosmwasm.Iterator {
+	return s.store.Iterator(start, end)
+}
+
+func (s kvStore) ReverseIterator(start, end []byte) cosmwasm.Iterator {
+	return s.store.ReverseIterator(start, end)
+}
+
+// NewDB adapts a tm-db database to cosmwasm.KVStoreWithErrors, such that its errors are reported
+// to the VM. Use cosmwasm.AdaptKVStoreWithErrors to pass it to the contract calls.
+func NewDB(db dbm.DB) cosmwasm.KVStoreWithErrors {
+	return cosmwasm.KVStoreFuncs{
+		GetFunc:    db.Get,
+		SetFunc:    db.Set,
+		DeleteFunc: db.Delete,
+		IteratorFunc: func(start, end []byte) (cosmwasm.Iterator, error) {
+			return db.Iterator(start, end)
+		},
+		ReverseIteratorFunc: func(start, end []byte) (cosmwasm.Iterator, error) {
+			return db.ReverseIterator(start, end)
+		},
+	}
+}
@@ -0,0 +1,157 @@
+package tmdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	cosmwasm "github.com/line/wasmvm"
+	"github.com/line/wasmvm/internal/api"
+	"github.com/line/wasmvm/types"
+)
+
+const (
+	TESTING_FEATURES     = "staking,stargate,iterator"
+	TESTING_GAS_LIMIT    = uint64(500_000_000_000)
+	TESTING_MEMORY_LIMIT = 32  // MiB
+	TESTING_CACHE_SIZE   = 100 // MiB
+)
+
+// sdkStore is an SDK style store over a tm-db database, panicking on errors
+type sdkStore struct {
+	db dbm.DB
+}
+
+var _ KVStore = sdkStore{}
+
+func (s sdkStore) Get(key []byte) []byte {
+	v, err := s.db.Get(key)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (s sdkStore) Set(key, value []byte) {
+	if err := s.db.Set(key, value); err != nil {
+		panic(err)
+	}
+}
+
+func (s sdkStore) Delete(key []byte) {
+	if err := s.db.Delete(key); err != nil {
+		panic(err)
+	}
+}
+
+func (s sdkStore) Iterator(start, end []byte) dbm.Iterator {
+	it, err := s.db.Iterator(start, end)
+	if err != nil {
+		panic(err)
+	}
+	return it
+}
+
+func (s sdkStore) ReverseIterator(start, end []byte) dbm.Iterator {
+	it, err := s.db.ReverseIterator(start, end)
+	if err != nil {
+		panic(err)
+	}
+	return it
+}
+
+// stores returns one store per supported kind of backend
+func stores() map[string]cosmwasm.KVStore {
+	return map[string]cosmwasm.KVStore{
+		"mock":   api.NewLookup(api.NewMockGasMeter(TESTING_GAS_LIMIT)),
+		"sdk":    NewKVStore(sdkStore{db: dbm.NewMemDB()}),
+		"tm-db":  cosmwasm.AdaptKVStoreWithErrors(NewDB(dbm.NewMemDB())),
+		"memory": cosmwasm.AdaptKVStoreWithErrors(api.NewMemDB()),
+	}
+}
+
+func collect(it cosmwasm.Iterator) []string {
+	defer it.Close()
+	var out []string
+	for ; it.Valid(); it.Next() {
+		out = append(out, string(it.Key())+"="+string(it.Value()))
+	}
+	return out
+}
+
+func TestIteratorEquivalence(t *testing.T) {
+	results := make(map[string][][]string)
+	for name, store := range stores() {
+		for _, key := range []string{"b", "d", "a", "c", "e"} {
+			store.Set([]byte(key), []byte("v"+key))
+		}
+		store.Delete([]byte("c"))
+		results[name] = [][]string{
+			collect(store.Iterator(nil, nil)),
+			collect(store.ReverseIterator(nil, nil)),
+			collect(store.Iterator([]byte("b"), []byte("e"))),
+			collect(store.ReverseIterator([]byte("b"), []byte("e"))),
+			collect(store.Iterator([]byte("x"), nil)),
+		}
+	}
+	expected := [][]string{
+		{"a=va", "b=vb", "d=vd", "e=ve"},
+		{"e=ve", "d=vd", "b=vb", "a=va"},
+		{"b=vb", "d=vd"},
+		{"d=vd", "b=vb"},
+		nil,
+	}
+	for name, result := range results {
+		assert.Equal(t, expected, result, name)
+	}
+}
+
+// TestContractIteratorEquivalence runs the queue contract, which iterates using cScan and cNext,
+// against all kinds of stores
+func TestContractIteratorEquivalence(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "wasmvm-testing")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	vm, err := cosmwasm.NewVM(tmpdir, TESTING_FEATURES, TESTING_MEMORY_LIMIT, false, TESTING_CACHE_SIZE)
+	require.NoError(t, err)
+	defer vm.Cleanup()
+	wasm, err := ioutil.ReadFile("../testdata/queue.wasm")
+	require.NoError(t, err)
+	checksum, err := vm.Create(wasm)
+	require.NoError(t, err)
+
+	deserCost := types.UFraction{Numerator: 1, Denominator: 1}
+	goapi := api.NewMockAPI()
+	querier := api.DefaultQuerier(api.MOCK_CONTRACT_ADDR, nil)
+	env := api.MockEnv()
+	info := api.MockInfo("creator", nil)
+	queries := []string{`{"count":{}}`, `{"sum":{}}`, `{"reducer":{}}`, `{"list":{}}`}
+
+	results := make(map[string][]string)
+	for name, store := range stores() {
+		gasMeter := api.NewMockGasMeter(TESTING_GAS_LIMIT)
+		_, _, err := vm.Instantiate(checksum, env, info, []byte(`{}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+		require.NoError(t, err, name)
+		for i := 0; i < 5; i++ {
+			msg := []byte(fmt.Sprintf(`{"enqueue":{"value":%d}}`, i*7))
+			_, _, err = vm.Execute(checksum, env, info, msg, store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+			require.NoError(t, err, name)
+		}
+		_, _, err = vm.Execute(checksum, env, info, []byte(`{"dequeue":{}}`), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+		require.NoError(t, err, name)
+
+		for _, query := range queries {
+			data, _, err := vm.Query(checksum, env, []byte(query), store, *goapi, querier, gasMeter, TESTING_GAS_LIMIT, deserCost)
+			require.NoError(t, err, name)
+			results[name] = append(results[name], string(data))
+		}
+	}
+	for name, result := range results {
+		assert.Equal(t, results["mock"], result, name)
+	}
+}
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// BlockEnv holds the pre-encoded parts of Env that are the same for every contract call in a block.
+//
+// Encoding Env is done on every entry point call, but within a block only the transaction index
+// and the contract address change. BlockEnv encodes the block info once, such that the per call
+// Env bytes can be assembled with a single allocation. The result of Encode is byte for byte
+// identical to json.Marshal of the corresponding Env.
+type BlockEnv struct {
+	// height is the block height, which the *EncodedEnv calls need to select the gas config
+	height uint64
+	// prefix is the encoded Env up to and including the "transaction" key
+	prefix []byte
+}
+
+// NewBlockEnv encodes the given block info for use in many Env encodings.
+func NewBlockEnv(block BlockInfo) (*BlockEnv, error) {
+	blockBin, err := json.Marshal(block)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, 0, len(`{"block":,"transaction":`)+len(blockBin))
+	prefix = append(prefix, `{"block":`...)
+	prefix = append(prefix, blockBin...)
+	prefix = append(prefix, `,"transaction":`...)
+	return &BlockEnv{height: block.Height, prefix: prefix}, nil
+}
+
+// Height returns the height of the block info, which must be passed along with the encoded Env
+// to the *EncodedEnv calls of the VM.
+func (b *BlockEnv) Height() uint64 {
+	return b.height
+}
+
+// Encode returns the JSON encoding of an Env with this block and the given transaction and contract info.
+func (b *BlockEnv) Encode(transaction *TransactionInfo, contract ContractInfo) ([]byte, error) {
+	// `null` or `{"index":4294967295}` is at most 20 bytes, the address gets 2 quotes
+	out := make([]byte, 0, len(b.prefix)+20+len(`,"contract":{"address":""}}`)+len(contract.Address))
+	out = append(out, b.prefix...)
+	if transaction == nil {
+		out = append(out, "null"...)
+	} else {
+		out = append(out, `{"index":`...)
+		out = strconv.AppendUint(out, uint64(transaction.Index), 10)
+		out = append(out, '}')
+	}
+	out = append(out, `,"contract":{"address":`...)
+	out, err := appendJSONString(out, contract.Address)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, "}}"...)
+	return out, nil
+}
+
+// EncodeEnv is a shortcut for Encode(env.Transaction, env.Contract). The block info of env is ignored.
+func (b *BlockEnv) EncodeEnv(env Env) ([]byte, error) {
+	return b.Encode(env.Transaction, env.Contract)
+}
+
+// appendJSONString appends s encoded as a JSON string exactly like encoding/json does it.
+// Addresses are almost always plain ASCII, so we avoid the reflection based encoder in that case.
+func appendJSONString(dst []byte, s string) ([]byte, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		// encoding/json escapes control characters, quotes, backslashes, <, >, & (HTML safety)
+		// and validates non-ASCII sequences
+		if c < 0x20 || c >= 0x80 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			bz, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			return append(dst, bz...), nil
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, s...)
+	return append(dst, '"'), nil
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockEnvEncodeMatchesJSON(t *testing.T) {
+	block := BlockInfo{
+		Height:  444,
+		Time:    1955939743_123456789,
+		ChainID: "nice-chain",
+	}
+	blockEnv, err := NewBlockEnv(block)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(444), blockEnv.Height())
+
+	specs := map[string]struct {
+		transaction *TransactionInfo
+		address     string
+	}{
+		"no transaction": {
+			transaction: nil,
+			address:     "wasm10dyr9899g6t0pelew4nvf4j5c3jcgv0r5d3a5l",
+		},
+		"first transaction": {
+			transaction: &TransactionInfo{Index: 0},
+			address:     "contract",
+		},
+		"max index": {
+			transaction: &TransactionInfo{Index: 4294967295},
+			address:     "contract",
+		},
+		"empty address": {
+			transaction: &TransactionInfo{Index: 3},
+			address:     "",
+		},
+		"address needs escaping": {
+			transaction: &TransactionInfo{Index: 3},
+			address:     "a\"b\\c<d>e&f\n",
+		},
+		"non-ASCII address": {
+			transaction: &TransactionInfo{Index: 3},
+			address:     "ünicode ",
+		},
+		"invalid UTF-8 address": {
+			transaction: &TransactionInfo{Index: 3},
+			address:     "bad\xff",
+		},
+	}
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			env := Env{
+				Block:       block,
+				Transaction: spec.transaction,
+				Contract:    ContractInfo{Address: spec.address},
+			}
+			expected, err := json.Marshal(env)
+			require.NoError(t, err)
+
+			bz, err := blockEnv.Encode(spec.transaction, env.Contract)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(bz))
+
+			bz, err = blockEnv.EncodeEnv(env)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(bz))
+		})
+	}
+}
+
+func benchmarkEnvs() []Env {
+	block := BlockInfo{Height: 123, Time: 1578939743_987654321, ChainID: "foobar"}
+	envs := make([]Env, 100)
+	for i := range envs {
+		envs[i] = Env{
+			Block:       block,
+			Transaction: &TransactionInfo{Index: uint32(i)},
+			Contract:    ContractInfo{Address: "link14hj2tavq8fpesdwxxcu44rty3hh90vhujrvcmstl4zr3txmfvw9sgf2vn8"},
+		}
+	}
+	return envs
+}
+
+func BenchmarkEnvMarshal(b *testing.B) {
+	envs := benchmarkEnvs()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, env := range envs {
+			_, err := json.Marshal(env)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBlockEnvEncode(b *testing.B) {
+	envs := benchmarkEnvs()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		// the block env is created once per block
+		blockEnv, err := NewBlockEnv(envs[0].Block)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, env := range envs {
+			_, err := blockEnv.EncodeEnv(env)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
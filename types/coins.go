@@ -0,0 +1,259 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// denomPattern matches the denoms accepted by the Cosmos SDK
+const denomPattern = `[a-zA-Z][a-zA-Z0-9/:._-]{2,127}`
+
+var (
+	denomRegex = regexp.MustCompile(`^` + denomPattern + `$`)
+	coinRegex  = regexp.MustCompile(`^([0-9]+)(` + denomPattern + `)$`)
+)
+
+// ValidateDenom checks that denom is a valid Cosmos SDK denom: 3 to 128 characters starting with a letter,
+// followed by letters, digits and /:._-
+func ValidateDenom(denom string) error {
+	if !denomRegex.MatchString(denom) {
+		return fmt.Errorf("invalid denom %q", denom)
+	}
+	return nil
+}
+
+func NewCoinFromUint256(amount Uint256, denom string) Coin {
+	return Coin{
+		Denom:  denom,
+		Amount: amount.String(),
+	}
+}
+
+// ParseCoin parses a coin like 100ucosm
+func ParseCoin(s string) (Coin, error) {
+	match := coinRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return Coin{}, fmt.Errorf("invalid coin %q: expected amount followed by denom, e.g. 100ucosm", s)
+	}
+	amount, err := ParseUint256(match[1])
+	if err != nil {
+		return Coin{}, fmt.Errorf("invalid coin %q: %w", s, err)
+	}
+	return NewCoinFromUint256(amount, match[2]), nil
+}
+
+// ParseCoins parses a comma separated list of coins like 100ucosm,5uatom and normalizes it.
+// An empty string is an empty list.
+func ParseCoins(s string) (Coins, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var coins Coins
+	for _, part := range strings.Split(s, ",") {
+		coin, err := ParseCoin(part)
+		if err != nil {
+			return nil, err
+		}
+		coins = append(coins, coin)
+	}
+	return coins.Normalize()
+}
+
+// String returns the coin like 100ucosm
+func (c Coin) String() string {
+	return c.Amount + c.Denom
+}
+
+// AmountUint256 parses the amount of the coin
+func (c Coin) AmountUint256() (Uint256, error) {
+	amount, err := ParseUint256(c.Amount)
+	if err != nil {
+		return Uint256{}, fmt.Errorf("invalid amount of %s: %w", c.Denom, err)
+	}
+	return amount, nil
+}
+
+// Validate checks the denom and that the amount is a decimal number without leading zeros that
+// fits into 256 bits. Contracts using cosmwasm-std can only handle amounts up to 128 bits, use
+// ValidateForContracts for coins sent to contracts.
+func (c Coin) Validate() error {
+	if err := ValidateDenom(c.Denom); err != nil {
+		return err
+	}
+	amount, err := c.AmountUint256()
+	if err != nil {
+		return err
+	}
+	if amount.String() != c.Amount {
+		return fmt.Errorf("amount %q of %s is not normalized", c.Amount, c.Denom)
+	}
+	return nil
+}
+
+// ValidateForContracts is like Validate and also checks that the amount fits into the Uint128 of cosmwasm-std
+func (c Coin) ValidateForContracts() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return c.validateUint128()
+}
+
+// validateUint128 checks that the amount fits into 128 bits
+func (c Coin) validateUint128() error {
+	amount, err := c.AmountUint256()
+	if err != nil {
+		return err
+	}
+	if !amount.IsUint128() {
+		return fmt.Errorf("amount %s of %s does not fit into 128 bits", c.Amount, c.Denom)
+	}
+	return nil
+}
+
+// String returns the coins like 100ucosm,5uatom
+func (c Coins) String() string {
+	parts := make([]string, len(c))
+	for i, coin := range c {
+		parts[i] = coin.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Sort sorts the coins by denom in place and returns them
+func (c Coins) Sort() Coins {
+	sort.SliceStable(c, func(i, j int) bool { return c[i].Denom < c[j].Denom })
+	return c
+}
+
+// Validate checks that all coins are valid and positive and that the denoms are sorted and unique,
+// like the coins of the Cosmos SDK. Amounts may use 256 bits, see ValidateForContracts.
+func (c Coins) Validate() error {
+	for i, coin := range c {
+		if err := coin.Validate(); err != nil {
+			return err
+		}
+		if coin.Amount == "0" {
+			return fmt.Errorf("amount of %s is zero", coin.Denom)
+		}
+		if i > 0 && c[i-1].Denom >= coin.Denom {
+			return fmt.Errorf("denoms are not sorted or not unique: %s after %s", coin.Denom, c[i-1].Denom)
+		}
+	}
+	return nil
+}
+
+// ValidateForContracts is like Validate and also checks that all amounts fit into the Uint128 of
+// cosmwasm-std, like the coins the Cosmos SDK sends to contracts
+func (c Coins) ValidateForContracts() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	for _, coin := range c {
+		if err := coin.validateUint128(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// amounts returns the sum of the amounts of each denom
+func (c Coins) amounts() (map[string]Uint256, error) {
+	amounts := make(map[string]Uint256, len(c))
+	for _, coin := range c {
+		amount, err := coin.AmountUint256()
+		if err != nil {
+			return nil, err
+		}
+		sum, err := amounts[coin.Denom].Add(amount)
+		if err != nil {
+			return nil, fmt.Errorf("amount of %s: %w", coin.Denom, err)
+		}
+		amounts[coin.Denom] = sum
+	}
+	return amounts, nil
+}
+
+// coinsFromAmounts returns the sorted coins with positive amounts
+func coinsFromAmounts(amounts map[string]Uint256) Coins {
+	var coins Coins
+	for denom, amount := range amounts {
+		if !amount.IsZero() {
+			coins = append(coins, NewCoinFromUint256(amount, denom))
+		}
+	}
+	return coins.Sort()
+}
+
+// Normalize returns new coins with the amounts of the same denom added up, zero amounts removed
+// and sorted by denom. Denoms are not validated.
+func (c Coins) Normalize() (Coins, error) {
+	amounts, err := c.amounts()
+	if err != nil {
+		return nil, err
+	}
+	return coinsFromAmounts(amounts), nil
+}
+
+// AmountOf returns the sum of the amounts of denom
+func (c Coins) AmountOf(denom string) (Uint256, error) {
+	var sum Uint256
+	for _, coin := range c {
+		if coin.Denom != denom {
+			continue
+		}
+		amount, err := coin.AmountUint256()
+		if err != nil {
+			return Uint256{}, err
+		}
+		if sum, err = sum.Add(amount); err != nil {
+			return Uint256{}, fmt.Errorf("amount of %s: %w", denom, err)
+		}
+	}
+	return sum, nil
+}
+
+// Add returns the normalized sum of c and other
+func (c Coins) Add(other Coins) (Coins, error) {
+	return append(append(Coins{}, c...), other...).Normalize()
+}
+
+// Sub returns the normalized difference of c and other. It fails with ErrUint256Underflow if
+// other contains more of a denom than c.
+func (c Coins) Sub(other Coins) (Coins, error) {
+	amounts, err := c.amounts()
+	if err != nil {
+		return nil, err
+	}
+	subtrahends, err := other.amounts()
+	if err != nil {
+		return nil, err
+	}
+	for denom, subtrahend := range subtrahends {
+		diff, err := amounts[denom].Sub(subtrahend)
+		if err != nil {
+			return nil, fmt.Errorf("cannot subtract %s%s from %s%s: %w", subtrahend, denom, amounts[denom], denom, err)
+		}
+		amounts[denom] = diff
+	}
+	return coinsFromAmounts(amounts), nil
+}
+
+// IsAllGTE returns whether c contains at least the amount of every denom in other
+func (c Coins) IsAllGTE(other Coins) (bool, error) {
+	amounts, err := c.amounts()
+	if err != nil {
+		return false, err
+	}
+	required, err := other.amounts()
+	if err != nil {
+		return false, err
+	}
+	for denom, amount := range required {
+		if amounts[denom].Cmp(amount) < 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
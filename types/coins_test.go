@@ -0,0 +1,146 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseCoins(t *testing.T, s string) Coins {
+	coins, err := ParseCoins(s)
+	require.NoError(t, err)
+	return coins
+}
+
+func TestParseCoin(t *testing.T) {
+	coin, err := ParseCoin(" 0100ucosm ")
+	require.NoError(t, err)
+	assert.Equal(t, Coin{Denom: "ucosm", Amount: "100"}, coin)
+
+	coin, err = ParseCoin("5ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2")
+	require.NoError(t, err)
+	assert.Equal(t, "5", coin.Amount)
+
+	for _, s := range []string{"", "100", "ucosm", "100 ucosm", "-1ucosm", "1.5ucosm", "10uc", "1" + maxUint256 + "ucosm"} {
+		_, err = ParseCoin(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseCoins(t *testing.T) {
+	coins, err := ParseCoins("")
+	require.NoError(t, err)
+	assert.Empty(t, coins)
+
+	// duplicate denoms are added up, zero amounts are dropped and the denoms are sorted
+	coins = mustParseCoins(t, "100ucosm, 5uatom,0ustake,20ucosm")
+	assert.Equal(t, Coins{{Denom: "uatom", Amount: "5"}, {Denom: "ucosm", Amount: "120"}}, coins)
+	assert.Equal(t, "5uatom,120ucosm", coins.String())
+	require.NoError(t, coins.Validate())
+
+	_, err = ParseCoins("100ucosm,")
+	assert.Error(t, err)
+}
+
+func TestCoinsValidate(t *testing.T) {
+	assert.NoError(t, Coins{}.Validate())
+	assert.NoError(t, Coins{NewCoin(1, "uatom"), NewCoin(2, "ucosm")}.Validate())
+
+	invalid := map[string]Coins{
+		"unsorted":   {NewCoin(2, "ucosm"), NewCoin(1, "uatom")},
+		"duplicate":  {NewCoin(1, "ucosm"), NewCoin(2, "ucosm")},
+		"zero":       {NewCoin(0, "ucosm")},
+		"denom":      {NewCoin(1, "1cosm")},
+		"amount":     {{Denom: "ucosm", Amount: "abc"}},
+		"zeros":      {{Denom: "ucosm", Amount: "010"}},
+		"empty":      {{Denom: "ucosm", Amount: ""}},
+		"overflow":   {{Denom: "ucosm", Amount: maxUint256 + "0"}},
+		"denom size": {NewCoin(1, "u")},
+	}
+	for name, coins := range invalid {
+		assert.Error(t, coins.Validate(), name)
+	}
+}
+
+func TestCoinsValidateForContracts(t *testing.T) {
+	maxUint128 := "340282366920938463463374607431768211455"
+	coins := Coins{{Denom: "uatom", Amount: maxUint128}, NewCoin(2, "ucosm")}
+	require.NoError(t, coins.ValidateForContracts())
+	require.NoError(t, coins[0].ValidateForContracts())
+
+	// valid for the SDK, but not for contracts
+	coins[0].Amount = "340282366920938463463374607431768211456"
+	require.NoError(t, coins.Validate())
+	err := coins.ValidateForContracts()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "amount 340282366920938463463374607431768211456 of uatom does not fit into 128 bits")
+	assert.Error(t, coins[0].ValidateForContracts())
+
+	// the checks of Validate still apply
+	assert.Error(t, Coins{NewCoin(2, "ucosm"), NewCoin(1, "uatom")}.ValidateForContracts())
+	assert.Error(t, Coin{Denom: "ucosm", Amount: "010"}.ValidateForContracts())
+}
+
+func TestCoinsArithmetic(t *testing.T) {
+	balance := mustParseCoins(t, "100ucosm,5uatom")
+
+	sum, err := balance.Add(mustParseCoins(t, "340282366920938463463374607431768211455ucosm,1ustake"))
+	require.NoError(t, err)
+	assert.Equal(t, "5uatom,340282366920938463463374607431768211555ucosm,1ustake", sum.String())
+	// the operands are not modified
+	assert.Equal(t, "5uatom,100ucosm", balance.String())
+
+	amount, err := sum.AmountOf("ucosm")
+	require.NoError(t, err)
+	assert.False(t, amount.IsUint128())
+	amount, err = sum.AmountOf("unknown")
+	require.NoError(t, err)
+	assert.True(t, amount.IsZero())
+
+	diff, err := balance.Sub(mustParseCoins(t, "5uatom,40ucosm"))
+	require.NoError(t, err)
+	assert.Equal(t, Coins{NewCoin(60, "ucosm")}, diff)
+	_, err = balance.Sub(mustParseCoins(t, "101ucosm"))
+	assert.ErrorIs(t, err, ErrUint256Underflow)
+	_, err = balance.Sub(mustParseCoins(t, "1ustake"))
+	assert.ErrorIs(t, err, ErrUint256Underflow)
+
+	max := Coins{{Denom: "ucosm", Amount: maxUint256}}
+	_, err = max.Add(Coins{NewCoin(1, "ucosm")})
+	assert.ErrorIs(t, err, ErrUint256Overflow)
+
+	ok, err := balance.IsAllGTE(mustParseCoins(t, "100ucosm"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = balance.IsAllGTE(nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = balance.IsAllGTE(mustParseCoins(t, "100ucosm,6uatom"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = balance.IsAllGTE(mustParseCoins(t, "1ustake"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = balance.Add(Coins{{Denom: "ucosm", Amount: "1.5"}})
+	assert.Error(t, err)
+}
+
+func TestCoinsJSONUnchanged(t *testing.T) {
+	coins := mustParseCoins(t, "100ucosm,5uatom")
+	bz, err := json.Marshal(coins)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"denom":"uatom","amount":"5"},{"denom":"ucosm","amount":"100"}]`, string(bz))
+
+	empty, err := ParseCoins("")
+	require.NoError(t, err)
+	bz, err = json.Marshal(empty)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(bz))
+
+	bz, err = json.Marshal(NewCoinFromUint256(NewUint256(7), "ucosm"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"denom":"ucosm","amount":"7"}`, string(bz))
+}
@@ -0,0 +1,273 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureDir contains canonical JSON written by the Rust side (see fixtureSource)
+const fixtureDir = "testdata/fixtures"
+
+// fixtureSource writes every fixture from the cosmwasm-std types
+const fixtureSource = "../libwasmvm/src/fixtures.rs"
+
+// fixtureNameRegex matches the names of the fixtures written in fixtureSource
+var fixtureNameRegex = regexp.MustCompile(`check_(?:parsed_)?fixture(?:::<[^(]*>)?\(\s*"([a-z0-9_]+)"`)
+
+// fixtureTypes maps the prefix of a fixture name to the Go type that mirrors it
+var fixtureTypes = map[string]func() interface{}{
+	"cosmos_msg_":              func() interface{} { return &CosmosMsg{} },
+	"sub_msg":                  func() interface{} { return &SubMsg{} },
+	"contract_result_":         func() interface{} { return &ContractResult{} },
+	"env":                      func() interface{} { return &Env{} },
+	"message_info":             func() interface{} { return &MessageInfo{} },
+	"reply_":                   func() interface{} { return &Reply{} },
+	"query_request_":           func() interface{} { return &QueryRequest{} },
+	"balance_response":         func() interface{} { return &BalanceResponse{} },
+	"all_balances_response":    func() interface{} { return &AllBalancesResponse{} },
+	"all_validators_response":  func() interface{} { return &AllValidatorsResponse{} },
+	"validator_response":       func() interface{} { return &ValidatorResponse{} },
+	"all_delegations_response": func() interface{} { return &AllDelegationsResponse{} },
+	"delegation_response":      func() interface{} { return &DelegationResponse{} },
+	"bonded_denom_response":    func() interface{} { return &BondedDenomResponse{} },
+	"list_channels_response":   func() interface{} { return &ListChannelsResponse{} },
+	"channel_response":         func() interface{} { return &ChannelResponse{} },
+	"contract_info_response":   func() interface{} { return &ContractInfoResponse{} },
+	"querier_result_":          func() interface{} { return &QuerierResult{} },
+	"system_error_":            func() interface{} { return &SystemError{} },
+	"ibc_channel_open_msg_":    func() interface{} { return &IBCChannelOpenMsg{} },
+	"ibc_channel_connect_msg_": func() interface{} { return &IBCChannelConnectMsg{} },
+	"ibc_channel_close_msg_":   func() interface{} { return &IBCChannelCloseMsg{} },
+	"ibc_packet_receive_msg":   func() interface{} { return &IBCPacketReceiveMsg{} },
+	"ibc_packet_ack_msg":       func() interface{} { return &IBCPacketAckMsg{} },
+	"ibc_packet_timeout_msg":   func() interface{} { return &IBCPacketTimeoutMsg{} },
+	"ibc_channel_open_result_": func() interface{} { return &IBCChannelOpenResult{} },
+	"ibc_basic_result":         func() interface{} { return &IBCBasicResult{} },
+	"ibc_receive_result":       func() interface{} { return &IBCReceiveResult{} },
+}
+
+type fixture struct {
+	name string
+	json []byte
+}
+
+func loadFixtures(t *testing.T) []fixture {
+	paths, err := filepath.Glob(filepath.Join(fixtureDir, "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	sort.Strings(paths)
+	fixtures := make([]fixture, len(paths))
+	for i, path := range paths {
+		bz, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		fixtures[i] = fixture{name: strings.TrimSuffix(filepath.Base(path), ".json"), json: bytes.TrimSpace(bz)}
+	}
+	return fixtures
+}
+
+// fixtureType returns a new value of the type of a fixture, using the longest matching prefix
+func fixtureType(t *testing.T, name string) interface{} {
+	best := ""
+	for prefix := range fixtureTypes {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	require.NotEmpty(t, best, "no Go type for fixture %s", name)
+	return fixtureTypes[best]()
+}
+
+// fixtureDifference is a documented way in which the Go encoding of a fixture may differ from
+// the bytes written by cosmwasm-std
+type fixtureDifference int
+
+const (
+	// omitsNulls means Go omits optional fields that are unset, where serde writes null.
+	// serde reads a missing Option field as None, so contracts see the same value.
+	omitsNulls fixtureDifference = 1 << iota
+	// fieldOrder means the Go struct declares the fields in another order than the Rust struct.
+	// serde does not depend on the order of object fields.
+	fieldOrder
+)
+
+// fixtureExceptions lists every fixture that does not round trip byte for byte and why.
+// All other fixtures must be encoded by Go exactly like by cosmwasm-std.
+var fixtureExceptions = map[string]fixtureDifference{
+	"contract_info_response":          omitsNulls,
+	"contract_result_ok":              omitsNulls | fieldOrder,
+	"contract_result_ok_empty":        fieldOrder,
+	"cosmos_msg_ibc_transfer":         omitsNulls,
+	"cosmos_msg_wasm_instantiate":     omitsNulls,
+	"delegation_response":             fieldOrder,
+	"ibc_channel_open_result_none":    omitsNulls,
+	"ibc_packet_receive_msg":          omitsNulls,
+	"query_request_ibc_channel":       fieldOrder,
+	"query_request_ibc_list_channels": omitsNulls,
+	"reply_ok":                        omitsNulls,
+	"sub_msg_reply_never":             omitsNulls,
+}
+
+// nullFieldRegex matches an object field with a null value together with one adjacent comma
+var nullFieldRegex = regexp.MustCompile(`,"[a-z_]+":null|"[a-z_]+":null,?`)
+
+// decodeJSON decodes JSON into generic values, which do not depend on the order of object fields
+func decodeJSON(t *testing.T, bz []byte) interface{} {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(bz))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&v))
+	return v
+}
+
+// TestFixturesWrittenByRust checks that the fixture files and the fixtures written by fixtureSource
+// are the same set, so no fixture is added here without a writer on the Rust side
+func TestFixturesWrittenByRust(t *testing.T) {
+	source, err := ioutil.ReadFile(fixtureSource)
+	require.NoError(t, err)
+	written := make(map[string]bool)
+	for _, match := range fixtureNameRegex.FindAllStringSubmatch(string(source), -1) {
+		written[match[1]] = true
+	}
+	require.NotEmpty(t, written)
+
+	for _, f := range loadFixtures(t) {
+		assert.True(t, written[f.name], "fixture %s is not written by %s", f.name, fixtureSource)
+		delete(written, f.name)
+	}
+	assert.Empty(t, written, "fixtures written by %s are missing, run make update-fixtures", fixtureSource)
+}
+
+// TestFixturesRoundtrip decodes every fixture into its Go type and checks that encoding it again
+// results in the same bytes, except for the differences listed in fixtureExceptions.
+// Fields the Go types do not know about are an error.
+func TestFixturesRoundtrip(t *testing.T) {
+	fixtures := loadFixtures(t)
+	names := make(map[string]bool, len(fixtures))
+	for _, f := range fixtures {
+		names[f.name] = true
+		t.Run(f.name, func(t *testing.T) {
+			value := fixtureType(t, f.name)
+			dec := json.NewDecoder(bytes.NewReader(f.json))
+			dec.DisallowUnknownFields()
+			require.NoError(t, dec.Decode(value))
+
+			encoded, err := json.Marshal(value)
+			require.NoError(t, err)
+			difference, ok := fixtureExceptions[f.name]
+			if !ok {
+				assert.Equal(t, string(f.json), string(encoded))
+				return
+			}
+			require.NotEqual(t, string(f.json), string(encoded), "fixture is encoded exactly, remove it from fixtureExceptions")
+			expected := f.json
+			if difference&omitsNulls != 0 {
+				expected = nullFieldRegex.ReplaceAll(expected, nil)
+			}
+			if difference&fieldOrder != 0 {
+				assert.Equal(t, decodeJSON(t, expected), decodeJSON(t, encoded), "encoded as %s", encoded)
+			} else {
+				assert.Equal(t, string(expected), string(encoded))
+			}
+		})
+	}
+	for name := range fixtureExceptions {
+		assert.True(t, names[name], "exception for unknown fixture %s", name)
+	}
+}
+
+// TestFixturesExactEncoding checks the types that Go sends to contracts byte by byte
+func TestFixturesExactEncoding(t *testing.T) {
+	gasLimit := uint64(500000)
+	cases := map[string]interface{}{
+		"env": Env{
+			Block:       BlockInfo{Height: 12345, Time: 1571797419879305533, ChainID: "cosmos-testnet-14002"},
+			Transaction: &TransactionInfo{Index: 3},
+			Contract:    ContractInfo{Address: "contract1"},
+		},
+		"env_no_transaction": Env{
+			Block:    BlockInfo{Height: 12345, Time: 1571797419879305533, ChainID: "cosmos-testnet-14002"},
+			Contract: ContractInfo{Address: "contract1"},
+		},
+		"message_info":          MessageInfo{Sender: "creator", Funds: Coins{NewCoin(1000, "ucosm"), NewCoin(5, "uatom")}},
+		"message_info_no_funds": MessageInfo{Sender: "creator"},
+		"reply_ok_no_events":    Reply{ID: 7, Result: SubMsgResult{Ok: &SubMsgResponse{Data: []byte{1, 2}}}},
+		"reply_err":             Reply{ID: 7, Result: SubMsgResult{Err: "out of funds"}},
+		"sub_msg": SubMsg{
+			ID:       12,
+			Msg:      CosmosMsg{Bank: &BankMsg{Burn: &BurnMsg{Amount: Coins{NewCoin(1, "ucosm")}}}},
+			GasLimit: &gasLimit,
+			ReplyOn:  ReplySuccess,
+		},
+		"cosmos_msg_bank_burn_empty":     CosmosMsg{Bank: &BankMsg{Burn: &BurnMsg{}}},
+		"balance_response":               BalanceResponse{Amount: NewCoin(0, "ucosm")},
+		"all_balances_response_empty":    AllBalancesResponse{},
+		"all_validators_response_empty":  AllValidatorsResponse{},
+		"all_delegations_response_empty": AllDelegationsResponse{},
+		"list_channels_response_empty":   ListChannelsResponse{},
+		"validator_response_none":        ValidatorResponse{},
+		"bonded_denom_response":          BondedDenomResponse{Denom: "stake"},
+		"all_validators_response": AllValidatorsResponse{Validators: Validators{{
+			Address: "val1", Commission: "0.05", MaxCommission: "0.1", MaxChangeRate: "0.01",
+		}}},
+		"querier_result_ok":                ToQuerierResult([]byte(`{"verifier":"fred"}`), nil),
+		"querier_result_system_error":      ToQuerierResult(nil, NoSuchContract{Addr: "contract9"}),
+		"system_error_unknown":             SystemError{Unknown: &Unknown{}},
+		"system_error_unsupported_request": SystemError{UnsupportedRequest: &UnsupportedRequest{Kind: "custom"}},
+	}
+
+	fixtures := make(map[string][]byte)
+	for _, f := range loadFixtures(t) {
+		fixtures[f.name] = f.json
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			expected, ok := fixtures[name]
+			require.True(t, ok, "missing fixture %s", name)
+			encoded, err := json.Marshal(value)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(encoded))
+		})
+	}
+}
+
+// TestFixturesEmptyArrays checks that empty lists decode to empty Go values and are encoded as [],
+// never as null, which cosmwasm-std rejects for Vec fields
+func TestFixturesEmptyArrays(t *testing.T) {
+	var coins Coins
+	require.NoError(t, json.Unmarshal([]byte(`[]`), &coins))
+	assert.Empty(t, coins)
+	bz, err := json.Marshal(coins)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(bz))
+
+	var events Events
+	require.NoError(t, json.Unmarshal([]byte(`null`), &events))
+	assert.Empty(t, events)
+	bz, err = json.Marshal(events)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(bz))
+
+	var validators Validators
+	require.NoError(t, json.Unmarshal([]byte(`[]`), &validators))
+	assert.Empty(t, validators)
+	bz, err = json.Marshal(validators)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(bz))
+
+	// nested in the types sent to contracts
+	bz, err = json.Marshal(Reply{Result: SubMsgResult{Ok: &SubMsgResponse{Events: Events{{Type: "wasm"}}}}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":0,"result":{"ok":{"events":[{"type":"wasm","attributes":[]}]}}}`, string(bz))
+	bz, err = json.Marshal(ExecuteMsg{ContractAddr: "c", Msg: []byte("{}")})
+	require.NoError(t, err)
+	assert.Equal(t, `{"contract_addr":"c","msg":"e30=","funds":[]}`, string(bz))
+}
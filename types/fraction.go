@@ -1,5 +1,13 @@
 package types
 
+import (
+	"errors"
+	"math/bits"
+)
+
+// ErrFractionOverflow is returned by the checked fraction operations if the result does not fit into uint64
+var ErrFractionOverflow = errors.New("fraction overflow")
+
 type Fraction struct {
 	Numerator   int64
 	Denominator int64
@@ -18,10 +26,35 @@ type UFraction struct {
 	Denominator uint64
 }
 
+// Mul multiplies the numerator by m. The result silently overflows for large values,
+// use CheckedMul or MulFloor when m is not trusted.
 func (f *UFraction) Mul(m uint64) UFraction {
 	return UFraction{f.Numerator * m, f.Denominator}
 }
 
+// CheckedMul multiplies the numerator by m and returns ErrFractionOverflow if it does not fit into uint64
+func (f UFraction) CheckedMul(m uint64) (UFraction, error) {
+	hi, lo := bits.Mul64(f.Numerator, m)
+	if hi != 0 {
+		return UFraction{}, ErrFractionOverflow
+	}
+	return UFraction{Numerator: lo, Denominator: f.Denominator}, nil
+}
+
+// MulFloor returns floor(m * f). The intermediate product is 128 bit wide, so this only fails
+// if the result itself does not fit into uint64.
+func (f UFraction) MulFloor(m uint64) (uint64, error) {
+	if f.Denominator == 0 {
+		return 0, errors.New("fraction with zero denominator")
+	}
+	hi, lo := bits.Mul64(f.Numerator, m)
+	if hi >= f.Denominator {
+		return 0, ErrFractionOverflow
+	}
+	quo, _ := bits.Div64(hi, lo, f.Denominator)
+	return quo, nil
+}
+
 func (f UFraction) Floor() uint64 {
 	return f.Numerator / f.Denominator
 }
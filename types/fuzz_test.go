@@ -0,0 +1,141 @@
+//go:build go1.18
+// +build go1.18
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzTypes are the types round-tripped by FuzzJSONRoundtrip. The fuzzer picks one by index.
+var fuzzTypes = []func() interface{}{
+	func() interface{} { return &CosmosMsg{} },
+	func() interface{} { return &QueryRequest{} },
+	func() interface{} { return &SystemError{} },
+	func() interface{} { return &SubMsg{} },
+	func() interface{} { return &Reply{} },
+	func() interface{} { return &VoteMsg{} },
+	func() interface{} { return &ListChannelsResponse{} },
+	func() interface{} { return &ChannelResponse{} },
+	func() interface{} { return &AllValidatorsResponse{} },
+	func() interface{} { return &AllDelegationsResponse{} },
+	func() interface{} { return &AllBalancesResponse{} },
+	func() interface{} { return &Coins{} },
+	func() interface{} { return &Events{} },
+	func() interface{} { return &Response{} },
+	func() interface{} { return &ContractResult{} },
+	func() interface{} { return &QueryResponse{} },
+	func() interface{} { return &QuerierResult{} },
+	func() interface{} { return &Env{} },
+	func() interface{} { return &MessageInfo{} },
+	func() interface{} { return &IBCChannelOpenMsg{} },
+	func() interface{} { return &IBCChannelConnectMsg{} },
+	func() interface{} { return &IBCChannelCloseMsg{} },
+	func() interface{} { return &IBCPacketReceiveMsg{} },
+	func() interface{} { return &IBCPacketAckMsg{} },
+	func() interface{} { return &IBCPacketTimeoutMsg{} },
+	func() interface{} { return &IBCChannelOpenResult{} },
+	func() interface{} { return &IBCBasicResult{} },
+	func() interface{} { return &IBCReceiveResult{} },
+}
+
+// FuzzJSONRoundtrip checks that every value the types accept is encoded to JSON that decodes to the
+// same value and encoding again
+func FuzzJSONRoundtrip(f *testing.F) {
+	seeds := map[int][]string{
+		0:  {`{"bank":{"send":{"to_address":"bob","amount":[{"denom":"ucosm","amount":"1"}]}}}`, `{"gov":{"vote":{"proposal_id":1,"vote":"no_with_veto"}}}`, `{"wasm":{"clear_admin":{"contract_addr":"c"}}}`},
+		1:  {`{"bank":{"all_balances":{"address":"bob"}}}`, `{"ibc":{"list_channels":{}}}`, `{"staking":{"bonded_denom":{}}}`, `{"wasm":{"raw":{"contract_addr":"c","key":"Zm9v"}}}`},
+		2:  {`{"no_such_contract":{"addr":"foo"}}`, `{"invalid_request":{"error":"e","request":"e30="}}`, `{"unknown":{}}`},
+		3:  {`{"id":1,"msg":{"bank":{"burn":{"amount":[]}}},"gas_limit":null,"reply_on":"success"}`},
+		4:  {`{"id":7,"result":{"ok":{"events":[],"data":null}}}`, `{"id":7,"result":{"error":"failed"}}`},
+		5:  {`{"proposal_id":4,"vote":"abstain"}`},
+		6:  {`{"channels":[]}`, `{"channels":null}`},
+		9:  {`{"delegations":[]}`},
+		11: {`[]`, `null`, `[{"denom":"a","amount":"0"}]`},
+		12: {`[]`, `null`, `[{"type":"wasm","attributes":[{"key":"k","value":"v"}]}]`},
+		13: {`{"messages":[],"attributes":[],"events":[],"data":"AQI="}`},
+		16: {`{"ok":{"ok":"e30="}}`, `{"ok":{"error":"x"}}`, `{"error":{"unsupported_request":{"kind":"k"}}}`},
+		17: {`{"block":{"height":1,"time":"1","chain_id":"c"},"transaction":{"index":1},"contract":{"address":"c"}}`},
+		22: {`{"packet":{"data":"AA==","src":{"port_id":"p","channel_id":"c"},"dest":{"port_id":"p","channel_id":"c"},"sequence":1,"timeout":{"timestamp":"5"}},"relayer":"r"}`},
+	}
+	for index, values := range seeds {
+		for _, value := range values {
+			f.Add(uint8(index), []byte(value))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, index uint8, data []byte) {
+		newValue := fuzzTypes[int(index)%len(fuzzTypes)]
+		value := newValue()
+		if json.Unmarshal(data, value) != nil {
+			return
+		}
+		encoded, err := json.Marshal(value)
+		require.NoError(t, err)
+
+		decoded := newValue()
+		require.NoError(t, json.Unmarshal(encoded, decoded), "cannot decode %s as %T", encoded, decoded)
+		reencoded, err := json.Marshal(decoded)
+		require.NoError(t, err)
+		require.Equal(t, string(encoded), string(reencoded), "%T", value)
+	})
+}
+
+// echoQuerier returns the request it got, or err if set
+type echoQuerier struct {
+	err error
+}
+
+var _ Querier = echoQuerier{}
+
+func (q echoQuerier) Query(request QueryRequest, _ uint64) ([]byte, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return json.Marshal(request)
+}
+
+func (q echoQuerier) GasConsumed() uint64 {
+	return 0
+}
+
+// FuzzRustQuery checks that arbitrary request bytes from the contract result in exactly one of an
+// ok result or a system error, both of which can be encoded for the contract
+func FuzzRustQuery(f *testing.F) {
+	f.Add([]byte(`{"bank":{"balance":{"address":"a","denom":"b"}}}`), uint8(0))
+	f.Add([]byte(`{"custom":{"foo":1}}`), uint8(1))
+	f.Add([]byte(`{"wasm":{"smart":{"contract_addr":"c","msg":"e30="}}}`), uint8(2))
+	f.Add([]byte(`{"bank":`), uint8(0))
+	f.Add([]byte{0xff, 0x00}, uint8(3))
+
+	queriers := []Querier{
+		echoQuerier{},
+		echoQuerier{err: errors.New("contract error")},
+		echoQuerier{err: NoSuchContract{Addr: "c"}},
+		echoQuerier{err: UnsupportedRequest{Kind: "custom"}},
+	}
+
+	f.Fuzz(func(t *testing.T, request []byte, querier uint8) {
+		res := RustQuery(queriers[int(querier)%len(queriers)], request, 1_000_000)
+		require.True(t, (res.Ok == nil) != (res.Err == nil), "exactly one of ok and error must be set: %#v", res)
+		if res.Err != nil {
+			// exactly one variant is set, otherwise Error panics
+			require.NotEmpty(t, res.Err.Error())
+		}
+
+		bz, err := json.Marshal(res)
+		require.NoError(t, err)
+		var decoded QuerierResult
+		require.NoError(t, json.Unmarshal(bz, &decoded))
+
+		if res.Err != nil && res.Err.InvalidRequest != nil {
+			// the original request is passed back to the contract unchanged
+			require.True(t, bytes.Equal(request, decoded.Err.InvalidRequest.Request))
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// GasConfig is the gas pricing the VM applies on top of the gas metered inside of the Wasm runtime
+// and the gas consumed by the KVStore on the GasMeter.
+type GasConfig struct {
+	// BaseCost is charged once per contract call
+	BaseCost uint64
+	// DeserializationCostPerByte is charged per byte of the contract result
+	DeserializationCostPerByte UFraction
+	// HumanizeCostPerByte is charged per byte of the canonical input of a humanize address call,
+	// in addition to the cost returned by the GoAPI
+	HumanizeCostPerByte UFraction
+	// CanonicalizeCostPerByte is charged per byte of the human input of a canonicalize address call,
+	// in addition to the cost returned by the GoAPI
+	CanonicalizeCostPerByte UFraction
+	// QueryResponseCostPerByte is charged per byte of a query response, in addition to the gas the
+	// querier consumed
+	QueryResponseCostPerByte UFraction
+}
+
+// zeroCost is a fraction with a valid denominator that prices everything at 0 gas
+var zeroCost = UFraction{Numerator: 0, Denominator: 1}
+
+// DefaultGasConfig returns the pricing used when no gas schedule is set,
+// i.e. only deserialization is charged with the given cost per byte.
+func DefaultGasConfig(deserCost UFraction) GasConfig {
+	return GasConfig{
+		DeserializationCostPerByte: deserCost,
+		HumanizeCostPerByte:        zeroCost,
+		CanonicalizeCostPerByte:    zeroCost,
+		QueryResponseCostPerByte:   zeroCost,
+	}
+}
+
+// Validate returns an error if one of the fractions has a zero denominator
+func (c GasConfig) Validate() error {
+	fractions := []struct {
+		name string
+		f    UFraction
+	}{
+		{"DeserializationCostPerByte", c.DeserializationCostPerByte},
+		{"HumanizeCostPerByte", c.HumanizeCostPerByte},
+		{"CanonicalizeCostPerByte", c.CanonicalizeCostPerByte},
+		{"QueryResponseCostPerByte", c.QueryResponseCostPerByte},
+	}
+	for _, entry := range fractions {
+		if entry.f.Denominator == 0 {
+			return fmt.Errorf("%s has a zero denominator", entry.name)
+		}
+	}
+	return nil
+}
+
+// GasScheduleVersion is a GasConfig that is active from a block height on
+type GasScheduleVersion struct {
+	Height uint64
+	Config GasConfig
+}
+
+// GasSchedule is a list of gas configs activated at increasing block heights.
+// This allows chains to upgrade their pricing at a given height while still being able to
+// replay old blocks with the pricing of that time.
+type GasSchedule struct {
+	versions []GasScheduleVersion
+}
+
+// NewGasSchedule creates a schedule from the given versions. The first version must start at height 0
+// and heights must be unique.
+func NewGasSchedule(versions ...GasScheduleVersion) (*GasSchedule, error) {
+	if len(versions) == 0 {
+		return nil, errors.New("gas schedule needs at least one version")
+	}
+	sorted := make([]GasScheduleVersion, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Height < sorted[j].Height })
+	if sorted[0].Height != 0 {
+		return nil, errors.New("first gas schedule version must start at height 0")
+	}
+	for idx, version := range sorted {
+		if idx > 0 && version.Height == sorted[idx-1].Height {
+			return nil, fmt.Errorf("duplicate gas schedule version at height %d", version.Height)
+		}
+		if err := version.Config.Validate(); err != nil {
+			return nil, fmt.Errorf("gas schedule version at height %d: %w", version.Height, err)
+		}
+	}
+	return &GasSchedule{versions: sorted}, nil
+}
+
+// Versions returns the versions ordered by height
+func (s *GasSchedule) Versions() []GasScheduleVersion {
+	return s.versions
+}
+
+// ConfigAt returns the config active at the given block height
+func (s *GasSchedule) ConfigAt(height uint64) GasConfig {
+	// index of the first version starting after height
+	idx := sort.Search(len(s.versions), func(i int) bool { return s.versions[i].Height > height })
+	return s.versions[idx-1].Config
+}
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUFractionMulFloor(t *testing.T) {
+	f := UFraction{Numerator: 3, Denominator: 2}
+	res, err := f.MulFloor(5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), res)
+
+	// the intermediate product does not fit into uint64 but the result does
+	half := UFraction{Numerator: 1 << 63, Denominator: 1 << 62}
+	res, err = half.MulFloor(1 << 40)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1<<41), res)
+
+	_, err = f.MulFloor(math.MaxUint64)
+	assert.ErrorIs(t, err, ErrFractionOverflow)
+
+	_, err = UFraction{Numerator: 1, Denominator: 0}.MulFloor(1)
+	assert.Error(t, err)
+
+	_, err = f.CheckedMul(math.MaxUint64)
+	assert.ErrorIs(t, err, ErrFractionOverflow)
+	checked, err := f.CheckedMul(4)
+	require.NoError(t, err)
+	assert.Equal(t, UFraction{Numerator: 12, Denominator: 2}, checked)
+}
+
+func TestGasSchedule(t *testing.T) {
+	v1 := DefaultGasConfig(UFraction{Numerator: 1, Denominator: 1})
+	v2 := v1
+	v2.BaseCost = 1000
+	v3 := v2
+	v3.QueryResponseCostPerByte = UFraction{Numerator: 3, Denominator: 1}
+
+	// versions are sorted by height
+	schedule, err := NewGasSchedule(
+		GasScheduleVersion{Height: 200, Config: v3},
+		GasScheduleVersion{Height: 0, Config: v1},
+		GasScheduleVersion{Height: 100, Config: v2},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, v1, schedule.ConfigAt(0))
+	assert.Equal(t, v1, schedule.ConfigAt(99))
+	assert.Equal(t, v2, schedule.ConfigAt(100))
+	assert.Equal(t, v2, schedule.ConfigAt(199))
+	assert.Equal(t, v3, schedule.ConfigAt(200))
+	assert.Equal(t, v3, schedule.ConfigAt(math.MaxUint64))
+	assert.Len(t, schedule.Versions(), 3)
+}
+
+func TestGasScheduleInvalid(t *testing.T) {
+	valid := DefaultGasConfig(UFraction{Numerator: 1, Denominator: 1})
+
+	_, err := NewGasSchedule()
+	assert.Error(t, err)
+
+	_, err = NewGasSchedule(GasScheduleVersion{Height: 5, Config: valid})
+	assert.Error(t, err)
+
+	_, err = NewGasSchedule(GasScheduleVersion{Height: 0, Config: valid}, GasScheduleVersion{Height: 0, Config: valid})
+	assert.Error(t, err)
+
+	_, err = NewGasSchedule(GasScheduleVersion{Height: 0, Config: GasConfig{}})
+	assert.Error(t, err)
+}
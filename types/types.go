@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 )
 
@@ -50,12 +51,54 @@ func (c *Coins) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-type OutOfGasError struct{}
+// GasLocation describes where a contract call ran out of gas
+type GasLocation string
+
+const (
+	// GasLocationWasm means the gas limit was exceeded by the Wasm execution, including the gas
+	// reported by the host callbacks
+	GasLocationWasm GasLocation = "wasm"
+	// GasLocationStorageRead means the GasMeter ran out of gas when reading from or iterating over the store
+	GasLocationStorageRead GasLocation = "storage read"
+	// GasLocationStorageWrite means the GasMeter ran out of gas when writing to or deleting from the store
+	GasLocationStorageWrite GasLocation = "storage write"
+	// GasLocationQuery means the querier ran out of gas
+	GasLocationQuery GasLocation = "query"
+	// GasLocationAddressAPI means the GoAPI ran out of gas when humanizing or canonicalizing an address
+	GasLocationAddressAPI GasLocation = "address api"
+	// GasLocationDeserialization means there was not enough gas left to deserialize the contract result
+	GasLocationDeserialization GasLocation = "deserialization"
+	// GasLocationBaseCost means the gas limit was lower than the base cost of a call
+	GasLocationBaseCost GasLocation = "base cost"
+)
+
+// OutOfGasError is returned when a contract call runs out of gas.
+// The fields are filled as far as they are known, the zero value is a valid OutOfGasError.
+type OutOfGasError struct {
+	// Limit is the gas limit of the call
+	Limit uint64
+	// Used is the gas used by the call as reported by the VM
+	Used uint64
+	// Location is where the call ran out of gas
+	Location GasLocation
+	// Descriptor is the descriptor of the SDK's ErrorOutOfGas panic if the GasMeter ran out of gas
+	Descriptor string
+}
 
 var _ error = OutOfGasError{}
 
 func (o OutOfGasError) Error() string {
-	return "Out of gas"
+	msg := "Out of gas"
+	if o.Location != "" {
+		msg += " in " + string(o.Location)
+	}
+	if o.Descriptor != "" {
+		msg += ": " + o.Descriptor
+	}
+	if o.Limit != 0 {
+		msg += fmt.Sprintf(" (gas used: %d, limit: %d)", o.Used, o.Limit)
+	}
+	return msg
 }
 
 // Contains static analysis info of the contract (the Wasm code to be precise).
@@ -78,4 +121,32 @@ type Metrics struct {
 	SizePinnedMemoryCache uint64
 	// Cumulative size of all elements in memory cache (in bytes)
 	SizeMemoryCache uint64
+	// Storage shows the key and value lengths contracts use in storage calls. It is tracked
+	// in Go, not in libwasmvm.
+	Storage StorageMetrics
+}
+
+// StorageLimits are the maximum key and value lengths in bytes a contract can use in storage calls.
+// 0 means no limit is enforced by the Go callbacks.
+type StorageLimits struct {
+	MaxKeyLength   uint64
+	MaxValueLength uint64
+}
+
+// StorageMetrics show how close contracts get to the StorageLimits
+type StorageMetrics struct {
+	// LargestKey is the length of the largest key seen in a storage call
+	LargestKey uint64
+	// LargestValue is the length of the largest value seen in a storage call
+	LargestValue uint64
+	// KeysNearLimit is the number of keys of at least 90% of the key length limit
+	// that were accepted
+	KeysNearLimit uint64
+	// ValuesNearLimit is the number of values of at least 90% of the value length limit
+	// that were accepted
+	ValuesNearLimit uint64
+	// RejectedKeys is the number of keys rejected for exceeding the limit
+	RejectedKeys uint64
+	// RejectedValues is the number of values rejected for exceeding the limit
+	RejectedValues uint64
 }
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUint256Overflow is returned if the result of an operation does not fit into 256 bits
+	ErrUint256Overflow = errors.New("uint256 overflow")
+	// ErrUint256Underflow is returned if the result of a subtraction would be negative
+	ErrUint256Underflow = errors.New("uint256 underflow")
+)
+
+// Uint256 is an unsigned 256 bit integer, large enough for the amounts of both cosmwasm-std (Uint128)
+// and the Cosmos SDK (sdk.Int). The zero value is 0. In JSON it is a decimal string, like the amount of a Coin.
+type Uint256 struct {
+	// words are in little endian order
+	words [4]uint64
+}
+
+func NewUint256(v uint64) Uint256 {
+	return Uint256{words: [4]uint64{v}}
+}
+
+// ParseUint256 parses a decimal number without sign. Leading zeros are accepted.
+func ParseUint256(s string) (Uint256, error) {
+	if s == "" {
+		return Uint256{}, errors.New("empty number")
+	}
+	var u Uint256
+	for i := 0; i < len(s); i++ {
+		digit := s[i]
+		if digit < '0' || digit > '9' {
+			return Uint256{}, fmt.Errorf("invalid number %q", s)
+		}
+		var ok bool
+		u, ok = u.mulAdd(10, uint64(digit-'0'))
+		if !ok {
+			return Uint256{}, fmt.Errorf("number %q: %w", s, ErrUint256Overflow)
+		}
+	}
+	return u, nil
+}
+
+// mulAdd returns u * m + add and false if the result does not fit
+func (u Uint256) mulAdd(m uint64, add uint64) (Uint256, bool) {
+	carry := add
+	for i := range u.words {
+		hi, lo := bits.Mul64(u.words[i], m)
+		var c uint64
+		u.words[i], c = bits.Add64(lo, carry, 0)
+		carry = hi + c
+	}
+	return u, carry == 0
+}
+
+// Add returns u + v or ErrUint256Overflow
+func (u Uint256) Add(v Uint256) (Uint256, error) {
+	var res Uint256
+	var carry uint64
+	for i := range u.words {
+		res.words[i], carry = bits.Add64(u.words[i], v.words[i], carry)
+	}
+	if carry != 0 {
+		return Uint256{}, ErrUint256Overflow
+	}
+	return res, nil
+}
+
+// Sub returns u - v or ErrUint256Underflow
+func (u Uint256) Sub(v Uint256) (Uint256, error) {
+	var res Uint256
+	var borrow uint64
+	for i := range u.words {
+		res.words[i], borrow = bits.Sub64(u.words[i], v.words[i], borrow)
+	}
+	if borrow != 0 {
+		return Uint256{}, ErrUint256Underflow
+	}
+	return res, nil
+}
+
+// Cmp returns -1 if u < v, 0 if u == v and 1 if u > v
+func (u Uint256) Cmp(v Uint256) int {
+	for i := len(u.words) - 1; i >= 0; i-- {
+		switch {
+		case u.words[i] < v.words[i]:
+			return -1
+		case u.words[i] > v.words[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+func (u Uint256) IsZero() bool {
+	return u.words == [4]uint64{}
+}
+
+// IsUint128 returns whether u fits into a Uint128 of cosmwasm-std, which contracts use for Coin amounts
+func (u Uint256) IsUint128() bool {
+	return u.words[2] == 0 && u.words[3] == 0
+}
+
+// Uint64 returns u and whether it fits into uint64
+func (u Uint256) Uint64() (uint64, bool) {
+	return u.words[0], u.words[1] == 0 && u.words[2] == 0 && u.words[3] == 0
+}
+
+// String returns u as a decimal number without leading zeros
+func (u Uint256) String() string {
+	if u.IsZero() {
+		return "0"
+	}
+	// split into chunks of 19 decimal digits, the largest power of 10 that fits into uint64
+	const chunk = 10_000_000_000_000_000_000
+	var chunks []uint64
+	for !u.IsZero() {
+		var rem uint64
+		for i := len(u.words) - 1; i >= 0; i-- {
+			u.words[i], rem = bits.Div64(rem, u.words[i], chunk)
+		}
+		chunks = append(chunks, rem)
+	}
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatUint(chunks[len(chunks)-1], 10))
+	for i := len(chunks) - 2; i >= 0; i-- {
+		fmt.Fprintf(&sb, "%019d", chunks[i])
+	}
+	return sb.String()
+}
+
+func (u Uint256) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.String())
+}
+
+func (u *Uint256) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseUint256(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
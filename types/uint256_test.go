@@ -0,0 +1,86 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const maxUint256 = "115792089237316195423570985008687907853269984665640564039457584007913129639935"
+
+func TestParseUint256(t *testing.T) {
+	for _, s := range []string{"0", "1", "18446744073709551615", "18446744073709551616", "340282366920938463463374607431768211455", maxUint256} {
+		u, err := ParseUint256(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, s, u.String())
+	}
+
+	// leading zeros are accepted but not kept
+	u, err := ParseUint256("000120")
+	require.NoError(t, err)
+	assert.Equal(t, "120", u.String())
+
+	_, err = ParseUint256("115792089237316195423570985008687907853269984665640564039457584007913129639936")
+	assert.ErrorIs(t, err, ErrUint256Overflow)
+	for _, s := range []string{"", "-1", "+1", "1.5", "1e3", " 1", "0x10"} {
+		_, err = ParseUint256(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestUint256StringMatchesBigInt(t *testing.T) {
+	n := new(big.Int)
+	for i := 0; i < 256; i += 7 {
+		n.Lsh(big.NewInt(1), uint(i))
+		n.Sub(n, big.NewInt(1))
+		u, err := ParseUint256(n.String())
+		require.NoError(t, err)
+		assert.Equal(t, n.String(), u.String())
+	}
+}
+
+func TestUint256Arithmetic(t *testing.T) {
+	max, err := ParseUint256(maxUint256)
+	require.NoError(t, err)
+	one := NewUint256(1)
+
+	sum, err := NewUint256(math.MaxUint64).Add(one)
+	require.NoError(t, err)
+	assert.Equal(t, "18446744073709551616", sum.String())
+	_, ok := sum.Uint64()
+	assert.False(t, ok)
+	diff, err := sum.Sub(one)
+	require.NoError(t, err)
+	v, ok := diff.Uint64()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(math.MaxUint64), v)
+
+	_, err = max.Add(one)
+	assert.ErrorIs(t, err, ErrUint256Overflow)
+	_, err = one.Sub(NewUint256(2))
+	assert.ErrorIs(t, err, ErrUint256Underflow)
+
+	assert.Equal(t, -1, one.Cmp(sum))
+	assert.Equal(t, 1, max.Cmp(sum))
+	assert.Equal(t, 0, sum.Cmp(sum))
+	assert.True(t, Uint256{}.IsZero())
+	assert.True(t, sum.IsUint128())
+	assert.False(t, max.IsUint128())
+}
+
+func TestUint256JSON(t *testing.T) {
+	u, err := ParseUint256("340282366920938463463374607431768211456")
+	require.NoError(t, err)
+	bz, err := json.Marshal(u)
+	require.NoError(t, err)
+	assert.Equal(t, `"340282366920938463463374607431768211456"`, string(bz))
+
+	var parsed Uint256
+	require.NoError(t, json.Unmarshal(bz, &parsed))
+	assert.Equal(t, u, parsed)
+	assert.Error(t, json.Unmarshal([]byte(`123`), &parsed))
+}